@@ -0,0 +1,161 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Backend describes an implementation of a VCS to the package. The Git, Svn,
+// Hg, and Bzr backends are registered by default. Other VCS can be added with
+// RegisterBackend so NewRepo, Open, Discover, and DetectVcsFromFS work with
+// them.
+type Backend struct {
+	// New creates a Repo for the remote and local locations. It has the same
+	// semantics as NewGitRepo and friends.
+	New func(remote, local string, opts ...Option) (Repo, error)
+
+	// MetadataDir is the directory, such as .git, the VCS keeps its metadata
+	// in at the root of a working copy. It is used to detect the VCS when
+	// Detect is nil and is skipped when walking a working copy.
+	MetadataDir string
+
+	// Detect reports if the directory at path is the root of a working copy
+	// for the VCS. It is optional when MetadataDir is set.
+	Detect func(path string) bool
+
+	// Schemes lists URL schemes, such as git+ssh, that identify the VCS from
+	// a remote.
+	Schemes []string
+
+	// Probe returns a command that succeeds when remote is a repository of
+	// the VCS, such as git ls-remote. It should not prompt for input. It is
+	// used to detect the VCS of remotes when probing is enabled with
+	// WithProbe and is optional.
+	Probe func(remote string) *Command
+}
+
+// detect reports if path is the root of a working copy for the backend.
+func (b *Backend) detect(path string) bool {
+	if b.Detect != nil {
+		return b.Detect(path)
+	}
+	if b.MetadataDir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(path, b.MetadataDir))
+	return err == nil
+}
+
+type registeredBackend struct {
+	typ Type
+	Backend
+}
+
+var (
+	backendsMu sync.RWMutex
+
+	// backends is in the order the VCS are checked for when detecting the
+	// VCS of a working copy. This is the order of guessed popularity for the
+	// built-in backends.
+	backends []registeredBackend
+)
+
+func init() {
+	RegisterBackend(Git, Backend{
+		New: func(remote, local string, opts ...Option) (Repo, error) {
+			r, err := NewGitRepo(remote, local, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+		MetadataDir: ".git",
+		Schemes:     []string{"git", "git+ssh"},
+		Probe: func(remote string) *Command {
+			return &Command{
+				Name: "git",
+				Args: []string{"ls-remote", remote},
+				Env:  mergeEnvLists([]string{"GIT_TERMINAL_PROMPT=0"}, os.Environ()),
+			}
+		},
+	})
+	RegisterBackend(Svn, Backend{
+		New: func(remote, local string, opts ...Option) (Repo, error) {
+			r, err := NewSvnRepo(remote, local, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+		MetadataDir: ".svn",
+		Schemes:     []string{"svn+ssh"},
+		Probe: func(remote string) *Command {
+			return &Command{Name: "svn", Args: []string{"info", "--non-interactive", remote}}
+		},
+	})
+	RegisterBackend(Hg, Backend{
+		New: func(remote, local string, opts ...Option) (Repo, error) {
+			r, err := NewHgRepo(remote, local, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+		MetadataDir: ".hg",
+		Probe: func(remote string) *Command {
+			return &Command{Name: "hg", Args: []string{"identify", "--noninteractive", remote}}
+		},
+	})
+	RegisterBackend(Bzr, Backend{
+		New: func(remote, local string, opts ...Option) (Repo, error) {
+			r, err := NewBzrRepo(remote, local, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+		MetadataDir: ".bzr",
+		Schemes:     []string{"bzr+ssh"},
+		Probe: func(remote string) *Command {
+			return &Command{Name: "bzr", Args: []string{"info", remote}}
+		},
+	})
+}
+
+// RegisterBackend adds the backend for the VCS type t, replacing any backend
+// already registered for it. Backends that are added are checked after the
+// built-in ones when detecting the VCS of a working copy or remote. It is
+// safe to call from multiple goroutines but is intended to be called from an
+// init function.
+func RegisterBackend(t Type, b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	b.Schemes = slices.Clone(b.Schemes)
+	for i := range backends {
+		if backends[i].typ == t {
+			backends[i].Backend = b
+			return
+		}
+	}
+	backends = append(backends, registeredBackend{typ: t, Backend: b})
+}
+
+// registeredBackends returns a snapshot of the registered backends in order.
+func registeredBackends() []registeredBackend {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return slices.Clone(backends)
+}
+
+// lookupBackend returns the backend registered for t.
+func lookupBackend(t Type) (Backend, bool) {
+	for _, b := range registeredBackends() {
+		if b.typ == t {
+			return b.Backend, true
+		}
+	}
+	return Backend{}, false
+}
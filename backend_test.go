@@ -0,0 +1,104 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// fakeRepo is a Repo for a VCS registered by a test. Only the getters work.
+type fakeRepo struct {
+	Repo
+	typ           Type
+	remote, local string
+}
+
+func (f *fakeRepo) Vcs() Type         { return f.typ }
+func (f *fakeRepo) Remote() string    { return f.remote }
+func (f *fakeRepo) LocalPath() string { return f.local }
+
+func registerFakeBackend(t *testing.T, typ Type, b Backend) {
+	orig := registeredBackends()
+	t.Cleanup(func() {
+		backendsMu.Lock()
+		backends = orig
+		backendsMu.Unlock()
+	})
+	b.New = func(remote, local string, _ ...Option) (Repo, error) {
+		return &fakeRepo{typ: typ, remote: remote, local: local}, nil
+	}
+	RegisterBackend(typ, b)
+}
+
+func TestRegisterBackend(t *testing.T) {
+	const fossil Type = "fossil"
+	registerFakeBackend(t, fossil, Backend{
+		MetadataDir: ".fslckout",
+		Schemes:     []string{"fossil+ssh"},
+	})
+
+	repo, err := NewRepo("fossil+ssh://example.com/repo", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Vcs() != fossil || repo.Remote() != "fossil+ssh://example.com/repo" {
+		t.Errorf("NewRepo did not use the backend for the scheme: %s %s", repo.Vcs(), repo.Remote())
+	}
+
+	root := t.TempDir()
+	wc := filepath.Join(root, "wc")
+	if err := os.MkdirAll(filepath.Join(wc, ".fslckout"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ty, err := DetectVcsFromFS(wc)
+	if err != nil || ty != fossil {
+		t.Errorf("DetectVcsFromFS did not detect the backend: %s %v", ty, err)
+	}
+
+	repo, err = Open(filepath.Join(wc, ".fslckout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Vcs() != fossil || repo.LocalPath() != wc {
+		t.Errorf("Open did not use the backend: %s %s", repo.Vcs(), repo.LocalPath())
+	}
+
+	repos, err := Discover(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].LocalPath() != wc {
+		t.Errorf("Discover did not find the working copy: %v", repos)
+	}
+}
+
+func TestRegisterBackendReplace(t *testing.T) {
+	var probed []string
+	registerFakeBackend(t, Hg, Backend{
+		Detect: func(path string) bool {
+			probed = append(probed, path)
+			return filepath.Base(path) == "custom"
+		},
+	})
+
+	dir := filepath.Join(t.TempDir(), "custom")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ty, err := DetectVcsFromFS(dir)
+	if err != nil || ty != Hg {
+		t.Errorf("the replaced backend was not used: %s %v", ty, err)
+	}
+	if !slices.Equal(probed, []string{dir}) {
+		t.Errorf("unexpected probes: %v", probed)
+	}
+
+	var types []Type
+	for _, b := range registeredBackends() {
+		types = append(types, b.typ)
+	}
+	if !slices.Equal(types, []Type{Git, Svn, Hg, Bzr}) {
+		t.Errorf("replacing a backend changed the order: %v", types)
+	}
+}
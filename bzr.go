@@ -1,10 +1,15 @@
 package vcs
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
+	"iter"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -14,13 +19,13 @@ import (
 var bzrDetectURL = regexp.MustCompile("parent branch: (?P<foo>.+)\n")
 
 // NewBzrRepo creates a new instance of BzrRepo. The remote and local directories
-// need to be passed in.
-func NewBzrRepo(remote, local string) (*BzrRepo, error) {
+// need to be passed in. Any opts are applied to the repo.
+func NewBzrRepo(remote, local string, opts ...Option) (*BzrRepo, error) {
+	c := newConfig(opts)
 	// Log deprecation warning
 	Logger.Println("WARNING: The Bazaar (bzr) project has been retired and is no longer maintained. Support for bzr may be removed in a future version.")
 
-	ins := depInstalled("bzr")
-	if !ins {
+	if !c.installed("bzr") {
 		return nil, NewLocalError("bzr is not installed", nil, "")
 	}
 	ltype, err := DetectVcsFromFS(local)
@@ -33,7 +38,9 @@ func NewBzrRepo(remote, local string) (*BzrRepo, error) {
 	r := &BzrRepo{}
 	r.setRemote(remote)
 	r.setLocalPath(local)
+	r.vcsType = Bzr
 	r.Logger = Logger
+	r.configure(c)
 
 	// With the other VCS we can check if the endpoint locally is different
 	// from the one configured internally. But, with Bzr you can't. For example,
@@ -43,10 +50,7 @@ func NewBzrRepo(remote, local string) (*BzrRepo, error) {
 	// the change from https to http and the path chance.
 	// Here we set the remote to be the local one if none is passed in.
 	if err == nil && r.CheckLocal() && remote == "" {
-		c := exec.Command("bzr", "info")
-		c.Dir = local
-		c.Env = envForDir(c.Dir)
-		out, err := c.CombinedOutput()
+		out, err := r.RunFromDir("bzr", "info")
 		if err != nil {
 			return nil, NewLocalError("Unable to retrieve local repo information", err, string(out))
 		}
@@ -74,6 +78,11 @@ func (s BzrRepo) Vcs() Type {
 
 // Get is used to perform an initial clone of a repository.
 func (s *BzrRepo) Get() error {
+	return s.GetContext(context.Background())
+}
+
+// GetContext is the context-aware version of Get.
+func (s *BzrRepo) GetContext(ctx context.Context) error {
 
 	basePath := filepath.Dir(filepath.FromSlash(s.LocalPath()))
 	if _, err := os.Stat(basePath); os.IsNotExist(err) {
@@ -83,7 +92,7 @@ func (s *BzrRepo) Get() error {
 		}
 	}
 
-	out, err := s.run("bzr", "branch", "--", s.Remote(), s.LocalPath())
+	out, err := s.runContext(ctx, "bzr", "branch", "--", s.Remote(), s.LocalPath())
 	if err != nil {
 		return NewRemoteError("Unable to get repository", err, string(out))
 	}
@@ -93,7 +102,12 @@ func (s *BzrRepo) Get() error {
 
 // Init initializes a bazaar repository at local location.
 func (s *BzrRepo) Init() error {
-	out, err := s.run("bzr", "init", "--", s.LocalPath())
+	return s.InitContext(context.Background())
+}
+
+// InitContext is the context-aware version of Init.
+func (s *BzrRepo) InitContext(ctx context.Context) error {
+	out, err := s.runContext(ctx, "bzr", "init", "--", s.LocalPath())
 
 	// There are some windows cases where bazaar cannot create the parent
 	// directory if it does not already exist, to the location it's trying
@@ -107,7 +121,7 @@ func (s *BzrRepo) Init() error {
 				return NewLocalError("Unable to initialize repository", err, "")
 			}
 
-			out, err = s.run("bzr", "init", "--", s.LocalPath())
+			out, err = s.runContext(ctx, "bzr", "init", "--", s.LocalPath())
 			if err != nil {
 				return NewLocalError("Unable to initialize repository", err, string(out))
 			}
@@ -123,11 +137,16 @@ func (s *BzrRepo) Init() error {
 
 // Update performs a Bzr pull and update to an existing checkout.
 func (s *BzrRepo) Update() error {
-	out, err := s.RunFromDir("bzr", "pull")
+	return s.UpdateContext(context.Background())
+}
+
+// UpdateContext is the context-aware version of Update.
+func (s *BzrRepo) UpdateContext(ctx context.Context) error {
+	out, err := s.RunFromDirContext(ctx, "bzr", "pull")
 	if err != nil {
 		return NewRemoteError("Unable to update repository", err, string(out))
 	}
-	out, err = s.RunFromDir("bzr", "update")
+	out, err = s.RunFromDirContext(ctx, "bzr", "update")
 	if err != nil {
 		return NewRemoteError("Unable to update repository", err, string(out))
 	}
@@ -136,7 +155,12 @@ func (s *BzrRepo) Update() error {
 
 // UpdateVersion sets the version of a package currently checked out via Bzr.
 func (s *BzrRepo) UpdateVersion(version string) error {
-	out, err := s.RunFromDir("bzr", "update", "-r", version)
+	return s.UpdateVersionContext(context.Background(), version)
+}
+
+// UpdateVersionContext is the context-aware version of UpdateVersion.
+func (s *BzrRepo) UpdateVersionContext(ctx context.Context, version string) error {
+	out, err := s.RunFromDirContext(ctx, "bzr", "update", "-r"+version)
 	if err != nil {
 		return NewLocalError("Unable to update checked out version", err, string(out))
 	}
@@ -145,8 +169,13 @@ func (s *BzrRepo) UpdateVersion(version string) error {
 
 // Version retrieves the current version.
 func (s *BzrRepo) Version() (string, error) {
+	return s.VersionContext(context.Background())
+}
+
+// VersionContext is the context-aware version of Version.
+func (s *BzrRepo) VersionContext(ctx context.Context) (string, error) {
 
-	out, err := s.RunFromDir("bzr", "revno", "--tree")
+	out, err := s.RunFromDirContext(ctx, "bzr", "revno", "--tree")
 	if err != nil {
 		return "", NewLocalError("Unable to retrieve checked out version", err, string(out))
 	}
@@ -159,12 +188,17 @@ func (s *BzrRepo) Version() (string, error) {
 // * A tag if on a tag
 // * Otherwise a revision
 func (s *BzrRepo) Current() (string, error) {
-	tip, err := s.CommitInfo("-1")
+	return s.CurrentContext(context.Background())
+}
+
+// CurrentContext is the context-aware version of Current.
+func (s *BzrRepo) CurrentContext(ctx context.Context) (string, error) {
+	tip, err := s.CommitInfoContext(ctx, "-1")
 	if err != nil {
 		return "", err
 	}
 
-	curr, err := s.Version()
+	curr, err := s.VersionContext(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -173,7 +207,7 @@ func (s *BzrRepo) Current() (string, error) {
 		return "-1", nil
 	}
 
-	ts, err := s.TagsFromCommit(curr)
+	ts, err := s.TagsFromCommitContext(ctx, curr)
 	if err != nil {
 		return "", err
 	}
@@ -186,7 +220,12 @@ func (s *BzrRepo) Current() (string, error) {
 
 // Date retrieves the date on the latest commit.
 func (s *BzrRepo) Date() (time.Time, error) {
-	out, err := s.RunFromDir("bzr", "version-info", "--custom", "--template={date}")
+	return s.DateContext(context.Background())
+}
+
+// DateContext is the context-aware version of Date.
+func (s *BzrRepo) DateContext(ctx context.Context) (time.Time, error) {
+	out, err := s.RunFromDirContext(ctx, "bzr", "version-info", "--custom", "--template={date}")
 	if err != nil {
 		return time.Time{}, NewLocalError("Unable to retrieve revision date", err, string(out))
 	}
@@ -215,9 +254,19 @@ func (s *BzrRepo) Branches() ([]string, error) {
 	return branches, nil
 }
 
+// BranchesContext is the context-aware version of Branches.
+func (s *BzrRepo) BranchesContext(_ context.Context) ([]string, error) {
+	return s.Branches()
+}
+
 // Tags returns a list of available tags on the repository.
 func (s *BzrRepo) Tags() ([]string, error) {
-	out, err := s.RunFromDir("bzr", "tags")
+	return s.TagsContext(context.Background())
+}
+
+// TagsContext is the context-aware version of Tags.
+func (s *BzrRepo) TagsContext(ctx context.Context) ([]string, error) {
+	out, err := s.RunFromDirContext(ctx, "bzr", "tags")
 	if err != nil {
 		return []string{}, NewLocalError("Unable to retrieve tags", err, string(out))
 	}
@@ -228,63 +277,475 @@ func (s *BzrRepo) Tags() ([]string, error) {
 // IsReference returns if a string is a reference. A reference can be a
 // commit id or tag.
 func (s *BzrRepo) IsReference(r string) bool {
-	_, err := s.RunFromDir("bzr", "revno", "-r", r)
+	return s.IsReferenceContext(context.Background(), r)
+}
+
+// IsReferenceContext is the context-aware version of IsReference.
+func (s *BzrRepo) IsReferenceContext(ctx context.Context, r string) bool {
+	_, err := s.RunFromDirContext(ctx, "bzr", "revno", "-r"+r)
 	return err == nil
 }
 
 // IsDirty returns if the checkout has been modified from the checked
-// out reference.
+// out reference. Untracked files only count when the repo was created with
+// WithCountUntracked.
 func (s *BzrRepo) IsDirty() bool {
-	out, err := s.RunFromDir("bzr", "diff")
-	return err != nil || len(out) != 0
+	return s.IsDirtyContext(context.Background())
+}
+
+// IsDirtyContext is the context-aware version of IsDirty.
+func (s *BzrRepo) IsDirtyContext(ctx context.Context) bool {
+	entries, err := s.status(ctx, StatusOptions{}, s.countUntracked)
+	return err != nil || isDirty(entries, s.countUntracked)
+}
+
+// Status lists the files in the working copy that differ from the checked
+// out revision. See StatusRepo for details.
+func (s *BzrRepo) Status(opts StatusOptions) ([]StatusEntry, error) {
+	return s.StatusContext(context.Background(), opts)
+}
+
+// StatusContext is the context-aware version of Status.
+func (s *BzrRepo) StatusContext(ctx context.Context, opts StatusOptions) ([]StatusEntry, error) {
+	return s.status(ctx, opts, true)
+}
+
+// status lists the files as StatusContext does, leaving out the unknown
+// files when untracked is false.
+func (s *BzrRepo) status(ctx context.Context, opts StatusOptions, untracked bool) ([]StatusEntry, error) {
+	args := []string{"status", "--short"}
+	if !untracked {
+		args = append(args, "--versioned")
+	}
+	out, stderr, err := s.outputFromDir(ctx, "bzr", args...)
+	if err != nil {
+		return nil, NewLocalError("Unable to retrieve status", err, stderr)
+	}
+	var ignored []byte
+	if opts.Ignored {
+		ignored, stderr, err = s.outputFromDir(ctx, "bzr", "ls", "--ignored", "--recursive")
+		if err != nil {
+			return nil, NewLocalError("Unable to retrieve status", err, stderr)
+		}
+	}
+	return parseBzrStatus(out, ignored), nil
+}
+
+// parseBzrStatus parses the output of bzr status --short and bzr ls
+// --ignored. Each line of the status has a column for the change to the
+// versioning, one for the change to the content, and one for the execute bit
+// before the path.
+func parseBzrStatus(status, ignored []byte) []StatusEntry {
+	set := statusSet{}
+	for _, l := range strings.Split(string(status), "\n") {
+		l = strings.TrimSuffix(l, "\r")
+		if len(l) < 5 {
+			continue
+		}
+		v, c, p := l[0], l[1], strings.TrimSuffix(l[4:], "/")
+		var e StatusEntry
+		switch {
+		case v == 'C':
+			// Conflicts are described, as in "Text conflict in a.txt".
+			if before, _, ok := strings.Cut(strings.TrimPrefix(p, "Path conflict: "), " / "); ok {
+				p = before
+			} else if i := strings.LastIndex(p, " in "); i >= 0 {
+				p = p[i+len(" in "):]
+			}
+			e = StatusEntry{Path: p, Status: FileConflicted}
+		case v == '?':
+			e = StatusEntry{Path: p, Status: FileUntracked}
+		case v == 'R':
+			from, to, _ := strings.Cut(p, " => ")
+			e = StatusEntry{Path: strings.TrimSuffix(to, "/"), OrigPath: strings.TrimSuffix(from, "/"), Status: FileRenamed}
+		case v == '+':
+			e = StatusEntry{Path: p, Status: FileAdded}
+		case v == '-':
+			e = StatusEntry{Path: p, Status: FileDeleted}
+		case v == ' ' && c == 'D':
+			e = StatusEntry{Path: p, Status: FileMissing}
+		case v == ' ' && (c == 'M' || c == 'K' || c == 'N'):
+			e = StatusEntry{Path: p, Status: FileModified}
+		default:
+			// Pending merges and unchanged entries
+			continue
+		}
+		set.add(e)
+	}
+	for _, l := range strings.Split(string(ignored), "\n") {
+		if l = strings.TrimSuffix(strings.TrimSpace(l), "/"); l != "" {
+			set.add(StatusEntry{Path: filepath.ToSlash(l), Status: FileIgnored})
+		}
+	}
+	return set.entries()
+}
+
+// Diff returns the parsed differences between the revisions from and to.
+// See DiffRepo for their meaning.
+func (s *BzrRepo) Diff(from, to string, paths ...string) (*Diff, error) {
+	return s.DiffContext(context.Background(), from, to, paths...)
+}
+
+// DiffContext is the context-aware version of Diff.
+func (s *BzrRepo) DiffContext(ctx context.Context, from, to string, paths ...string) (*Diff, error) {
+	return diff(ctx, func(ctx context.Context, w io.Writer) error {
+		return s.WriteDiffContext(ctx, w, from, to, paths...)
+	})
+}
+
+// WriteDiff writes the unified diff between the revisions from and to to w.
+// See DiffRepo for their meaning.
+func (s *BzrRepo) WriteDiff(w io.Writer, from, to string, paths ...string) error {
+	return s.WriteDiffContext(context.Background(), w, from, to, paths...)
+}
+
+// WriteDiffContext is the context-aware version of WriteDiff.
+func (s *BzrRepo) WriteDiffContext(ctx context.Context, w io.Writer, from, to string, paths ...string) error {
+	args := []string{"diff"}
+	if from != "" || to != "" {
+		if from == "" {
+			v, err := s.VersionContext(ctx)
+			if err != nil {
+				return err
+			}
+			from = v
+		}
+		if to != "" {
+			from += ".." + to
+		}
+		args = append(args, "-r"+from)
+	}
+	args = append(append(args, "--"), paths...)
+	return s.writeDiff(ctx, w, "bzr", args...)
+}
+
+// ReadFile returns the contents of the file at path in the revision rev. See
+// FileRepo for details.
+func (s *BzrRepo) ReadFile(rev, path string) (io.ReadCloser, error) {
+	return s.ReadFileContext(context.Background(), rev, path)
+}
+
+// ReadFileContext is the context-aware version of ReadFile.
+func (s *BzrRepo) ReadFileContext(ctx context.Context, rev, path string) (io.ReadCloser, error) {
+	rev, err := s.fileRevision(ctx, rev)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := s.lookup(ctx, rev, path)
+	if err != nil {
+		return nil, err
+	}
+	return s.readFile(ctx, fi, "bzr", "cat", "-r"+rev, "--", fi.Path)
+}
+
+// Stat describes the file or directory at path in the revision rev. See
+// FileRepo for details.
+func (s *BzrRepo) Stat(rev, path string) (*FileInfo, error) {
+	return s.StatContext(context.Background(), rev, path)
+}
+
+// StatContext is the context-aware version of Stat.
+func (s *BzrRepo) StatContext(ctx context.Context, rev, path string) (*FileInfo, error) {
+	rev, err := s.fileRevision(ctx, rev)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := s.lookup(ctx, rev, path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode.IsRegular() {
+		// Neither bzr ls nor the inventory give the size of files, so the
+		// bytes of the file are counted as they are read.
+		r := s.readFromDir(ctx, "Unable to retrieve file information", "bzr", "cat", "-r"+rev, "--", fi.Path)
+		defer r.Close()
+		if fi.Size, err = io.Copy(io.Discard, r); err != nil {
+			return nil, err
+		}
+	}
+	return fi, nil
+}
+
+// lookup describes the file or directory at path in the revision rev, which
+// must not be empty, without its size.
+func (s *BzrRepo) lookup(ctx context.Context, rev, path string) (*FileInfo, error) {
+	path = cleanRepoPath(path)
+	if path == "." {
+		if err := fileNotFound(ctx, s.CommitInfoContext, rev); err != ErrFileNotFound {
+			return nil, err
+		}
+		return &FileInfo{Path: path, Mode: fs.ModeDir | 0755}, nil
+	}
+	dir := "."
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		dir = path[:i]
+	}
+	out, _, err := s.outputFromDir(ctx, "bzr", "ls", "--versioned", "-r"+rev, "--", dir)
+	if err != nil {
+		return nil, fileNotFound(ctx, s.CommitInfoContext, rev)
+	}
+	fi, ok := parseBzrLs(string(out), path)
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return fi, nil
+}
+
+// fileRevision returns rev, or the checked out revision when rev is empty as
+// bzr reads the working tree when no revision is given.
+func (s *BzrRepo) fileRevision(ctx context.Context, rev string) (string, error) {
+	if rev != "" {
+		return rev, nil
+	}
+	return s.VersionContext(ctx)
+}
+
+// parseBzrLs finds path in the output of bzr ls, which marks directories
+// with a trailing slash and symbolic links with a trailing at sign.
+func parseBzrLs(out, path string) (*FileInfo, bool) {
+	for _, l := range strings.Split(out, "\n") {
+		l = filepath.ToSlash(strings.TrimRight(l, "\r"))
+		fi := &FileInfo{Path: path, Mode: 0644}
+		switch {
+		case l == path:
+		case strings.HasSuffix(l, "/"), strings.HasSuffix(l, "+"):
+			// Tree references are nested branches.
+			fi.Mode = fs.ModeDir | 0755
+			l = l[:len(l)-1]
+		case strings.HasSuffix(l, "@"):
+			fi.Mode = fs.ModeSymlink | 0777
+			l = l[:len(l)-1]
+		}
+		if l == path {
+			return fi, true
+		}
+	}
+	return nil, false
 }
 
 // CommitInfo retrieves metadata about a commit.
 func (s *BzrRepo) CommitInfo(id string) (*CommitInfo, error) {
+	return s.CommitInfoContext(context.Background(), id)
+}
+
+// CommitInfoContext is the context-aware version of CommitInfo.
+func (s *BzrRepo) CommitInfoContext(ctx context.Context, id string) (*CommitInfo, error) {
+	// Revisions are passed as part of the -r option, here and elsewhere, so
+	// that bzr cannot read one starting with a dash as an option.
 	r := "-r" + id
-	out, err := s.RunFromDir("bzr", "log", r, "--log-format=long")
+	out, err := s.RunFromDirContext(ctx, "bzr", "log", r, "--log-format=long", "--show-ids")
 	if err != nil {
+		if isContextErr(err) {
+			return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
+		}
 		return nil, ErrRevisionUnavailable
 	}
 
-	ci := &CommitInfo{}
-	lines := strings.Split(string(out), "\n")
-	const format = "Mon 2006-01-02 15:04:05 -0700"
-	var track int
-	var trackOn bool
-
-	// Note, bzr does not appear to use i18m.
-	for i, l := range lines {
-		if strings.HasPrefix(l, "revno:") {
-			ci.Commit = strings.TrimSpace(strings.TrimPrefix(l, "revno:"))
-		} else if strings.HasPrefix(l, "committer:") {
-			ci.Author = strings.TrimSpace(strings.TrimPrefix(l, "committer:"))
-		} else if strings.HasPrefix(l, "timestamp:") {
-			ts := strings.TrimSpace(strings.TrimPrefix(l, "timestamp:"))
-			ci.Date, err = time.Parse(format, ts)
-			if err != nil {
-				return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
-			}
-		} else if strings.TrimSpace(l) == "message:" {
-			track = i
-			trackOn = true
-		} else if trackOn && i > track {
-			ci.Message = ci.Message + l
-		}
+	var ci *CommitInfo
+	err = parseBzrLog(bytes.NewReader(out), func(c *CommitInfo) bool {
+		ci = c
+		return false
+	})
+	if err != nil && err != errStopLog {
+		return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
 	}
-	ci.Message = strings.TrimSpace(ci.Message)
 
 	// Didn't find the revision
-	if ci.Author == "" {
+	if ci == nil || ci.Author == "" {
 		return nil, ErrRevisionUnavailable
 	}
 
 	return ci, nil
 }
 
+// Log returns an iterator over the commits selected by opts, newest first.
+func (s *BzrRepo) Log(opts LogOptions) iter.Seq2[*CommitInfo, error] {
+	return s.LogContext(context.Background(), opts)
+}
+
+// LogContext is the context-aware version of Log.
+func (s *BzrRepo) LogContext(ctx context.Context, opts LogOptions) iter.Seq2[*CommitInfo, error] {
+	return logSeq(ctx, opts, func(ctx context.Context, emit func(*CommitInfo) bool) error {
+		args := []string{"log", "--log-format=long", "--show-ids", "-n0"}
+		if opts.FirstParent {
+			args[3] = "-n1"
+		}
+
+		// The range of bzr log includes its start, which is excluded here.
+		lower, upper, from, extra := opts.From, opts.To, "", 0
+		var fromInfo *CommitInfo
+		if opts.From != "" {
+			ci, err := s.CommitInfoContext(ctx, opts.From)
+			if err != nil {
+				return err
+			}
+			fromInfo, from, extra = ci, ci.Commit, 1
+		}
+
+		// A date in the range is the first revision committed after it. The
+		// dates replace the ends of the range they narrow, which the dates of
+		// the revisions tell.
+		if !opts.Since.IsZero() || !opts.Until.IsZero() {
+			top := opts.To
+			if top == "" {
+				top = "-1"
+			}
+			ci, err := s.CommitInfoContext(ctx, top)
+			if err != nil {
+				return err
+			}
+			if !opts.Since.IsZero() {
+				if ci.CommitDate.Before(opts.Since) {
+					return nil
+				}
+				// Bzr times have fractions of a second that are not in the
+				// date, so it is moved back to include a revision at Since.
+				if fromInfo == nil || fromInfo.CommitDate.Before(opts.Since) {
+					lower, from, extra = bzrDate(opts.Since.Add(-time.Second)), "", 1
+				}
+			}
+			// The revision after Until is removed by the final check of the
+			// dates.
+			if !opts.Until.IsZero() && ci.CommitDate.After(opts.Until) {
+				if fromInfo != nil && fromInfo.CommitDate.After(opts.Until) {
+					return nil
+				}
+				upper = bzrDate(opts.Until)
+				extra++
+			}
+		}
+		if lower != "" || upper != "" {
+			args = append(args, "-r"+lower+".."+upper)
+		}
+
+		// Merged revisions are listed below the revision that merged them
+		// whatever their dates, so the dates are only exact with FirstParent.
+		native := opts.Author == "" && (opts.FirstParent || (opts.Since.IsZero() && opts.Until.IsZero()))
+		if n := opts.limit(extra, native); n != "" {
+			args = append(args, "-l", n)
+		}
+		if opts.Path != "" {
+			args = append(args, "--", opts.Path)
+		}
+
+		return s.streamFromDir(ctx, func(r io.Reader) error {
+			return parseBzrLog(r, func(ci *CommitInfo) bool {
+				if from != "" && ci.Commit == from {
+					return true
+				}
+				return emit(ci)
+			})
+		}, "bzr", args...)
+	})
+}
+
+// bzrDate returns the revision spec of the first revision committed after t.
+// Bzr reads the date in the local time zone.
+func bzrDate(t time.Time) string {
+	return "date:" + t.Local().Format("2006-01-02,15:04:05")
+}
+
+// parseBzrLog parses the long format of bzr log with --show-ids, passing each
+// commit to emit. Merged revisions are indented below the revision that
+// merged them. Bzr records a single time for a commit, and the author only
+// when it differs from the committer.
+func parseBzrLog(r io.Reader, emit func(*CommitInfo) bool) error {
+	const format = "Mon 2006-01-02 15:04:05 -0700"
+	var ci *CommitInfo
+	var indent string
+	var msg []string
+	inMsg := false
+	flush := func() bool {
+		if ci == nil || ci.Commit == "" {
+			return true
+		}
+		if ci.AuthorName == "" {
+			ci.AuthorName, ci.AuthorEmail = ci.CommitterName, ci.CommitterEmail
+		}
+		ci.Author = joinPerson(ci.AuthorName, ci.AuthorEmail)
+		ci.Date = ci.CommitDate
+		ci.setMessage(strings.Join(msg, "\n"))
+		c := ci
+		ci, msg, inMsg = nil, nil, false
+		return emit(c)
+	}
+
+	// Note, bzr does not appear to use i18n.
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		l := sc.Text()
+		t := strings.TrimSpace(l)
+		if t != "" && strings.Trim(t, "-") == "" {
+			if !flush() {
+				return errStopLog
+			}
+			ci = &CommitInfo{}
+			indent = l[:len(l)-len(strings.TrimLeft(l, " "))]
+			continue
+		}
+		if ci == nil {
+			continue
+		}
+		if inMsg {
+			// The message is indented by two spaces. It ends at the first line
+			// that is not, such as the note bzr adds about hidden merges.
+			if m, ok := strings.CutPrefix(l, indent+"  "); ok {
+				msg = append(msg, m)
+				continue
+			}
+			if t == "" {
+				msg = append(msg, "")
+				continue
+			}
+			inMsg = false
+		}
+
+		key, value, _ := strings.Cut(t, ":")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "revno":
+			// Merges are marked as in "revno: 3 [merge]".
+			if f := strings.Fields(value); len(f) > 0 {
+				ci.Commit = f[0]
+			}
+		case "parent":
+			ci.Parents = append(ci.Parents, "revid:"+value)
+		case "committer":
+			ci.CommitterName, ci.CommitterEmail = splitPerson(value)
+		case "author":
+			// Commits with several authors list them separated by commas.
+			if i := strings.Index(value, ">, "); i >= 0 {
+				value = value[:i+1]
+			}
+			ci.AuthorName, ci.AuthorEmail = splitPerson(value)
+		case "timestamp":
+			d, err := time.Parse(format, value)
+			if err != nil {
+				return err
+			}
+			ci.CommitDate = d
+		case "message":
+			inMsg = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if !flush() {
+		return errStopLog
+	}
+	return nil
+}
+
 // TagsFromCommit retrieves tags from a commit id.
 func (s *BzrRepo) TagsFromCommit(id string) ([]string, error) {
-	out, err := s.RunFromDir("bzr", "tags", "-r", id)
+	return s.TagsFromCommitContext(context.Background(), id)
+}
+
+// TagsFromCommitContext is the context-aware version of TagsFromCommit.
+func (s *BzrRepo) TagsFromCommitContext(ctx context.Context, id string) ([]string, error) {
+	out, err := s.RunFromDirContext(ctx, "bzr", "tags", "-r"+id)
 	if err != nil {
 		return []string{}, NewLocalError("Unable to retrieve tags", err, string(out))
 	}
@@ -295,6 +756,11 @@ func (s *BzrRepo) TagsFromCommit(id string) ([]string, error) {
 
 // Ping returns if remote location is accessible.
 func (s *BzrRepo) Ping() bool {
+	return s.PingContext(context.Background())
+}
+
+// PingContext is the context-aware version of Ping.
+func (s *BzrRepo) PingContext(ctx context.Context) bool {
 
 	// Running bzr info is slow. Many of the projects are on launchpad which
 	// has a public 1.0 API we can use.
@@ -306,20 +772,25 @@ func (s *BzrRepo) Ping() bool {
 			// get returns the body and an err. If the status code is not a 200
 			// an error is returned. Launchpad returns a 404 for a codebase that
 			// does not exist. Otherwise it returns a JSON object describing it.
-			_, er := get("https://api.launchpad.net/1.0/" + try)
+			_, er := get(ctx, s.httpClient, "https://api.launchpad.net/1.0/"+try)
 			return er == nil
 		}
 	}
 
 	// This is the same command that Go itself uses but it's not fast (or fast
 	// enough by my standards). A faster method would be useful.
-	_, err = s.run("bzr", "info", "--", s.Remote())
+	_, err = s.runContext(ctx, "bzr", "info", "--", s.Remote())
 	return err == nil
 }
 
 // ExportDir exports the current revision to the passed in directory.
 func (s *BzrRepo) ExportDir(dir string) error {
-	out, err := s.RunFromDir("bzr", "export", "--", dir)
+	return s.ExportDirContext(context.Background(), dir)
+}
+
+// ExportDirContext is the context-aware version of ExportDir.
+func (s *BzrRepo) ExportDirContext(ctx context.Context, dir string) error {
+	out, err := s.RunFromDirContext(ctx, "bzr", "export", "--", dir)
 	s.log(out)
 	if err != nil {
 		return NewLocalError("Unable to export source", err, string(out))
@@ -328,6 +799,30 @@ func (s *BzrRepo) ExportDir(dir string) error {
 	return nil
 }
 
+// ExportModuleZip writes the Go module zip file for the module modulePath at
+// version, found in subdir of the repo, to w. The current revision is
+// exported and the files are laid out as the go command expects, with the
+// rules of golang.org/x/mod/zip applied.
+func (s *BzrRepo) ExportModuleZip(w io.Writer, modulePath, version, subdir string) error {
+	return s.ExportModuleZipContext(context.Background(), w, modulePath, version, subdir)
+}
+
+// ExportModuleZipContext is the context-aware version of ExportModuleZip.
+func (s *BzrRepo) ExportModuleZipContext(ctx context.Context, w io.Writer, modulePath, version, subdir string) error {
+	return writeModuleZip(ctx, s.ExportDirContext, w, modulePath, version, subdir)
+}
+
+// DirHash returns the h1: hash, as recorded in go.sum, of the module zip file
+// ExportModuleZip writes for the current revision.
+func (s *BzrRepo) DirHash(modulePath, version, subdir string) (string, error) {
+	return s.DirHashContext(context.Background(), modulePath, version, subdir)
+}
+
+// DirHashContext is the context-aware version of DirHash.
+func (s *BzrRepo) DirHashContext(ctx context.Context, modulePath, version, subdir string) (string, error) {
+	return moduleHash(ctx, s.ExportDirContext, modulePath, version, subdir)
+}
+
 // Multi-lingual manner check for the VCS error that it couldn't create directory.
 // https://bazaar.launchpad.net/~bzr-pqm/bzr/bzr.dev/files/head:/po/
 func (s *BzrRepo) isUnableToCreateDir(err error) bool {
@@ -2,16 +2,26 @@ package vcs
 
 import (
 	"bytes"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 )
 
-// Canary test to ensure BzrRepo implements the Repo interface.
-var _ Repo = &BzrRepo{}
+// Canary test to ensure BzrRepo implements the Repo interface and the optional
+// interfaces.
+var (
+	_ Repo        = &BzrRepo{}
+	_ DiffRepo    = &BzrRepo{}
+	_ FileRepo    = &BzrRepo{}
+	_ HistoryRepo = &BzrRepo{}
+	_ StatusRepo  = &BzrRepo{}
+)
 
 // TestBzrDeprecationWarning tests that a deprecation warning is logged when creating a BzrRepo
 func TestBzrDeprecationWarning(t *testing.T) {
@@ -327,3 +337,173 @@ func TestBzrInit(t *testing.T) {
 		t.Errorf("Bzr Init returns wrong version: %s", v)
 	}
 }
+
+func TestParseBzrStatus(t *testing.T) {
+	status := " M  a.txt\n+N  b.txt\n-D  c.txt\n D  d.txt\nR   e.txt => f.txt\n?   g.txt\n?   dir/\nC   Text conflict in h.txt\nP   author@example.com-20150729134639-9f8e7d6c5b4a3921\n"
+	entries := parseBzrStatus([]byte(status), []byte("build.log\nout/\n"))
+	expected := []StatusEntry{
+		{Path: "a.txt", Status: FileModified},
+		{Path: "b.txt", Status: FileAdded},
+		{Path: "build.log", Status: FileIgnored},
+		{Path: "c.txt", Status: FileDeleted},
+		{Path: "d.txt", Status: FileMissing},
+		{Path: "dir", Status: FileUntracked},
+		{Path: "f.txt", OrigPath: "e.txt", Status: FileRenamed},
+		{Path: "g.txt", Status: FileUntracked},
+		{Path: "h.txt", Status: FileConflicted},
+		{Path: "out", Status: FileIgnored},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+}
+
+func TestParseBzrLs(t *testing.T) {
+	out := "dir/b.txt\ndir/link@\ndir/sub/\n"
+	tests := []struct {
+		path     string
+		expected *FileInfo
+	}{
+		{"dir/b.txt", &FileInfo{Path: "dir/b.txt", Mode: 0644}},
+		{"dir/link", &FileInfo{Path: "dir/link", Mode: fs.ModeSymlink | 0777}},
+		{"dir/sub", &FileInfo{Path: "dir/sub", Mode: fs.ModeDir | 0755}},
+		{"dir/c.txt", nil},
+	}
+	for _, tc := range tests {
+		fi, _ := parseBzrLs(out, tc.path)
+		if !reflect.DeepEqual(fi, tc.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tc.path, tc.expected, fi)
+		}
+	}
+}
+
+func TestParseBzrDiff(t *testing.T) {
+	d, err := ParseDiff(strings.NewReader(`=== added directory 'dir'
+=== added file 'dir/b.txt'
+--- dir/b.txt	1970-01-01 00:00:00 +0000
++++ dir/b.txt	2015-07-29 13:46:39 +0000
+@@ -0,0 +1,1 @@
++b
+
+=== modified file 'a.txt' (properties changed: -x to +x)
+--- a.txt	2015-07-29 13:46:39 +0000
++++ a.txt	2015-07-29 14:46:39 +0000
+@@ -1,1 +1,1 @@
+-a
++c
+
+=== removed file 'c.txt'
+--- c.txt	2015-07-29 13:46:39 +0000
++++ c.txt	1970-01-01 00:00:00 +0000
+@@ -1,1 +0,0 @@
+-c
+
+=== renamed file 'd.txt' => 'e.txt'
+=== modified file 'bin.dat'
+Binary files bin.dat	2015-07-29 13:46:39 +0000 and bin.dat	2015-07-29 14:46:39 +0000 differ
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fileSummary{
+		{"", "dir/b.txt", FileAdded, false, 1, 0},
+		{"a.txt", "a.txt", FileModified, false, 1, 1},
+		{"c.txt", "", FileDeleted, false, 0, 1},
+		{"d.txt", "e.txt", FileRenamed, false, 0, 0},
+		{"bin.dat", "bin.dat", FileModified, true, 0, 0},
+	}
+	if got := summarize(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestBzrLogFilters(t *testing.T) {
+	loc := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = loc })
+
+	entry := func(revno, timestamp string) string {
+		return "------------------------------------------------------------\nrevno: " + revno +
+			"\ncommitter: Test Author <author@example.com>\ntimestamp: " + timestamp + "\nmessage:\n  Commit\n"
+	}
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"bzr log -r-1 --log-format=long --show-ids": {out: entry("3", "Wed 2015-07-29 15:00:00 +0000")},
+		"bzr log --log-format=long --show-ids -n1 -rdate:2015-07-29,13:46:38..date:2015-07-29,14:46:39 -l 4": {
+			out: entry("3", "Wed 2015-07-29 15:00:00 +0000") + entry("2", "Wed 2015-07-29 14:00:00 +0000"),
+		},
+	}}
+	repo := &BzrRepo{}
+	repo.setLocalPath(t.TempDir())
+	repo.Runner = f
+
+	// The revision after Until is not listed.
+	since := time.Date(2015, 7, 29, 13, 46, 39, 0, time.UTC)
+	var got []string
+	for ci, err := range repo.Log(LogOptions{Since: since, Until: since.Add(time.Hour), Max: 2, FirstParent: true}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ci.Commit)
+	}
+	if !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("expected revision 2, got %v", got)
+	}
+
+	// A window after the tip has no commits.
+	f.calls = nil
+	for _, err := range repo.Log(LogOptions{Since: since.Add(2 * time.Hour)}) {
+		t.Errorf("expected no commits, got %v", err)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected only the tip to be read, got %d commands", len(f.calls))
+	}
+}
+
+func TestBzrReadFile(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"bzr ls --versioned -r3 -- dir": {out: "dir/b.txt\ndir/sub/\n"},
+		"bzr cat -r3 -- dir/b.txt":      {out: "b\n"},
+	}}
+	repo := &BzrRepo{}
+	repo.setLocalPath(t.TempDir())
+	repo.Runner = f
+
+	fi, err := repo.Stat("3", "dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (FileInfo{Path: "dir/b.txt", Size: 2, Mode: 0644}); *fi != expected {
+		t.Errorf("expected %+v, got %+v", expected, *fi)
+	}
+
+	// ReadFile reads the file once, without the size Stat counts.
+	f.calls = nil
+	r, err := repo.ReadFile("3", "dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "b\n" {
+		t.Errorf("expected %q, got %q", "b\n", data)
+	}
+	var cats int
+	for _, c := range f.calls {
+		if c.Args[0] == "cat" {
+			cats++
+		}
+	}
+	if cats != 1 {
+		t.Errorf("expected bzr cat to run once, got %d", cats)
+	}
+
+	if _, err := repo.ReadFile("3", "dir/sub"); err == nil {
+		t.Error("expected an error for a directory")
+	}
+	if _, err := repo.Stat("3", "dir/c.txt"); err != ErrFileNotFound {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+}
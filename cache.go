@@ -0,0 +1,309 @@
+package vcs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps a mirror of each remote in a directory and creates working
+// copies from it, so repeated checkouts of the same remote fetch only what is
+// new. Remotes that differ only by scheme, user, a trailing slash, or a .git
+// extension share a mirror.
+//
+// Working copies are made from the mirrors as follows:
+//
+//   - Git clones from the remote with --reference to the mirror, borrowing its
+//     objects through alternates. Garbage collection is turned off in the
+//     mirror so that it never drops objects the working copies borrow.
+//   - Hg uses hg share so the working copy uses the store of the mirror, and
+//     then pulls from the remote into that store.
+//   - Bzr keeps the mirror as a shared repository without trees. Working
+//     copies are branches stacked on the branch in the mirror, so they only
+//     store the revisions they pull from the remote.
+//   - Svn has no local history so working copies are checked out from the
+//     remote as usual, as are the working copies of other VCS.
+//
+// Each working copy is at the latest revision of the remote, whether or not
+// the mirror has been refreshed. A mirror that is not refreshed only means
+// more is fetched for each working copy.
+//
+// Git, Hg, and Bzr working copies depend on their mirror. Remove them before
+// removing the cache. A Cache is safe for concurrent use but the cache
+// directory should not be shared between processes.
+type Cache struct {
+	// MaxAge is how long a mirror is used before Get refreshes it. When it
+	// is zero mirrors are only refreshed by calling Refresh.
+	MaxAge time.Duration
+
+	dir  string
+	opts []Option
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewCache returns a Cache that keeps its mirrors in dir. The opts are used
+// to detect the VCS of remotes and are passed to the constructor of each
+// repo.
+func NewCache(dir string, opts ...Option) *Cache {
+	return &Cache{
+		dir:   dir,
+		opts:  opts,
+		locks: map[string]*sync.Mutex{},
+	}
+}
+
+// Get creates a working copy of remote at local, which must not exist yet,
+// and returns the Repo for it. The mirror of remote is created first when the
+// cache does not have one. The VCS is detected from remote as NewRepo does.
+func (c *Cache) Get(remote, local string) (Repo, error) {
+	return c.GetContext(context.Background(), remote, local)
+}
+
+// GetContext is the context-aware version of Get.
+func (c *Cache) GetContext(ctx context.Context, remote, local string) (Repo, error) {
+	vtype, remote, err := detectRemote(ctx, newConfig(c.opts), remote)
+	if err != nil {
+		return nil, err
+	}
+
+	m := c.mirror(vtype, remote)
+	if m == nil {
+		repo, err := newRepoOfType(vtype, remote, local, c.opts)
+		if err != nil {
+			return nil, err
+		}
+		if rc, ok := repo.(RepoContext); ok {
+			return repo, rc.GetContext(ctx)
+		}
+		return repo, repo.Get()
+	}
+
+	unlock := c.lock(m.path)
+	fi, err := os.Stat(m.path)
+	switch {
+	case os.IsNotExist(err):
+		err = m.create(ctx)
+	case err == nil && c.MaxAge > 0 && time.Since(fi.ModTime()) > c.MaxAge:
+		err = m.refresh(ctx)
+	}
+	if err == nil {
+		err = m.checkout(ctx, local)
+	}
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return newRepoOfType(vtype, remote, local, c.opts)
+}
+
+// Refresh updates the mirror of remote from the remote. It does nothing when
+// the cache has no mirror of remote.
+func (c *Cache) Refresh(remote string) error {
+	return c.RefreshContext(context.Background(), remote)
+}
+
+// RefreshContext is the context-aware version of Refresh.
+func (c *Cache) RefreshContext(ctx context.Context, remote string) error {
+	vtype, remote, err := detectRemote(ctx, newConfig(c.opts), remote)
+	if err != nil {
+		return err
+	}
+	m := c.mirror(vtype, remote)
+	if m == nil {
+		return nil
+	}
+
+	unlock := c.lock(m.path)
+	defer unlock()
+	if _, err := os.Stat(m.path); os.IsNotExist(err) {
+		return nil
+	}
+	return m.refresh(ctx)
+}
+
+// lock serializes the use of the mirror at path.
+func (c *Cache) lock(path string) func() {
+	c.mu.Lock()
+	l, ok := c.locks[path]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[path] = l
+	}
+	c.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// mirror returns the mirror of remote or nil when the VCS is not mirrored.
+func (c *Cache) mirror(vtype Type, remote string) *mirror {
+	switch vtype {
+	case Git, Hg, Bzr:
+	default:
+		return nil
+	}
+
+	cfg := newConfig(c.opts)
+	sum := sha256.Sum256([]byte(string(vtype) + "\x00" + mirrorKey(remote)))
+	m := &mirror{
+		base:       base{remote: remote, vcsType: vtype, Logger: Logger},
+		path:       filepath.Join(c.dir, string(vtype), hex.EncodeToString(sum[:])),
+		remoteName: cfg.remoteName,
+	}
+	m.configure(cfg)
+	if m.remoteName == "" {
+		m.remoteName = "origin"
+	}
+	return m
+}
+
+// mirrorKey normalizes remote so that the different ways of writing the
+// location of a repo share a mirror.
+func mirrorKey(remote string) string {
+	u, err := parseRemote(remote)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(remote, "/")
+	}
+	p := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+	return strings.ToLower(u.Host) + "/" + strings.TrimPrefix(p, "/")
+}
+
+// mirror is the copy of a remote kept by a Cache.
+type mirror struct {
+	base
+
+	// path is the location of the mirror.
+	path string
+
+	// remoteName is the name Git working copies use for the remote.
+	remoteName string
+}
+
+// create creates the mirror from the remote.
+func (m *mirror) create(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return NewLocalError("Unable to create directory", err, "")
+	}
+
+	var out []byte
+	var err error
+	switch m.vcsType {
+	case Git:
+		// git gc, run automatically by remote update, could remove objects
+		// that are no longer referenced by the mirror but still used by the
+		// working copies that borrow them.
+		out, err = m.runContext(ctx, "git", "clone", "--mirror", "-c", "gc.auto=0", "-c", "gc.pruneExpire=never", "--", m.Remote(), m.path)
+	case Hg:
+		out, err = m.runContext(ctx, "hg", "clone", "--noupdate", "--", m.Remote(), m.path)
+	case Bzr:
+		out, err = m.runContext(ctx, "bzr", "init-repo", "--no-trees", "--", m.path)
+		if err == nil {
+			out, err = m.runContext(ctx, "bzr", "branch", "--no-tree", "--", m.Remote(), m.branch())
+		}
+	}
+	if err != nil {
+		_ = os.RemoveAll(m.path)
+		return NewRemoteError("Unable to get repository", err, string(out))
+	}
+	return nil
+}
+
+// refresh fetches what is new from the remote into the mirror.
+func (m *mirror) refresh(ctx context.Context) error {
+	var out []byte
+	var err error
+	switch m.vcsType {
+	case Git:
+		out, err = m.runContext(ctx, "git", "-C", m.path, "remote", "update", "--prune")
+	case Hg:
+		out, err = m.runContext(ctx, "hg", "pull", "-R", m.path, "--", m.Remote())
+	case Bzr:
+		out, err = m.runContext(ctx, "bzr", "pull", "--overwrite", "-d", m.branch(), "--", m.Remote())
+	}
+	if err != nil {
+		return NewRemoteError("Unable to update repository", err, string(out))
+	}
+
+	// The modification time of the mirror records when it was refreshed.
+	now := time.Now()
+	if err := os.Chtimes(m.path, now, now); err != nil {
+		return NewLocalError("Unable to update repository", err, "")
+	}
+	return nil
+}
+
+// checkout creates a working copy of the remote at local from the mirror.
+func (m *mirror) checkout(ctx context.Context, local string) error {
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return NewLocalError("Unable to create directory", err, "")
+	}
+
+	var out []byte
+	var err error
+	switch m.vcsType {
+	case Git:
+		args := []string{"clone", "--recursive", "--reference", m.path}
+		if m.remoteName != "origin" {
+			args = append(args, "--origin", m.remoteName)
+		}
+		out, err = m.runContext(ctx, "git", append(args, "--", m.Remote(), local)...)
+	case Hg:
+		out, err = m.runContext(ctx, "hg", "share", "--noupdate", "--", m.path, local)
+		if err == nil {
+			// hg share sets the default path to the mirror. Pulls should go
+			// to the remote, and still land in the shared store.
+			hgrc := "[paths]\ndefault = " + m.Remote() + "\n"
+			if werr := os.WriteFile(filepath.Join(local, ".hg", "hgrc"), []byte(hgrc), 0644); werr != nil {
+				return NewLocalError("Unable to configure repository", werr, "")
+			}
+			out, err = m.runContext(ctx, "hg", "pull", "-R", local, "--", m.Remote())
+		}
+		if err == nil {
+			out, err = m.runContext(ctx, "hg", "update", "-R", local)
+		}
+	case Bzr:
+		out, err = m.runContext(ctx, "bzr", "branch", "--stacked", "--", m.branch(), local)
+		if err == nil {
+			if werr := setBzrParent(local, m.Remote()); werr != nil {
+				return NewLocalError("Unable to configure repository", werr, "")
+			}
+			out, err = m.runContext(ctx, "bzr", "pull", "--overwrite", "-d", local, "--", m.Remote())
+		}
+	}
+	if err != nil {
+		return NewRemoteError("Unable to get repository", err, string(out))
+	}
+	return nil
+}
+
+// branch is the location of the branch in a Bzr mirror.
+func (m *mirror) branch() string {
+	return filepath.Join(m.path, "branch")
+}
+
+// setBzrParent points the parent branch of the Bzr branch at local to remote,
+// in place of the mirror it was branched from.
+func setBzrParent(local, remote string) error {
+	conf := filepath.Join(local, ".bzr", "branch", "branch.conf")
+	b, err := os.ReadFile(conf)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	for _, l := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		if l != "" && !strings.HasPrefix(strings.TrimSpace(l), "parent_location") {
+			lines = append(lines, l)
+		}
+	}
+	lines = append(lines, "parent_location = "+remote)
+	return os.WriteFile(conf, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
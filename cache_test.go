@@ -0,0 +1,109 @@
+package vcs_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/vcs"
+	"github.com/Masterminds/vcs/vcstest"
+)
+
+func TestCacheGit(t *testing.T) {
+	b := vcstest.Build(t, vcs.Git, vcstest.Spec{Commits: []vcstest.Commit{
+		{Files: map[string]string{"a.txt": "a\n"}, Tags: []string{"v1.0.0"}},
+	}})
+	cacheDir := t.TempDir()
+	cache := vcs.NewCache(cacheDir)
+
+	first, err := cache.Get(b.Remote, filepath.Join(t.TempDir(), "first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Vcs() != vcs.Git || first.Remote() != b.Remote {
+		t.Errorf("unexpected repo %s %s", first.Vcs(), first.Remote())
+	}
+	if v, err := first.Version(); err != nil || v != b.Revisions[0] {
+		t.Errorf("unexpected version %s %v", v, err)
+	}
+	alternates, err := os.ReadFile(filepath.Join(first.LocalPath(), ".git", "objects", "info", "alternates"))
+	if err != nil || !strings.HasPrefix(string(alternates), cacheDir) {
+		t.Errorf("working copy does not borrow from the mirror: %q %v", alternates, err)
+	}
+
+	// A commit made after the mirror was created is seen after a refresh.
+	cmd := exec.Command("git", "commit", "-q", "--allow-empty", "-m", "Later")
+	cmd.Dir = b.Dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	if err := cache.Refresh(b.Remote + "/"); err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.Get(b.Remote+"/", filepath.Join(t.TempDir(), "second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci, err := second.CommitInfo("HEAD"); err != nil || ci.Message != "Later" {
+		t.Errorf("refreshed commit not checked out: %v %v", ci, err)
+	}
+
+	mirrors, err := os.ReadDir(filepath.Join(cacheDir, "git"))
+	if err != nil || len(mirrors) != 1 {
+		t.Fatalf("expected the remotes to share one mirror, got %v %v", mirrors, err)
+	}
+
+	// Pruning the mirror could remove objects the working copies borrow.
+	out, err := exec.Command("git", "-C", filepath.Join(cacheDir, "git", mirrors[0].Name()), "config", "gc.pruneExpire").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "never" {
+		t.Errorf("expected the mirror to never prune objects, got %q %v", out, err)
+	}
+}
+
+// TestCacheLatest checks that working copies are at the latest revision of
+// the remote, as they are for Git, when the mirror is not refreshed.
+func TestCacheLatest(t *testing.T) {
+	for _, f := range []vcstest.Factory{vcstest.HgFactory(), vcstest.BzrFactory()} {
+		t.Run(string(f.Type), func(t *testing.T) {
+			b := vcstest.Build(t, f.Type, vcstest.Spec{Commits: []vcstest.Commit{
+				{Files: map[string]string{"a.txt": "a\n"}},
+			}})
+			cache := vcs.NewCache(t.TempDir())
+			if _, err := cache.Get(b.Remote, filepath.Join(t.TempDir(), "first")); err != nil {
+				t.Fatal(err)
+			}
+
+			var cmd *exec.Cmd
+			switch f.Type {
+			case vcs.Hg:
+				if err := os.WriteFile(filepath.Join(b.Dir, "b.txt"), []byte("b\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				cmd = exec.Command("hg", "commit", "-A", "-u", "a <a@example.com>", "-m", "Later")
+				cmd.Env = append(os.Environ(), "HGPLAIN=1", "HGRCPATH=")
+			case vcs.Bzr:
+				cmd = exec.Command("bzr", "commit", "-q", "--unchanged", "-m", "Later")
+				cmd.Env = append(os.Environ(), "BZR_EMAIL=a <a@example.com>", "BZR_HOME="+b.Dir)
+			}
+			cmd.Dir = b.Dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("%s: %s", err, out)
+			}
+
+			second, err := cache.Get(b.Remote, filepath.Join(t.TempDir(), "second"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := second.Version()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ci, err := second.CommitInfo(v); err != nil || ci.Message != "Later" {
+				t.Errorf("latest commit not checked out: %v %v", ci, err)
+			}
+		})
+	}
+}
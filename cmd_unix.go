@@ -0,0 +1,17 @@
+//go:build !windows
+
+package vcs
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup places the command in its own process group and, when the
+// command's context is done, kills the entire group.
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error {
+		return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+	}
+}
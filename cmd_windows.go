@@ -0,0 +1,15 @@
+//go:build windows
+
+package vcs
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a new process group. Windows has no
+// way to signal a whole group so, when the context is done, only the direct
+// child is killed. That is the default behavior of exec.CommandContext.
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
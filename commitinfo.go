@@ -0,0 +1,77 @@
+package vcs
+
+import (
+	"strings"
+)
+
+// Trailer is a key: value line at the end of a commit message, such as
+// "Signed-off-by: Name <email>".
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// splitPerson splits an identity in the form "Name <email>" into its name
+// and email. An identity without an email, such as a Svn user name, is
+// returned as the name.
+func splitPerson(s string) (name, email string) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndex(s, "<")
+	if i < 0 || !strings.HasSuffix(s, ">") {
+		return s, ""
+	}
+	return strings.TrimSpace(s[:i]), s[i+1 : len(s)-1]
+}
+
+// joinPerson is the inverse of splitPerson.
+func joinPerson(name, email string) string {
+	if email == "" {
+		return name
+	}
+	return name + " <" + email + ">"
+}
+
+// setMessage sets the full message of ci and the trailers found in it.
+func (ci *CommitInfo) setMessage(msg string) {
+	ci.Message = strings.TrimSpace(msg)
+	ci.Trailers = parseTrailers(ci.Message)
+}
+
+// parseTrailers returns the trailers of msg. As with git interpret-trailers
+// they are the last paragraph of a message with more than one paragraph,
+// when every line of it is a trailer or the indented continuation of one.
+func parseTrailers(msg string) []Trailer {
+	paras := strings.Split(strings.ReplaceAll(msg, "\r\n", "\n"), "\n\n")
+	if len(paras) < 2 {
+		return nil
+	}
+
+	var trailers []Trailer
+	for _, l := range strings.Split(strings.Trim(paras[len(paras)-1], "\n"), "\n") {
+		if len(trailers) > 0 && (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) {
+			t := &trailers[len(trailers)-1]
+			t.Value += " " + strings.TrimSpace(l)
+			continue
+		}
+		key, value, ok := strings.Cut(l, ":")
+		if !ok || !isTrailerKey(key) {
+			return nil
+		}
+		trailers = append(trailers, Trailer{Key: key, Value: strings.TrimSpace(value)})
+	}
+	return trailers
+}
+
+// isTrailerKey reports whether s is made of letters, digits, and hyphens and
+// starts with a letter or digit.
+func isTrailerKey(s string) bool {
+	if s == "" || s[0] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package vcs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTrailers(t *testing.T) {
+	tests := []struct {
+		msg      string
+		expected []Trailer
+	}{
+		{"Subject", nil},
+		{"Signed-off-by: A <a@example.com>", nil},
+		{"Subject\n\nBody text.", nil},
+		{
+			"Subject\n\nBody.\n\nSigned-off-by: A <a@example.com>\nReviewed-by: B <b@example.com>",
+			[]Trailer{{"Signed-off-by", "A <a@example.com>"}, {"Reviewed-by", "B <b@example.com>"}},
+		},
+		{
+			"Subject\n\nFixes: a long\n  description",
+			[]Trailer{{"Fixes", "a long description"}},
+		},
+		{"Subject\n\nSigned-off-by: A\nnot a trailer", nil},
+		{"Subject\n\nSee http://example.com", nil},
+	}
+	for _, tc := range tests {
+		if got := parseTrailers(tc.msg); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("parseTrailers(%q) = %v, expected %v", tc.msg, got, tc.expected)
+		}
+	}
+}
+
+func TestSplitPerson(t *testing.T) {
+	tests := []struct {
+		in, name, email string
+	}{
+		{"Test Author <author@example.com>", "Test Author", "author@example.com"},
+		{"mattfarina", "mattfarina", ""},
+		{"<author@example.com>", "", "author@example.com"},
+	}
+	for _, tc := range tests {
+		name, email := splitPerson(tc.in)
+		if name != tc.name || email != tc.email {
+			t.Errorf("splitPerson(%q) = %q, %q", tc.in, name, email)
+		}
+		if got := joinPerson(name, email); tc.name != "" && got != tc.in {
+			t.Errorf("joinPerson(%q, %q) = %q", name, email, got)
+		}
+	}
+}
+
+func TestParseBzrLog(t *testing.T) {
+	const out = `------------------------------------------------------------
+revno: 3 [merge]
+revision-id: c@example.com-3
+parent: c@example.com-2
+parent: a@example.com-m1
+committer: Committer <c@example.com>
+author: Author <a@example.com>, Other <o@example.com>
+branch nick: trunk
+timestamp: Thu 2015-07-30 10:00:00 -0400
+message:
+  Merge feature
+
+  Signed-off-by: Author <a@example.com>
+    ------------------------------------------------------------
+    revno: 1.1.1
+    revision-id: a@example.com-m1
+    parent: c@example.com-1
+    committer: Author <a@example.com>
+    branch nick: feature
+    timestamp: Wed 2015-07-29 12:00:00 +0200
+    message:
+      Feature
+------------------------------------------------------------
+Use --include-merged or -n0 to see merged revisions.
+`
+	var cis []*CommitInfo
+	err := parseBzrLog(strings.NewReader(out), func(ci *CommitInfo) bool {
+		cis = append(cis, ci)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cis) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(cis))
+	}
+
+	ci := cis[0]
+	if ci.Commit != "3" || ci.Author != "Author <a@example.com>" || ci.CommitterName != "Committer" {
+		t.Errorf("unexpected commit %+v", ci)
+	}
+	if !reflect.DeepEqual(ci.Parents, []string{"revid:c@example.com-2", "revid:a@example.com-m1"}) {
+		t.Errorf("unexpected parents %v", ci.Parents)
+	}
+	if ci.Message != "Merge feature\n\nSigned-off-by: Author <a@example.com>" || len(ci.Trailers) != 1 {
+		t.Errorf("unexpected message %q", ci.Message)
+	}
+
+	ci = cis[1]
+	if ci.Commit != "1.1.1" || ci.Message != "Feature" || ci.AuthorName != "Author" {
+		t.Errorf("unexpected commit %+v", ci)
+	}
+	if _, offset := ci.Date.Zone(); offset != 2*60*60 {
+		t.Errorf("expected the time zone to be kept, got %s", ci.Date)
+	}
+}
@@ -0,0 +1,27 @@
+package vcs_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Masterminds/vcs/vcstest"
+)
+
+func TestGitConformance(t *testing.T) {
+	vcstest.RunConformance(t, vcstest.GitFactory())
+}
+
+func TestHgConformance(t *testing.T) {
+	vcstest.RunConformance(t, vcstest.HgFactory())
+}
+
+func TestSvnConformance(t *testing.T) {
+	vcstest.RunConformance(t, vcstest.SvnFactory())
+}
+
+func TestBzrConformance(t *testing.T) {
+	if os.Getenv("SKIP_BZR") == "true" {
+		t.Skip("Skipping bzr tests")
+	}
+	vcstest.RunConformance(t, vcstest.BzrFactory())
+}
@@ -0,0 +1,397 @@
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DiffRepo is the optional interface of repos that can compare revisions.
+//
+// The from and to revisions select what is compared. An empty from is the
+// checked out revision and an empty to is the working copy, so
+// Diff("", "") lists the uncommitted changes. Files that are not under
+// version control are not included. The paths, relative to the root of the
+// working copy, limit the diff to those files and directories.
+type DiffRepo interface {
+	// Diff returns the parsed differences between from and to.
+	Diff(from, to string, paths ...string) (*Diff, error)
+
+	// DiffContext is the context-aware version of Diff.
+	DiffContext(ctx context.Context, from, to string, paths ...string) (*Diff, error)
+
+	// WriteDiff writes the unified diff between from and to to w as the VCS
+	// produces it. Git, Hg, and Svn use the git extended format.
+	WriteDiff(w io.Writer, from, to string, paths ...string) error
+
+	// WriteDiffContext is the context-aware version of WriteDiff.
+	WriteDiffContext(ctx context.Context, w io.Writer, from, to string, paths ...string) error
+}
+
+// FileStatus describes how a file changed.
+type FileStatus string
+
+// The ways a file can change between revisions.
+const (
+	FileAdded    FileStatus = "added"
+	FileModified FileStatus = "modified"
+	FileDeleted  FileStatus = "deleted"
+	FileRenamed  FileStatus = "renamed"
+)
+
+// Diff is a parsed unified diff.
+type Diff struct {
+	Files []*FileDiff
+}
+
+// Additions returns the number of lines added to all the files.
+func (d *Diff) Additions() int {
+	n := 0
+	for _, f := range d.Files {
+		n += f.Additions
+	}
+	return n
+}
+
+// Deletions returns the number of lines deleted from all the files.
+func (d *Diff) Deletions() int {
+	n := 0
+	for _, f := range d.Files {
+		n += f.Deletions
+	}
+	return n
+}
+
+// FileDiff is the change to a single file.
+type FileDiff struct {
+	// OldPath and NewPath are the slash separated paths of the file before
+	// and after the change. OldPath is empty for an added file and NewPath
+	// is empty for a deleted one.
+	OldPath, NewPath string
+
+	Status FileStatus
+
+	// Binary is true for files whose content the VCS does not show, in which
+	// case there are no hunks.
+	Binary bool
+
+	Hunks []*Hunk
+
+	// The number of lines added and deleted
+	Additions, Deletions int
+}
+
+// Path returns the path of the file after the change, or before it for a
+// deleted file.
+func (f *FileDiff) Path() string {
+	if f.NewPath == "" {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// Hunk is a contiguous region of changes to a file.
+type Hunk struct {
+	// The first line and the number of lines of the region before and after
+	// the change, as in "@@ -OldStart,OldLines +NewStart,NewLines @@".
+	OldStart, OldLines, NewStart, NewLines int
+
+	// Section is the text after the range, such as the enclosing function.
+	Section string
+
+	// Lines are the lines of the hunk, each starting with ' ', '+', or '-',
+	// or '\' for a "\ No newline at end of file" marker.
+	Lines []string
+}
+
+// ParseDiff parses a unified diff. It understands the git extended format, as
+// produced by Git and by Hg and Svn with --git, the headers of Svn and Bzr,
+// and plain unified diffs.
+func ParseDiff(r io.Reader) (*Diff, error) {
+	p := &diffParser{d: &Diff{}}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		p.line(sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	p.finish()
+	return p.d, nil
+}
+
+// diffParser holds the state of ParseDiff.
+type diffParser struct {
+	d   *Diff
+	cur *FileDiff
+
+	// git is true when the paths of the current file have a/ and b/
+	// prefixes.
+	git bool
+
+	// index is true when the current file was started by a Svn "Index:" line
+	// and no other header has been seen.
+	index bool
+
+	// hunk is the current hunk and oldLeft and newLeft the number of its
+	// lines still to be read.
+	hunk             *Hunk
+	oldLeft, newLeft int
+}
+
+func (p *diffParser) line(l string) {
+	if p.hunk != nil && (p.oldLeft > 0 || p.newLeft > 0) {
+		p.hunkLine(l)
+		return
+	}
+	if p.hunk != nil && strings.HasPrefix(l, `\`) {
+		p.hunk.Lines = append(p.hunk.Lines, l)
+		return
+	}
+	p.hunk = nil
+
+	switch {
+	case strings.HasPrefix(l, "diff --git "):
+		if !p.index {
+			p.start()
+		}
+		p.index = false
+		p.git = true
+		p.cur.OldPath, p.cur.NewPath = splitGitDiffPaths(strings.TrimPrefix(l, "diff --git "))
+	case strings.HasPrefix(l, "Index: "):
+		p.start()
+		p.index = true
+		p.cur.OldPath = strings.TrimPrefix(l, "Index: ")
+		p.cur.NewPath = p.cur.OldPath
+	case strings.HasPrefix(l, "=== "):
+		p.bzrHeader(strings.TrimPrefix(l, "=== "))
+	case p.cur != nil && (strings.HasPrefix(l, "new file mode ") || strings.HasPrefix(l, "copy to ")):
+		p.cur.Status = FileAdded
+	case p.cur != nil && strings.HasPrefix(l, "deleted file mode "):
+		p.cur.Status = FileDeleted
+	case p.cur != nil && strings.HasPrefix(l, "rename from "):
+		p.cur.Status = FileRenamed
+		p.cur.OldPath = unquoteGitPath(strings.TrimPrefix(l, "rename from "))
+	case p.cur != nil && strings.HasPrefix(l, "rename to "):
+		p.cur.Status = FileRenamed
+		p.cur.NewPath = unquoteGitPath(strings.TrimPrefix(l, "rename to "))
+	case strings.HasPrefix(l, "--- "):
+		// A plain unified diff has no other header for the file.
+		if p.cur == nil || len(p.cur.Hunks) > 0 {
+			p.start()
+		}
+		if path, ok := p.headerPath(strings.TrimPrefix(l, "--- "), "a/"); ok {
+			p.cur.OldPath = path
+		} else if p.cur.Status == "" {
+			p.cur.Status = FileAdded
+		}
+	case p.cur != nil && strings.HasPrefix(l, "+++ "):
+		if path, ok := p.headerPath(strings.TrimPrefix(l, "+++ "), "b/"); ok {
+			p.cur.NewPath = path
+		} else if p.cur.Status == "" {
+			p.cur.Status = FileDeleted
+		}
+	case p.cur != nil && strings.HasPrefix(l, "@@ "):
+		p.startHunk(l)
+	case p.cur != nil && (strings.HasPrefix(l, "Binary files ") ||
+		strings.HasPrefix(l, "Binary file ") ||
+		l == "GIT binary patch" ||
+		strings.HasPrefix(l, "Cannot display: file marked as a binary type")):
+		p.cur.Binary = true
+	}
+}
+
+// start begins a new file.
+func (p *diffParser) start() {
+	p.finish()
+	p.cur = &FileDiff{}
+	p.git, p.index = false, false
+}
+
+// finish adds the current file to the diff.
+func (p *diffParser) finish() {
+	f := p.cur
+	if f == nil {
+		return
+	}
+	p.cur, p.hunk = nil, nil
+	switch {
+	case f.Status == "" && f.OldPath != f.NewPath:
+		f.Status = FileRenamed
+	case f.Status == "":
+		f.Status = FileModified
+	case f.Status == FileAdded:
+		f.OldPath = ""
+	case f.Status == FileDeleted:
+		f.NewPath = ""
+	}
+	p.d.Files = append(p.d.Files, f)
+}
+
+// bzrHeader handles the "=== " lines bzr writes before each file.
+func (p *diffParser) bzrHeader(h string) {
+	kind, rest, _ := strings.Cut(h, " ")
+	what, rest, _ := strings.Cut(rest, " ")
+	if what != "file" && what != "symlink" {
+		// Directories have no content to compare.
+		p.finish()
+		return
+	}
+	p.start()
+	rest, _, _ = strings.Cut(rest, " (properties changed")
+	oldPath, newPath, renamed := strings.Cut(rest, " => ")
+	oldPath = strings.Trim(oldPath, "'")
+	if renamed {
+		newPath = strings.Trim(newPath, "'")
+	} else {
+		newPath = oldPath
+	}
+	p.cur.OldPath, p.cur.NewPath = oldPath, newPath
+	switch kind {
+	case "added":
+		p.cur.Status = FileAdded
+	case "removed":
+		p.cur.Status = FileDeleted
+	case "renamed":
+		p.cur.Status = FileRenamed
+	}
+}
+
+// headerPath returns the path of a "---" or "+++" line, without the date or
+// revision that may follow a tab, or false for /dev/null.
+func (p *diffParser) headerPath(s, prefix string) (string, bool) {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	if s == "/dev/null" {
+		return "", false
+	}
+	if p.git {
+		s = strings.TrimPrefix(unquoteGitPath(s), prefix)
+	}
+	return s, true
+}
+
+// startHunk begins a hunk from its "@@ -a,b +c,d @@ section" header.
+func (p *diffParser) startHunk(l string) {
+	rng, section, ok := strings.Cut(strings.TrimPrefix(l, "@@ "), " @@")
+	if !ok {
+		return
+	}
+	h := &Hunk{Section: strings.TrimPrefix(section, " ")}
+	for _, f := range strings.Fields(rng) {
+		start, count := parseHunkRange(f[1:])
+		switch f[0] {
+		case '-':
+			h.OldStart, h.OldLines = start, count
+		case '+':
+			h.NewStart, h.NewLines = start, count
+		}
+	}
+	p.cur.Hunks = append(p.cur.Hunks, h)
+	p.hunk, p.oldLeft, p.newLeft = h, h.OldLines, h.NewLines
+}
+
+// parseHunkRange parses "start,count" where count defaults to 1.
+func parseHunkRange(s string) (start, count int) {
+	a, b, ok := strings.Cut(s, ",")
+	start, _ = strconv.Atoi(a)
+	count = 1
+	if ok {
+		count, _ = strconv.Atoi(b)
+	}
+	return start, count
+}
+
+// hunkLine adds a line to the current hunk.
+func (p *diffParser) hunkLine(l string) {
+	if l == "" {
+		// Some tools strip the space from empty context lines.
+		l = " "
+	}
+	switch l[0] {
+	case '+':
+		p.newLeft--
+		p.cur.Additions++
+	case '-':
+		p.oldLeft--
+		p.cur.Deletions++
+	case '\\':
+	default:
+		p.oldLeft--
+		p.newLeft--
+	}
+	p.hunk.Lines = append(p.hunk.Lines, l)
+}
+
+// splitGitDiffPaths splits the "a/old b/new" of a diff --git line. When the
+// paths contain spaces the split is ambiguous. The paths are then taken to
+// be the same, as they are unless the file was renamed, in which case the
+// rename lines that follow give the paths.
+func splitGitDiffPaths(s string) (oldPath, newPath string) {
+	// Either path can be quoted, in which case the split is not ambiguous.
+	if q, err := strconv.QuotedPrefix(s); err == nil {
+		a := unquoteGitPath(q)
+		b := unquoteGitPath(strings.TrimPrefix(s[len(q):], " "))
+		return strings.TrimPrefix(a, "a/"), strings.TrimPrefix(b, "b/")
+	}
+	if i := strings.LastIndex(s, ` "b/`); i >= 0 && strings.HasSuffix(s, `"`) {
+		return strings.TrimPrefix(s[:i], "a/"), strings.TrimPrefix(unquoteGitPath(s[i+1:]), "b/")
+	}
+	if n := len(s); n%2 == 1 {
+		a, b := s[:n/2], s[n/2+1:]
+		if strings.HasPrefix(a, "a/") && strings.HasPrefix(b, "b/") && a[2:] == b[2:] {
+			return a[2:], b[2:]
+		}
+	}
+	a, b, _ := strings.Cut(s, " b/")
+	return strings.TrimPrefix(a, "a/"), b
+}
+
+// unquoteGitPath returns the path s, which Git puts in double quotes with C
+// escapes when it has bytes other than printable ASCII, such as non-ASCII
+// letters, tabs, or quotes.
+func unquoteGitPath(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// writeDiff runs a diff command in the local directory, writing its output to
+// w. bzr diff exits with 1 when there are differences.
+func (b *base) writeDiff(ctx context.Context, w io.Writer, cmd string, args ...string) error {
+	stderr := &cappedBuffer{}
+	err := b.exec(ctx, &Command{
+		Name:   cmd,
+		Args:   args,
+		Dir:    b.local,
+		Env:    envForDir(b.local),
+		Stdout: w,
+		Stderr: stderr,
+	})
+	if err != nil && !(cmd == "bzr" && exitCode(err) == 1) {
+		return NewLocalError("Unable to retrieve diff", err, stderr.String())
+	}
+	return nil
+}
+
+// diff runs write and parses the diff it writes.
+func diff(ctx context.Context, write func(context.Context, io.Writer) error) (*Diff, error) {
+	var buf bytes.Buffer
+	if err := write(ctx, &buf); err != nil {
+		return nil, err
+	}
+	d, err := ParseDiff(&buf)
+	if err != nil {
+		return nil, NewLocalError("Unable to parse diff", err, "")
+	}
+	return d, nil
+}
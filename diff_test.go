@@ -0,0 +1,138 @@
+package vcs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fileSummary is the part of a FileDiff compared by the tests.
+type fileSummary struct {
+	OldPath, NewPath     string
+	Status               FileStatus
+	Binary               bool
+	Additions, Deletions int
+}
+
+func summarize(d *Diff) []fileSummary {
+	var s []fileSummary
+	for _, f := range d.Files {
+		s = append(s, fileSummary{f.OldPath, f.NewPath, f.Status, f.Binary, f.Additions, f.Deletions})
+	}
+	return s
+}
+
+func TestParseDiffHunks(t *testing.T) {
+	d, err := ParseDiff(strings.NewReader(`--- a.txt
++++ a.txt
+@@ -1,2 +1,2 @@ section
+-a
++b
+ c
+@@ -10 +10,2 @@
+ x
++y
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Files) != 1 || len(d.Files[0].Hunks) != 2 {
+		t.Fatalf("unexpected diff %+v", d.Files)
+	}
+	h := d.Files[0].Hunks[0]
+	expected := &Hunk{OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2, Section: "section", Lines: []string{"-a", "+b", " c"}}
+	if !reflect.DeepEqual(h, expected) {
+		t.Errorf("expected %+v, got %+v", expected, h)
+	}
+	h = d.Files[0].Hunks[1]
+	expected = &Hunk{OldStart: 10, OldLines: 1, NewStart: 10, NewLines: 2, Lines: []string{" x", "+y"}}
+	if !reflect.DeepEqual(h, expected) {
+		t.Errorf("expected %+v, got %+v", expected, h)
+	}
+	if d.Additions() != 2 || d.Deletions() != 1 {
+		t.Errorf("expected 2 additions and 1 deletion, got %d and %d", d.Additions(), d.Deletions())
+	}
+}
+
+func TestGitDiff(t *testing.T) {
+	repo, ids := newGitTestRepo(t,
+		gitTestCommit{Files: map[string]string{"a.txt": "a\nb\n", "c.txt": "c\n"}},
+		gitTestCommit{Files: map[string]string{"a.txt": "a\nc\nd\n", "bin.dat": "\x00\x01\x02"}},
+	)
+
+	d, err := repo.Diff(ids[0], ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fileSummary{
+		{"a.txt", "a.txt", FileModified, false, 2, 1},
+		{"", "bin.dat", FileAdded, true, 0, 0},
+	}
+	if got := summarize(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	d, err = repo.Diff(ids[0], ids[1], "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Files) != 1 || d.Files[0].Path() != "a.txt" {
+		t.Errorf("expected only a.txt, got %+v", summarize(d))
+	}
+
+	d, err = repo.Diff("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Files) != 0 {
+		t.Errorf("expected a clean working copy, got %+v", summarize(d))
+	}
+
+	if err := os.WriteFile(filepath.Join(repo.LocalPath(), "c.txt"), []byte("c\ne\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err = repo.Diff("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []fileSummary{{"c.txt", "c.txt", FileModified, false, 1, 0}}
+	if got := summarize(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := repo.WriteDiff(&buf, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\n+e\n") {
+		t.Errorf("unexpected diff %q", buf.String())
+	}
+
+	if _, err := repo.Diff("does-not-exist", ""); err == nil {
+		t.Error("expected an error for an unknown revision")
+	}
+}
+
+// TestGitDiffQuotedPaths checks the paths Git quotes, or writes with a tab
+// after them when they have spaces, are read as the paths of the files.
+func TestGitDiffQuotedPaths(t *testing.T) {
+	repo, ids := newGitTestRepo(t,
+		gitTestCommit{Files: map[string]string{"héllo wörld.txt": "a\n", "with space.txt": "a\n"}},
+		gitTestCommit{Files: map[string]string{"héllo wörld.txt": "b\n", "with space.txt": "b\n"}},
+	)
+
+	d, err := repo.Diff(ids[0], ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fileSummary{
+		{"héllo wörld.txt", "héllo wörld.txt", FileModified, false, 1, 1},
+		{"with space.txt", "with space.txt", FileModified, false, 1, 1},
+	}
+	if got := summarize(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
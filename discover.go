@@ -0,0 +1,95 @@
+package vcs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Discover finds every working copy in the directory tree rooted at root and
+// returns a Repo, with its remote read from the local configuration, for each
+// of them in lexical order of their paths. Working copies nested within
+// others, such as Git submodules, are included. Svn working copies created by
+// clients before 1.7, which have a .svn directory in every directory, are
+// reported once for their root. Symbolic links are not followed.
+//
+// The opts are passed to the constructor for each repo. Use WithWorkers to
+// create the repos concurrently. When some of the repos cannot be created the
+// others are still returned along with an error describing the failures.
+func Discover(root string, opts ...Option) ([]Repo, error) {
+	// Metadata directories are skipped. Git uses a file of the same name for
+	// submodules and worktrees.
+	metadataDirs := map[string]bool{}
+	for _, b := range registeredBackends() {
+		if b.MetadataDir != "" {
+			metadataDirs[b.MetadataDir] = true
+		}
+	}
+
+	var roots []string
+	var types []Type
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if metadataDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+
+		t, err := DetectVcsFromFS(path)
+		if err != nil {
+			return nil
+		}
+		if t == Svn && path != root {
+			if _, err := os.Stat(filepath.Join(filepath.Dir(path), ".svn")); err == nil {
+				return nil
+			}
+		}
+		roots = append(roots, path)
+		types = append(types, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workers := newConfig(opts).workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	repos := make([]Repo, len(roots))
+	errs := make([]error, len(roots))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(roots)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				repos[i], errs[i] = newRepoOfType(types[i], "", roots[i], opts)
+			}
+		}()
+	}
+	for i := range roots {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var found []Repo
+	var failed []error
+	for i, r := range repos {
+		if errs[i] != nil {
+			failed = append(failed, NewLocalError("Unable to open "+roots[i], errs[i], ""))
+			continue
+		}
+		found = append(found, r)
+	}
+	return found, errors.Join(failed...)
+}
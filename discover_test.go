@@ -0,0 +1,72 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{
+		"git1/.git",
+		"git1/src",
+		"hg1/.hg",
+		"svn1/.svn",
+		"svn1/sub/.svn",
+		"bzr1/.bzr",
+		"plain/dir",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(p)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Git submodules have a .git file rather than a directory.
+	mod := filepath.Join(root, "git1", "vendor", "mod")
+	if err := os.MkdirAll(mod, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mod, ".git"), []byte("gitdir: ../../.git/modules/mod\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	svn1 := filepath.Join(root, "svn1")
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"git config --get remote.origin.url": {out: "https://example.com/foo.git\n"},
+		"hg paths":                           {out: "default = https://example.com/hg/foo\n"},
+		"svn info -- " + svn1:                {out: "URL: https://example.com/svn/foo/trunk\n"},
+		"bzr info":                           {out: "  parent branch: https://example.com/bzr/foo\n"},
+	}}
+
+	repos, err := Discover(root, WithRunner(f), WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range repos {
+		rel, _ := filepath.Rel(root, r.LocalPath())
+		got = append(got, string(r.Vcs())+" "+filepath.ToSlash(rel)+" "+r.Remote())
+	}
+	expected := []string{
+		"bzr bzr1 https://example.com/bzr/foo",
+		"git git1 https://example.com/foo.git",
+		"git git1/vendor/mod https://example.com/foo.git",
+		"hg hg1 https://example.com/hg/foo",
+		"svn svn1 https://example.com/svn/foo/trunk",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected repos discovered:\n%s", strings.Join(got, "\n"))
+	}
+
+	// A repo that cannot be opened is reported without losing the others.
+	delete(f.responses, "hg paths")
+	repos, err = Discover(root, WithRunner(f))
+	if err == nil || !strings.Contains(err.Error(), "hg1") {
+		t.Errorf("expected an error for hg1, got %v", err)
+	}
+	if len(repos) != 4 {
+		t.Errorf("expected the other 4 repos, got %d", len(repos))
+	}
+}
@@ -56,6 +56,17 @@ var (
 	// ErrRevisionUnavailable happens when commit revision information is
 	// unavailable.
 	ErrRevisionUnavailable = errors.New("revision unavailable")
+
+	// ErrFileNotFound is returned when a path does not exist at a revision.
+	ErrFileNotFound = errors.New("file not found")
+
+	// ErrInvalidModulePath is returned when a Go module path has an invalid
+	// major version suffix, such as example.com/foo/v1.
+	ErrInvalidModulePath = errors.New("invalid module path")
+
+	// ErrUnsupported is returned when a repo cannot perform an operation, such
+	// as building a pseudo-version without implementing AncestorTagger.
+	ErrUnsupported = errors.New("operation not supported by the VCS")
 )
 
 // RemoteError is returned when an operation fails against a remote repo
@@ -108,6 +119,13 @@ func (e *vcsError) Original() error {
 	return e.e
 }
 
+// Unwrap returns the underlying error so errors.Is and errors.As can inspect
+// it. For example, errors.Is(err, context.DeadlineExceeded) reports if an
+// operation failed because its deadline was hit.
+func (e *vcsError) Unwrap() error {
+	return e.e
+}
+
 // Out retrieves the output of the original command that was run.
 func (e *vcsError) Out() string {
 	return e.o
@@ -0,0 +1,126 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// FileRepo is the optional interface of repos that can read files at any
+// revision without changing the working copy.
+//
+// Paths are slash separated and relative to the root of the repo. An empty
+// rev is the checked out revision. When rev does not exist
+// ErrRevisionUnavailable is returned, and when path does not exist at rev
+// ErrFileNotFound.
+type FileRepo interface {
+	// ReadFile returns the contents of the file at path in the revision
+	// rev. The contents are read from the VCS as the reader is read and
+	// closing it stops the VCS.
+	ReadFile(rev, path string) (io.ReadCloser, error)
+
+	// ReadFileContext is the context-aware version of ReadFile.
+	ReadFileContext(ctx context.Context, rev, path string) (io.ReadCloser, error)
+
+	// Stat describes the file or directory at path in the revision rev.
+	Stat(rev, path string) (*FileInfo, error)
+
+	// StatContext is the context-aware version of Stat.
+	StatContext(ctx context.Context, rev, path string) (*FileInfo, error)
+}
+
+// FileInfo describes a file or directory at a revision.
+type FileInfo struct {
+	// Path is the cleaned, slash separated path relative to the root of the
+	// repo. The root is ".".
+	Path string
+
+	// Size is the length of a file in bytes. It is zero for directories.
+	Size int64
+
+	// Mode holds the type of directories and symbolic links. Files have the
+	// permissions 0644, or 0755 when Git or Hg record them as executable.
+	Mode fs.FileMode
+}
+
+// IsDir reports whether fi describes a directory.
+func (fi *FileInfo) IsDir() bool {
+	return fi.Mode.IsDir()
+}
+
+// cleanRepoPath cleans a slash separated path relative to the root of a repo.
+// The root is returned as ".".
+func cleanRepoPath(p string) string {
+	if p = strings.TrimPrefix(path.Clean("/"+p), "/"); p == "" {
+		return "."
+	}
+	return p
+}
+
+// fileNotFound returns ErrRevisionUnavailable when rev is unknown to the VCS
+// and ErrFileNotFound otherwise. It is used when a command fails without
+// saying which of them is missing.
+func fileNotFound(ctx context.Context, commitInfo func(context.Context, string) (*CommitInfo, error), rev string) error {
+	if _, err := commitInfo(ctx, rev); err != nil {
+		if isContextErr(err) {
+			return err
+		}
+		return ErrRevisionUnavailable
+	}
+	return ErrFileNotFound
+}
+
+// readFile returns a reader for the file described by fi, whose contents are
+// written by the command, or an error when fi is a directory.
+func (b *base) readFile(ctx context.Context, fi *FileInfo, cmd string, args ...string) (io.ReadCloser, error) {
+	if fi.IsDir() {
+		return nil, NewLocalError("Unable to read file", fmt.Errorf("%s is a directory", fi.Path), "")
+	}
+	return b.readFromDir(ctx, "Unable to read file", cmd, args...), nil
+}
+
+// readFromDir runs a command in the local directory and returns its standard
+// output as it is produced. A failure of the command is returned, as a
+// LocalError with msg, by Read once the output ends. Closing the reader stops
+// the command.
+func (b *base) readFromDir(ctx context.Context, msg string, cmd string, args ...string) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stderr := &cappedBuffer{}
+		err := b.exec(ctx, &Command{
+			Name:   cmd,
+			Args:   args,
+			Dir:    b.local,
+			Env:    envForDir(b.local),
+			Stdout: pw,
+			Stderr: stderr,
+		})
+		if err != nil {
+			err = NewLocalError(msg, err, stderr.String())
+		}
+		pw.CloseWithError(err)
+	}()
+	return &commandReader{PipeReader: pr, cancel: cancel, done: done}
+}
+
+// commandReader reads the output of a command started by readFromDir.
+type commandReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Close stops the command, if it is still running, and waits for it to
+// exit.
+func (r *commandReader) Close() error {
+	err := r.PipeReader.Close()
+	r.cancel()
+	<-r.done
+	return err
+}
@@ -0,0 +1,81 @@
+package vcs
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+)
+
+func TestGitReadFile(t *testing.T) {
+	repo, ids := newGitTestRepo(t,
+		gitTestCommit{Files: map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n"}},
+		gitTestCommit{Files: map[string]string{"a.txt": "a\nc\n", "dir/sub/d.txt": "d\n"}},
+	)
+
+	read := func(rev, path string) string {
+		t.Helper()
+		r, err := repo.ReadFile(rev, path)
+		if err != nil {
+			t.Fatalf("%s at %q: %s", path, rev, err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s at %q: %s", path, rev, err)
+		}
+		return string(data)
+	}
+	if got := read(ids[0], "a.txt"); got != "a\n" {
+		t.Errorf("expected %q, got %q", "a\n", got)
+	}
+	if got := read("", "a.txt"); got != "a\nc\n" {
+		t.Errorf("expected %q, got %q", "a\nc\n", got)
+	}
+	if got := read(ids[1], "./dir/sub/d.txt"); got != "d\n" {
+		t.Errorf("expected %q, got %q", "d\n", got)
+	}
+
+	tests := []struct {
+		rev, path string
+		expected  FileInfo
+	}{
+		{ids[0], "a.txt", FileInfo{Path: "a.txt", Size: 2, Mode: 0644}},
+		{ids[1], "a.txt", FileInfo{Path: "a.txt", Size: 4, Mode: 0644}},
+		{ids[0], "dir/b.txt", FileInfo{Path: "dir/b.txt", Size: 2, Mode: 0644}},
+		{ids[1], "dir/sub/", FileInfo{Path: "dir/sub", Mode: 0755 | fs.ModeDir}},
+		{"", "dir", FileInfo{Path: "dir", Mode: 0755 | fs.ModeDir}},
+		{"", "", FileInfo{Path: ".", Mode: 0755 | fs.ModeDir}},
+	}
+	for _, tc := range tests {
+		fi, err := repo.Stat(tc.rev, tc.path)
+		if err != nil {
+			t.Errorf("%s at %q: %s", tc.path, tc.rev, err)
+			continue
+		}
+		if *fi != tc.expected {
+			t.Errorf("%s at %q: expected %+v, got %+v", tc.path, tc.rev, tc.expected, *fi)
+		}
+	}
+
+	if _, err := repo.Stat(ids[0], "dir/sub"); err != ErrFileNotFound {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+	if _, err := repo.ReadFile("", "missing.txt"); err != ErrFileNotFound {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+	if _, err := repo.ReadFile("does-not-exist", "a.txt"); err != ErrRevisionUnavailable {
+		t.Errorf("expected ErrRevisionUnavailable, got %v", err)
+	}
+	if _, err := repo.ReadFile("", "dir"); err == nil {
+		t.Error("expected an error for a directory")
+	}
+
+	// Closing the reader early stops the VCS.
+	r, err := repo.ReadFile("", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,21 +1,26 @@
 package vcs
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/xml"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // NewGitRepo creates a new instance of GitRepo. The remote and local directories
-// need to be passed in.
-func NewGitRepo(remote, local string) (*GitRepo, error) {
-	ins := depInstalled("git")
-	if !ins {
+// need to be passed in. Any opts are applied to the repo.
+func NewGitRepo(remote, local string, opts ...Option) (*GitRepo, error) {
+	c := newConfig(opts)
+	if !c.installed("git") {
 		return nil, NewLocalError("git is not installed", nil, "")
 	}
 	ltype, err := DetectVcsFromFS(local)
@@ -29,15 +34,17 @@ func NewGitRepo(remote, local string) (*GitRepo, error) {
 	r.setRemote(remote)
 	r.setLocalPath(local)
 	r.RemoteLocation = "origin"
+	if c.remoteName != "" {
+		r.RemoteLocation = c.remoteName
+	}
+	r.vcsType = Git
 	r.Logger = Logger
+	r.configure(c)
 
 	// Make sure the local Git repo is configured the same as the remote when
 	// A remote value was passed in.
 	if err == nil && r.CheckLocal() {
-		c := exec.Command("git", "config", "--get", "remote.origin.url")
-		c.Dir = local
-		c.Env = envForDir(c.Dir)
-		out, err := c.CombinedOutput()
+		out, err := r.RunFromDir("git", "config", "--get", "remote."+r.RemoteLocation+".url")
 		if err != nil {
 			return nil, NewLocalError("Unable to retrieve local repo information", err, string(out))
 		}
@@ -70,7 +77,17 @@ func (s GitRepo) Vcs() Type {
 
 // Get is used to perform an initial clone of a repository.
 func (s *GitRepo) Get() error {
-	out, err := s.run("git", "clone", "--recursive", "--", s.Remote(), s.LocalPath())
+	return s.GetContext(context.Background())
+}
+
+// GetContext is the context-aware version of Get.
+func (s *GitRepo) GetContext(ctx context.Context) error {
+	args := []string{"clone", "--recursive"}
+	if s.RemoteLocation != "origin" {
+		args = append(args, "--origin", s.RemoteLocation)
+	}
+	args = append(args, "--", s.Remote(), s.LocalPath())
+	out, err := s.runContext(ctx, "git", args...)
 
 	// There are some windows cases where Git cannot create the parent directory,
 	// if it does not already exist, to the location it's trying to create the
@@ -84,7 +101,7 @@ func (s *GitRepo) Get() error {
 				return NewLocalError("Unable to create directory", err, "")
 			}
 
-			out, err = s.run("git", "clone", "--recursive", "--", s.Remote(), s.LocalPath())
+			out, err = s.runContext(ctx, "git", args...)
 			if err != nil {
 				return NewRemoteError("Unable to get repository", err, string(out))
 			}
@@ -100,7 +117,12 @@ func (s *GitRepo) Get() error {
 
 // Init initializes a git repository at local location.
 func (s *GitRepo) Init() error {
-	out, err := s.run("git", "init", "--", s.LocalPath())
+	return s.InitContext(context.Background())
+}
+
+// InitContext is the context-aware version of Init.
+func (s *GitRepo) InitContext(ctx context.Context) error {
+	out, err := s.runContext(ctx, "git", "init", "--", s.LocalPath())
 
 	// There are some windows cases where Git cannot create the parent directory,
 	// if it does not already exist, to the location it's trying to create the
@@ -114,7 +136,7 @@ func (s *GitRepo) Init() error {
 				return NewLocalError("Unable to initialize repository", err, "")
 			}
 
-			out, err = s.run("git", "init", "--", s.LocalPath())
+			out, err = s.runContext(ctx, "git", "init", "--", s.LocalPath())
 			if err != nil {
 				return NewLocalError("Unable to initialize repository", err, string(out))
 			}
@@ -130,15 +152,20 @@ func (s *GitRepo) Init() error {
 
 // Update performs an Git fetch and pull to an existing checkout.
 func (s *GitRepo) Update() error {
+	return s.UpdateContext(context.Background())
+}
+
+// UpdateContext is the context-aware version of Update.
+func (s *GitRepo) UpdateContext(ctx context.Context) error {
 	// Perform a fetch to make sure everything is up to date.
-	out, err := s.RunFromDir("git", "fetch", "--tags", "--", s.RemoteLocation)
+	out, err := s.RunFromDirContext(ctx, "git", "fetch", "--tags", "--", s.RemoteLocation)
 	if err != nil {
 		return NewRemoteError("Unable to update repository", err, string(out))
 	}
 
 	// When in a detached head state, such as when an individual commit is checked
 	// out do not attempt a pull. It will cause an error.
-	detached, err := isDetachedHead(s.LocalPath())
+	detached, err := s.isDetachedHead(ctx)
 	if err != nil {
 		return NewLocalError("Unable to update repository", err, "")
 	}
@@ -147,40 +174,48 @@ func (s *GitRepo) Update() error {
 		return nil
 	}
 
-	out, err = s.RunFromDir("git", "pull")
+	out, err = s.RunFromDirContext(ctx, "git", "pull")
 	if err != nil {
 		return NewRemoteError("Unable to update repository", err, string(out))
 	}
 
-	return s.defendAgainstSubmodules()
+	return s.defendAgainstSubmodules(ctx)
 }
 
 // UpdateVersion sets the version of a package currently checked out via Git.
 func (s *GitRepo) UpdateVersion(version string) error {
-	out, err := s.RunFromDir("git", "checkout", version)
+	return s.UpdateVersionContext(context.Background(), version)
+}
+
+// UpdateVersionContext is the context-aware version of UpdateVersion.
+func (s *GitRepo) UpdateVersionContext(ctx context.Context, version string) error {
+	if !isGitRevision(version) {
+		return ErrRevisionUnavailable
+	}
+	out, err := s.RunFromDirContext(ctx, "git", "checkout", version)
 	if err != nil {
 		return NewLocalError("Unable to update checked out version", err, string(out))
 	}
 
-	return s.defendAgainstSubmodules()
+	return s.defendAgainstSubmodules(ctx)
 }
 
 // defendAgainstSubmodules tries to keep repo state sane in the event of
 // submodules. Or nested submodules. What a great idea, submodules.
-func (s *GitRepo) defendAgainstSubmodules() error {
+func (s *GitRepo) defendAgainstSubmodules(ctx context.Context) error {
 	// First, update them to whatever they should be, if there should happen to be any.
-	out, err := s.RunFromDir("git", "submodule", "update", "--init", "--recursive")
+	out, err := s.RunFromDirContext(ctx, "git", "submodule", "update", "--init", "--recursive")
 	if err != nil {
 		return NewLocalError("Unexpected error while defensively updating submodules", err, string(out))
 	}
 	// Now, do a special extra-aggressive clean in case changing versions caused
 	// one or more submodules to go away.
-	out, err = s.RunFromDir("git", "clean", "-x", "-d", "-f", "-f")
+	out, err = s.RunFromDirContext(ctx, "git", "clean", "-x", "-d", "-f", "-f")
 	if err != nil {
 		return NewLocalError("Unexpected error while defensively cleaning up after possible derelict submodule directories", err, string(out))
 	}
 	// Then, repeat just in case there are any nested submodules that went away.
-	out, err = s.RunFromDir("git", "submodule", "foreach", "--recursive", "git clean -x -d -f -f")
+	out, err = s.RunFromDirContext(ctx, "git", "submodule", "foreach", "--recursive", "git clean -x -d -f -f")
 	if err != nil {
 		return NewLocalError("Unexpected error while defensively cleaning up after possible derelict nested submodule directories", err, string(out))
 	}
@@ -190,7 +225,12 @@ func (s *GitRepo) defendAgainstSubmodules() error {
 
 // Version retrieves the current version.
 func (s *GitRepo) Version() (string, error) {
-	out, err := s.RunFromDir("git", "rev-parse", "HEAD")
+	return s.VersionContext(context.Background())
+}
+
+// VersionContext is the context-aware version of Version.
+func (s *GitRepo) VersionContext(ctx context.Context) (string, error) {
+	out, err := s.RunFromDirContext(ctx, "git", "rev-parse", "HEAD")
 	if err != nil {
 		return "", NewLocalError("Unable to retrieve checked out version", err, string(out))
 	}
@@ -203,18 +243,26 @@ func (s *GitRepo) Version() (string, error) {
 // * Tag if on a tag
 // * Otherwise a revision id
 func (s *GitRepo) Current() (string, error) {
-	out, err := s.RunFromDir("git", "symbolic-ref", "HEAD")
+	return s.CurrentContext(context.Background())
+}
+
+// CurrentContext is the context-aware version of Current.
+func (s *GitRepo) CurrentContext(ctx context.Context) (string, error) {
+	out, err := s.RunFromDirContext(ctx, "git", "symbolic-ref", "HEAD")
 	if err == nil {
 		o := bytes.TrimSpace(bytes.TrimPrefix(out, []byte("refs/heads/")))
 		return string(o), nil
 	}
+	if isContextErr(err) {
+		return "", err
+	}
 
-	v, err := s.Version()
+	v, err := s.VersionContext(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	ts, err := s.TagsFromCommit(v)
+	ts, err := s.TagsFromCommitContext(ctx, v)
 	if err != nil {
 		return "", err
 	}
@@ -228,7 +276,12 @@ func (s *GitRepo) Current() (string, error) {
 
 // Date retrieves the date on the latest commit.
 func (s *GitRepo) Date() (time.Time, error) {
-	out, err := s.RunFromDir("git", "log", "-1", "--date=iso", "--pretty=format:%cd")
+	return s.DateContext(context.Background())
+}
+
+// DateContext is the context-aware version of Date.
+func (s *GitRepo) DateContext(ctx context.Context) (time.Time, error) {
+	out, err := s.RunFromDirContext(ctx, "git", "log", "-1", "--date=iso", "--pretty=format:%cd")
 	if err != nil {
 		return time.Time{}, NewLocalError("Unable to retrieve revision date", err, string(out))
 	}
@@ -241,7 +294,12 @@ func (s *GitRepo) Date() (time.Time, error) {
 
 // Branches returns a list of available branches on the RemoteLocation
 func (s *GitRepo) Branches() ([]string, error) {
-	out, err := s.RunFromDir("git", "show-ref")
+	return s.BranchesContext(context.Background())
+}
+
+// BranchesContext is the context-aware version of Branches.
+func (s *GitRepo) BranchesContext(ctx context.Context) ([]string, error) {
+	out, err := s.RunFromDirContext(ctx, "git", "show-ref")
 	if err != nil {
 		return []string{}, NewLocalError("Unable to retrieve branches", err, string(out))
 	}
@@ -251,7 +309,12 @@ func (s *GitRepo) Branches() ([]string, error) {
 
 // Tags returns a list of available tags on the RemoteLocation
 func (s *GitRepo) Tags() ([]string, error) {
-	out, err := s.RunFromDir("git", "show-ref")
+	return s.TagsContext(context.Background())
+}
+
+// TagsContext is the context-aware version of Tags.
+func (s *GitRepo) TagsContext(ctx context.Context) ([]string, error) {
+	out, err := s.RunFromDirContext(ctx, "git", "show-ref")
 	if err != nil {
 		return []string{}, NewLocalError("Unable to retrieve tags", err, string(out))
 	}
@@ -271,66 +334,318 @@ func (s *GitRepo) CheckLocal() bool {
 // IsReference returns if a string is a reference. A reference can be a
 // commit id, branch, or tag.
 func (s *GitRepo) IsReference(r string) bool {
-	_, err := s.RunFromDir("git", "rev-parse", "--verify", r)
+	return s.IsReferenceContext(context.Background(), r)
+}
+
+// IsReferenceContext is the context-aware version of IsReference.
+func (s *GitRepo) IsReferenceContext(ctx context.Context, r string) bool {
+	if !isGitRevision(r) {
+		return false
+	}
+	_, err := s.RunFromDirContext(ctx, "git", "rev-parse", "--verify", r)
 	if err == nil {
 		return true
 	}
+	if isContextErr(err) {
+		return false
+	}
 
 	// Some refs will fail rev-parse. For example, a remote branch that has
 	// not been checked out yet. This next step should pickup the other
 	// possible references.
-	_, err = s.RunFromDir("git", "show-ref", r)
+	_, err = s.RunFromDirContext(ctx, "git", "show-ref", r)
 	return err == nil
 }
 
 // IsDirty returns if the checkout has been modified from the checked
-// out reference.
+// out reference. Untracked files only count when the repo was created with
+// WithCountUntracked.
 func (s *GitRepo) IsDirty() bool {
-	out, err := s.RunFromDir("git", "diff")
-	return err != nil || len(out) != 0
+	return s.IsDirtyContext(context.Background())
 }
 
-// CommitInfo retrieves metadata about a commit.
-func (s *GitRepo) CommitInfo(id string) (*CommitInfo, error) {
-	fm := `--pretty=format:"<logentry><commit>%H</commit><author>%an &lt;%ae&gt;</author><date>%aD</date><message>%s</message></logentry>"`
-	out, err := s.RunFromDir("git", "log", id, fm, "-1")
+// IsDirtyContext is the context-aware version of IsDirty.
+func (s *GitRepo) IsDirtyContext(ctx context.Context) bool {
+	// Listing untracked files can take long in a large working copy so git
+	// only looks for them when they count.
+	untracked := "no"
+	if s.countUntracked {
+		untracked = "all"
+	}
+	entries, err := s.status(ctx, StatusOptions{}, untracked)
+	return err != nil || isDirty(entries, s.countUntracked)
+}
+
+// Status lists the files in the working copy that differ from the checked
+// out revision. See StatusRepo for details.
+func (s *GitRepo) Status(opts StatusOptions) ([]StatusEntry, error) {
+	return s.StatusContext(context.Background(), opts)
+}
+
+// StatusContext is the context-aware version of Status.
+func (s *GitRepo) StatusContext(ctx context.Context, opts StatusOptions) ([]StatusEntry, error) {
+	return s.status(ctx, opts, "all")
+}
+
+// status lists the files as StatusContext does, with untracked passed to
+// --untracked-files to choose which untracked files are listed.
+func (s *GitRepo) status(ctx context.Context, opts StatusOptions, untracked string) ([]StatusEntry, error) {
+	args := []string{"status", "--porcelain=v2", "-z", "--untracked-files=" + untracked}
+	if opts.Ignored {
+		args = append(args, "--ignored")
+	}
+	out, stderr, err := s.outputFromDir(ctx, "git", args...)
 	if err != nil {
+		return nil, NewLocalError("Unable to retrieve status", err, stderr)
+	}
+	return parseGitStatus(out), nil
+}
+
+// parseGitStatus parses the output of git status --porcelain=v2 -z.
+func parseGitStatus(out []byte) []StatusEntry {
+	set := statusSet{}
+	recs := strings.Split(string(out), "\x00")
+	for i := 0; i < len(recs); i++ {
+		r := recs[i]
+		if len(r) < 3 {
+			continue
+		}
+		switch r[0] {
+		case '?':
+			set.add(StatusEntry{Path: r[2:], Status: FileUntracked})
+		case '!':
+			set.add(StatusEntry{Path: strings.TrimSuffix(r[2:], "/"), Status: FileIgnored})
+		case 'u':
+			if f := strings.SplitN(r, " ", 11); len(f) == 11 {
+				set.add(StatusEntry{Path: f[10], Status: FileConflicted})
+			}
+		case '1':
+			if f := strings.SplitN(r, " ", 9); len(f) == 9 {
+				set.add(StatusEntry{Path: f[8], Status: gitFileStatus(f[1], f[2])})
+			}
+		case '2':
+			// Renames and copies are followed by the original path.
+			if f := strings.SplitN(r, " ", 10); len(f) == 10 && i+1 < len(recs) {
+				i++
+				e := StatusEntry{Path: f[9], Status: gitFileStatus(f[1], f[2])}
+				if e.Status == FileRenamed {
+					e.OrigPath = recs[i]
+				}
+				set.add(e)
+			}
+		}
+	}
+	return set.entries()
+}
+
+// gitFileStatus returns the status of a changed entry from its XY field,
+// the status in the index and in the working tree, and its submodule field.
+func gitFileStatus(xy, sub string) FileStatus {
+	if len(xy) != 2 {
+		return FileModified
+	}
+	x, y := xy[0], xy[1]
+	switch {
+	case y == 'D':
+		return FileMissing
+	case x == 'A' || x == 'C' || y == 'A':
+		return FileAdded
+	case x == 'D':
+		return FileDeleted
+	case x == 'R':
+		return FileRenamed
+	case strings.HasPrefix(sub, "S"):
+		return FileExternal
+	}
+	return FileModified
+}
+
+// Diff returns the parsed differences between the revisions from and to.
+// See DiffRepo for their meaning.
+func (s *GitRepo) Diff(from, to string, paths ...string) (*Diff, error) {
+	return s.DiffContext(context.Background(), from, to, paths...)
+}
+
+// DiffContext is the context-aware version of Diff.
+func (s *GitRepo) DiffContext(ctx context.Context, from, to string, paths ...string) (*Diff, error) {
+	return diff(ctx, func(ctx context.Context, w io.Writer) error {
+		return s.WriteDiffContext(ctx, w, from, to, paths...)
+	})
+}
+
+// WriteDiff writes the unified diff between the revisions from and to to w.
+// See DiffRepo for their meaning.
+func (s *GitRepo) WriteDiff(w io.Writer, from, to string, paths ...string) error {
+	return s.WriteDiffContext(context.Background(), w, from, to, paths...)
+}
+
+// WriteDiffContext is the context-aware version of WriteDiff.
+func (s *GitRepo) WriteDiffContext(ctx context.Context, w io.Writer, from, to string, paths ...string) error {
+	if from == "" {
+		from = "HEAD"
+	}
+	if !isGitRevision(from) || (to != "" && !isGitRevision(to)) {
+		return ErrRevisionUnavailable
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--no-textconv", "-M", "--src-prefix=a/", "--dst-prefix=b/", from}
+	if to != "" {
+		args = append(args, to)
+	}
+	args = append(append(args, "--"), paths...)
+	return s.writeDiff(ctx, w, "git", args...)
+}
+
+// ReadFile returns the contents of the file at path in the revision rev. See
+// FileRepo for details.
+func (s *GitRepo) ReadFile(rev, path string) (io.ReadCloser, error) {
+	return s.ReadFileContext(context.Background(), rev, path)
+}
+
+// ReadFileContext is the context-aware version of ReadFile.
+func (s *GitRepo) ReadFileContext(ctx context.Context, rev, path string) (io.ReadCloser, error) {
+	fi, err := s.StatContext(ctx, rev, path)
+	if err != nil {
+		return nil, err
+	}
+	if rev == "" {
+		rev = "HEAD"
+	}
+	return s.readFile(ctx, fi, "git", "cat-file", "blob", rev+":"+fi.Path)
+}
+
+// Stat describes the file or directory at path in the revision rev. See
+// FileRepo for details.
+func (s *GitRepo) Stat(rev, path string) (*FileInfo, error) {
+	return s.StatContext(context.Background(), rev, path)
+}
+
+// StatContext is the context-aware version of Stat.
+func (s *GitRepo) StatContext(ctx context.Context, rev, path string) (*FileInfo, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	if !isGitRevision(rev) {
 		return nil, ErrRevisionUnavailable
 	}
+	path = cleanRepoPath(path)
+	if path == "." {
+		if err := fileNotFound(ctx, s.CommitInfoContext, rev); err != ErrFileNotFound {
+			return nil, err
+		}
+		return &FileInfo{Path: path, Mode: fs.ModeDir | 0755}, nil
+	}
+	out, _, err := s.outputFromDir(ctx, "git", "ls-tree", "-l", "-z", rev, "--", path)
+	if err != nil {
+		return nil, fileNotFound(ctx, s.CommitInfoContext, rev)
+	}
+	for _, e := range strings.Split(string(out), "\x00") {
+		if fi, ok := parseGitTreeEntry(e); ok && fi.Path == path {
+			return fi, nil
+		}
+	}
+	return nil, ErrFileNotFound
+}
+
+// parseGitTreeEntry parses an entry of git ls-tree -l, which is
+// "<mode> <type> <object> <size>\t<path>".
+func parseGitTreeEntry(e string) (*FileInfo, bool) {
+	meta, path, ok := strings.Cut(e, "\t")
+	f := strings.Fields(meta)
+	if !ok || len(f) != 4 {
+		return nil, false
+	}
+	fi := &FileInfo{Path: path}
+	switch f[0] {
+	case "040000", "160000":
+		// Submodules are directories in the working copy.
+		fi.Mode = fs.ModeDir | 0755
+	case "120000":
+		fi.Mode = fs.ModeSymlink | 0777
+	case "100755":
+		fi.Mode = 0755
+	default:
+		fi.Mode = 0644
+	}
+	if !fi.IsDir() {
+		fi.Size, _ = strconv.ParseInt(f[3], 10, 64)
+	}
+	return fi, true
+}
 
-	cis := struct {
-		Commit  string `xml:"commit"`
-		Author  string `xml:"author"`
-		Date    string `xml:"date"`
-		Message string `xml:"message"`
-	}{}
-	err = xml.Unmarshal(out, &cis)
+// CommitInfo retrieves metadata about a commit.
+func (s *GitRepo) CommitInfo(id string) (*CommitInfo, error) {
+	return s.CommitInfoContext(context.Background(), id)
+}
+
+// CommitInfoContext is the context-aware version of CommitInfo.
+func (s *GitRepo) CommitInfoContext(ctx context.Context, id string) (*CommitInfo, error) {
+	if !isGitRevision(id) {
+		return nil, ErrRevisionUnavailable
+	}
+	out, err := s.RunFromDirContext(ctx, "git", "log", "-1", "--format="+gitCommitFormat, id)
 	if err != nil {
-		return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
+		if isContextErr(err) {
+			return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
+		}
+		return nil, ErrRevisionUnavailable
 	}
 
-	t, err := time.Parse("Mon, _2 Jan 2006 15:04:05 -0700", cis.Date)
+	ci, err := parseGitCommit(string(out))
 	if err != nil {
 		return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
 	}
+	return ci, nil
+}
 
-	ci := &CommitInfo{
-		Commit:  cis.Commit,
-		Author:  cis.Author,
-		Date:    t,
-		Message: cis.Message,
-	}
+// isGitRevision reports whether rev can be passed to git as a revision. Git
+// refuses names starting with a dash and reads arguments starting with one
+// as options, so such a rev is never a revision.
+func isGitRevision(rev string) bool {
+	return !strings.HasPrefix(rev, "-")
+}
+
+// gitCommitFormat is the git log format read by parseGitCommit. The fields
+// are separated by NUL characters and the message is last.
+const gitCommitFormat = "%H%x00%P%x00%an%x00%ae%x00%aI%x00%cn%x00%ce%x00%cI%x00%B"
 
+// parseGitCommit parses a commit in the gitCommitFormat.
+func parseGitCommit(rec string) (*CommitInfo, error) {
+	f := strings.SplitN(strings.TrimLeft(rec, "\n"), "\x00", 9)
+	if len(f) != 9 {
+		return nil, fmt.Errorf("unexpected log entry %q", rec)
+	}
+	ci := &CommitInfo{
+		Commit:         f[0],
+		Parents:        strings.Fields(f[1]),
+		AuthorName:     f[2],
+		AuthorEmail:    f[3],
+		CommitterName:  f[5],
+		CommitterEmail: f[6],
+	}
+	ci.Author = joinPerson(ci.AuthorName, ci.AuthorEmail)
+	var err error
+	if ci.Date, err = time.Parse(time.RFC3339, f[4]); err != nil {
+		return nil, err
+	}
+	if ci.CommitDate, err = time.Parse(time.RFC3339, f[7]); err != nil {
+		return nil, err
+	}
+	ci.setMessage(f[8])
 	return ci, nil
 }
 
 // TagsFromCommit retrieves tags from a commit id.
 func (s *GitRepo) TagsFromCommit(id string) ([]string, error) {
+	return s.TagsFromCommitContext(context.Background(), id)
+}
+
+// TagsFromCommitContext is the context-aware version of TagsFromCommit.
+func (s *GitRepo) TagsFromCommitContext(ctx context.Context, id string) ([]string, error) {
 	// This is imperfect and a better method would be great.
 
 	var re []string
 
-	out, err := s.RunFromDir("git", "show-ref", "-d")
+	out, err := s.RunFromDirContext(ctx, "git", "show-ref", "-d")
 	if err != nil {
 		return []string{}, NewLocalError("Unable to retrieve tags", err, string(out))
 	}
@@ -351,15 +666,98 @@ func (s *GitRepo) TagsFromCommit(id string) ([]string, error) {
 	return re, nil
 }
 
+// AncestorTags retrieves the tags on a commit id and its ancestors.
+func (s *GitRepo) AncestorTags(id string) ([]string, error) {
+	return s.AncestorTagsContext(context.Background(), id)
+}
+
+// AncestorTagsContext is the context-aware version of AncestorTags.
+func (s *GitRepo) AncestorTagsContext(ctx context.Context, id string) ([]string, error) {
+	if !isGitRevision(id) {
+		return []string{}, ErrRevisionUnavailable
+	}
+	out, err := s.RunFromDirContext(ctx, "git", "tag", "--merged", id)
+	if err != nil {
+		return []string{}, NewLocalError("Unable to retrieve tags", err, string(out))
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// Log returns an iterator over the commits selected by opts, newest first.
+func (s *GitRepo) Log(opts LogOptions) iter.Seq2[*CommitInfo, error] {
+	return s.LogContext(context.Background(), opts)
+}
+
+// LogContext is the context-aware version of Log.
+func (s *GitRepo) LogContext(ctx context.Context, opts LogOptions) iter.Seq2[*CommitInfo, error] {
+	args := []string{"log", "--format=" + gitCommitFormat + "%x1e"}
+	if opts.FirstParent {
+		args = append(args, "--first-parent")
+	}
+	if opts.Author != "" {
+		args = append(args, "--fixed-strings", "--regexp-ignore-case", "--author="+opts.Author)
+	}
+	since, until := opts.window()
+	if !opts.Since.IsZero() {
+		args = append(args, "--since=@"+strconv.FormatInt(since, 10))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until=@"+strconv.FormatInt(until, 10))
+	}
+	if n := opts.limit(0, true); n != "" {
+		args = append(args, "-n", n)
+	}
+	rev := opts.To
+	if rev == "" {
+		rev = "HEAD"
+	}
+	if !isGitRevision(rev) || (opts.From != "" && !isGitRevision(opts.From)) {
+		return func(yield func(*CommitInfo, error) bool) {
+			yield(nil, ErrRevisionUnavailable)
+		}
+	}
+	if opts.From != "" {
+		rev = opts.From + ".." + rev
+	}
+	args = append(args, rev, "--")
+	if opts.Path != "" {
+		args = append(args, opts.Path)
+	}
+
+	return logSeq(ctx, opts, func(ctx context.Context, emit func(*CommitInfo) bool) error {
+		return s.streamFromDir(ctx, func(r io.Reader) error {
+			sc := bufio.NewScanner(r)
+			sc.Buffer(nil, 1<<20)
+			sc.Split(scanRecords)
+			for sc.Scan() {
+				if strings.TrimSpace(sc.Text()) == "" {
+					continue
+				}
+				ci, err := parseGitCommit(sc.Text())
+				if err != nil {
+					return err
+				}
+				if !emit(ci) {
+					return errStopLog
+				}
+			}
+			return sc.Err()
+		}, "git", args...)
+	})
+}
+
 // Ping returns if remote location is accessible.
 func (s *GitRepo) Ping() bool {
-	c := exec.Command("git", "ls-remote", s.Remote())
+	return s.PingContext(context.Background())
+}
 
+// PingContext is the context-aware version of Ping.
+func (s *GitRepo) PingContext(ctx context.Context) bool {
 	// If prompted for a username and password, which GitHub does for all things
 	// not public, it's considered not available. To make it available the
 	// remote needs to be different.
-	c.Env = mergeEnvLists([]string{"GIT_TERMINAL_PROMPT=0"}, os.Environ())
-	_, err := c.CombinedOutput()
+	env := mergeEnvLists([]string{"GIT_TERMINAL_PROMPT=0"}, os.Environ())
+	_, err := s.combinedOutput(ctx, "", env, "git", "ls-remote", s.Remote())
 	return err == nil
 }
 
@@ -384,16 +782,41 @@ func EscapePathSeparator(path string) string {
 	}
 }
 
+// withTrailingSeparator adds a path separator to the end of dir, if it does
+// not have one, as git reads the --prefix of checkout-index as a directory
+// only when it ends with one.
+func withTrailingSeparator(dir string) string {
+	if !strings.HasSuffix(dir, string(os.PathSeparator)) {
+		dir += string(os.PathSeparator)
+	}
+	return dir
+}
+
 // ExportDir exports the current revision to the passed in directory.
 func (s *GitRepo) ExportDir(dir string) error {
+	return s.ExportDirContext(context.Background(), dir)
+}
 
-	var path string
+// ExportDirContext is the context-aware version of ExportDir.
+func (s *GitRepo) ExportDirContext(ctx context.Context, dir string) error {
+	dir = withTrailingSeparator(dir)
+	if err := s.exportIndex(ctx, dir); err != nil {
+		return err
+	}
 
-	// Without the trailing / there can be problems.
-	if !strings.HasSuffix(dir, string(os.PathSeparator)) {
-		dir = dir + string(os.PathSeparator)
+	// and now, the horror of submodules
+	out, err := handleSubmodules(ctx, s, dir)
+	s.log(out)
+	if err != nil {
+		return NewLocalError("Error while exporting submodule sources", err, string(out))
 	}
 
+	return nil
+}
+
+// exportIndex exports the current revision, without submodules, to dir,
+// which ends with a path separator.
+func (s *GitRepo) exportIndex(ctx context.Context, dir string) error {
 	// checkout-index on some systems, such as some Windows cases, does not
 	// create the parent directory to export into if it does not exist. Explicitly
 	// creating it.
@@ -402,37 +825,119 @@ func (s *GitRepo) ExportDir(dir string) error {
 		return NewLocalError("Unable to create directory", err, "")
 	}
 
-	path = EscapePathSeparator(dir)
-	out, err := s.RunFromDir("git", "checkout-index", "-f", "-a", "--prefix="+path)
+	path := EscapePathSeparator(dir)
+	out, err := s.RunFromDirContext(ctx, "git", "checkout-index", "-f", "-a", "--prefix="+path)
 	s.log(out)
 	if err != nil {
 		return NewLocalError("Unable to export source", err, string(out))
 	}
+	return nil
+}
 
-	// and now, the horror of submodules
-	out, err = handleSubmodules(s, dir)
-	s.log(out)
+// exportArchive exports the current revision, without submodules, to dir the
+// way the go command does for module zips: with git archive, without line
+// ending conversion, and with the export-ignore and export-subst attributes
+// disabled. The go command disables them in the repository's info/attributes.
+// Here that is done in a temporary repository borrowing the objects of the
+// working copy, so that the working copy is left untouched.
+func (s *GitRepo) exportArchive(ctx context.Context, dir string) error {
+	out, err := s.RunFromDirContext(ctx, "git", "rev-parse", "--verify", "HEAD^{commit}")
 	if err != nil {
-		return NewLocalError("Error while exporting submodule sources", err, string(out))
+		return NewLocalError("Unable to export source", err, string(out))
+	}
+	rev := strings.TrimSpace(string(out))
+	out, err = s.RunFromDirContext(ctx, "git", "rev-parse", "--git-common-dir")
+	if err != nil {
+		return NewLocalError("Unable to export source", err, string(out))
+	}
+	gitDir := filepath.FromSlash(strings.TrimSpace(string(out)))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(s.LocalPath(), gitDir)
+	}
+	if gitDir, err = filepath.Abs(gitDir); err != nil {
+		return NewLocalError("Unable to export source", err, "")
 	}
 
-	return nil
-}
+	tmp, err := os.MkdirTemp("", "go-vcs-archive")
+	if err != nil {
+		return NewLocalError("Unable to create directory", err, "")
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+	archiveDir := filepath.Join(tmp, "repo.git")
+	if out, err := s.runContext(ctx, "git", "init", "-q", "--bare", "--", archiveDir); err != nil {
+		return NewLocalError("Unable to export source", err, string(out))
+	}
+	files := map[string]string{
+		filepath.Join(archiveDir, "objects", "info", "alternates"): filepath.Join(gitDir, "objects") + "\n",
+		filepath.Join(archiveDir, "info", "attributes"):            "* -export-subst -export-ignore\n",
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return NewLocalError("Unable to export source", err, "")
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			return NewLocalError("Unable to export source", err, "")
+		}
+	}
 
-// isDetachedHead will detect if git repo is in "detached head" state.
-func isDetachedHead(dir string) (bool, error) {
-	p := filepath.Join(dir, ".git", "HEAD")
-	contents, err := os.ReadFile(p)
+	archive := filepath.Join(tmp, "source.tar")
+	env := mergeEnvLists([]string{"GIT_DIR=" + archiveDir}, os.Environ())
+	out, err = s.combinedOutput(ctx, tmp, env, "git", "-c", "core.autocrlf=input", "-c", "core.eol=lf",
+		"archive", "--format=tar", "--output="+archive, rev)
 	if err != nil {
-		return false, err
+		return NewLocalError("Unable to export source", err, string(out))
 	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return NewLocalError("Unable to export source", err, "")
+	}
+	defer func() { _ = f.Close() }()
+	if err := extractTar(f, dir); err != nil {
+		return NewLocalError("Unable to export source", err, "")
+	}
+	return nil
+}
 
-	contents = bytes.TrimSpace(contents)
-	if bytes.HasPrefix(contents, []byte("ref: ")) {
+// ExportModuleZip writes the Go module zip file for the module modulePath at
+// version, found in subdir of the repo, to w. The current revision is
+// exported and the files are laid out as the go command expects, with the
+// rules of golang.org/x/mod/zip applied. Submodules are not included as they
+// are not part of a module.
+func (s *GitRepo) ExportModuleZip(w io.Writer, modulePath, version, subdir string) error {
+	return s.ExportModuleZipContext(context.Background(), w, modulePath, version, subdir)
+}
+
+// ExportModuleZipContext is the context-aware version of ExportModuleZip.
+func (s *GitRepo) ExportModuleZipContext(ctx context.Context, w io.Writer, modulePath, version, subdir string) error {
+	return writeModuleZip(ctx, s.exportArchive, w, modulePath, version, subdir)
+}
+
+// DirHash returns the h1: hash, as recorded in go.sum, of the module zip file
+// ExportModuleZip writes for the current revision.
+func (s *GitRepo) DirHash(modulePath, version, subdir string) (string, error) {
+	return s.DirHashContext(context.Background(), modulePath, version, subdir)
+}
+
+// DirHashContext is the context-aware version of DirHash.
+func (s *GitRepo) DirHashContext(ctx context.Context, modulePath, version, subdir string) (string, error) {
+	return moduleHash(ctx, s.exportArchive, modulePath, version, subdir)
+}
+
+// isDetachedHead will detect if git repo is in "detached head" state.
+func (s *GitRepo) isDetachedHead(ctx context.Context) (bool, error) {
+	// symbolic-ref quietly exits with a status of 1 when HEAD is not a
+	// symbolic ref, which is the case when it is detached.
+	out, err := s.RunFromDirContext(ctx, "git", "symbolic-ref", "-q", "HEAD")
+	if err == nil {
 		return false, nil
 	}
+	if !isContextErr(err) && exitCode(err) == 1 {
+		return true, nil
+	}
 
-	return true, nil
+	return false, fmt.Errorf("%s: %w", bytes.TrimSpace(out), err)
 }
 
 // isUnableToCreateDir checks for an error in Init() to see if an error
@@ -3,14 +3,24 @@ package vcs
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 	//"log"
 )
 
-// Canary test to ensure GitRepo implements the Repo interface.
-var _ Repo = &GitRepo{}
+// Canary test to ensure GitRepo implements the Repo interface and the optional
+// interfaces.
+var (
+	_ Repo        = &GitRepo{}
+	_ DiffRepo    = &GitRepo{}
+	_ FileRepo    = &GitRepo{}
+	_ HistoryRepo = &GitRepo{}
+	_ StatusRepo  = &GitRepo{}
+)
 
 // To verify git is working we perform integration testing
 // with a known git service.
@@ -527,3 +537,194 @@ func TestGitSubmoduleHandling2(t *testing.T) {
 		t.Error("Error checking Git metadata. It exists.")
 	}
 }
+
+// gitTestCommit is a commit made by newGitTestRepo.
+type gitTestCommit struct {
+	// Author is in the form "Name <email>". It defaults to Test Author.
+	Author string
+
+	// Message defaults to "Commit N" where N counts from 1.
+	Message string
+
+	// Files maps a slash separated path to the content written to it.
+	Files map[string]string
+
+	// Branch is created from the previous commit when it does not exist. An
+	// empty branch is master.
+	Branch string
+
+	// CommitDate defaults to the date of the commit.
+	CommitDate time.Time
+
+	// Tags are created on the commit.
+	Tags []string
+}
+
+// gitTestDate is the date of the first commit made by newGitTestRepo. Each
+// commit after it is an hour later.
+var gitTestDate = time.Date(2015, 7, 29, 9, 46, 39, 0, time.FixedZone("", -4*60*60))
+
+// newGitTestRepo creates a Git repository with commits on master and returns
+// a checkout of it along with the id of each commit.
+func newGitTestRepo(t *testing.T, commits ...gitTestCommit) (*GitRepo, []string) {
+	t.Helper()
+	root := t.TempDir()
+	remote := filepath.Join(root, "remote")
+	git := func(env []string, args ...string) string {
+		t.Helper()
+		c := exec.Command("git", args...)
+		c.Dir = remote
+		c.Env = append(os.Environ(), append([]string{"GIT_CONFIG_NOSYSTEM=1", "HOME=" + root}, env...)...)
+		out, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+		return string(out)
+	}
+
+	if err := os.MkdirAll(remote, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git(nil, "init", "-q", ".")
+	git(nil, "symbolic-ref", "HEAD", "refs/heads/master")
+	branches := map[string]bool{"master": true}
+	current := "master"
+	var ids []string
+	for i, c := range commits {
+		if c.Branch == "" {
+			c.Branch = "master"
+		}
+		if c.Branch != current {
+			if branches[c.Branch] {
+				git(nil, "checkout", "-q", c.Branch)
+			} else {
+				git(nil, "checkout", "-q", "-b", c.Branch)
+				branches[c.Branch] = true
+			}
+			current = c.Branch
+		}
+
+		for name, content := range c.Files {
+			p := filepath.Join(remote, filepath.FromSlash(name))
+			writeFixtureFile(t, filepath.Dir(p), filepath.Base(p), content)
+		}
+		if c.Author == "" {
+			c.Author = "Test Author <author@example.com>"
+		}
+		if c.Message == "" {
+			c.Message = fmt.Sprintf("Commit %d", i+1)
+		}
+		date := gitTestDate.Add(time.Duration(i) * time.Hour)
+		if c.CommitDate.IsZero() {
+			c.CommitDate = date
+		}
+		name, email := splitPerson(c.Author)
+		env := []string{
+			"GIT_AUTHOR_NAME=" + name,
+			"GIT_AUTHOR_EMAIL=" + email,
+			"GIT_AUTHOR_DATE=" + date.Format(time.RFC3339),
+			"GIT_COMMITTER_NAME=" + name,
+			"GIT_COMMITTER_EMAIL=" + email,
+			"GIT_COMMITTER_DATE=" + c.CommitDate.Format(time.RFC3339),
+		}
+		git(nil, "add", "-A")
+		git(env, "commit", "-q", "--allow-empty", "-m", c.Message)
+		ids = append(ids, strings.TrimSpace(git(nil, "rev-parse", "HEAD")))
+		for _, tag := range c.Tags {
+			git(env, "tag", tag)
+		}
+	}
+	if current != "master" {
+		git(nil, "checkout", "-q", "master")
+	}
+
+	repo, err := NewGitRepo(remote, filepath.Join(root, "checkout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Get(); err != nil {
+		t.Fatal(err)
+	}
+	return repo, ids
+}
+
+func TestParseGitDiff(t *testing.T) {
+	d, err := ParseDiff(strings.NewReader(`diff --git a/a.txt b/a.txt
+index 7898192..6178079 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@ func main() {
+-a
++b
+ c
+\ No newline at end of file
+diff --git a/new file.txt b/new file.txt
+new file mode 100644
+index 0000000..e69de29
+--- /dev/null
++++ b/new file.txt
+@@ -0,0 +1 @@
++--- not a header
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+index 7898192..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-old
+diff --git a/from.txt b/to.txt
+similarity index 90%
+rename from from.txt
+rename to to.txt
+diff --git a/bin.dat b/bin.dat
+index 1b2c3d4..5e6f7a8 100644
+Binary files a/bin.dat and b/bin.dat differ
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fileSummary{
+		{"a.txt", "a.txt", FileModified, false, 1, 1},
+		{"", "new file.txt", FileAdded, false, 1, 0},
+		{"old.txt", "", FileDeleted, false, 0, 1},
+		{"from.txt", "to.txt", FileRenamed, false, 0, 0},
+		{"bin.dat", "bin.dat", FileModified, true, 0, 0},
+	}
+	if got := summarize(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+// Git quotes paths with unusual characters in the diff --git, ---, +++, and
+// rename lines.
+func TestParseGitDiffQuotedPaths(t *testing.T) {
+	d, err := ParseDiff(strings.NewReader(`diff --git "a/h\303\251llo w\303\266rld.txt" "b/h\303\251llo w\303\266rld.txt"
+index 7898192..6178079 100644
+--- "a/h\303\251llo w\303\266rld.txt"
++++ "b/h\303\251llo w\303\266rld.txt"
+@@ -1 +1 @@
+-a
++b
+diff --git a/plain.txt "b/tab\there.txt"
+similarity index 100%
+rename from plain.txt
+rename to "tab\there.txt"
+diff --git "a/\"quoted\".txt" b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++n
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fileSummary{
+		{"héllo wörld.txt", "héllo wörld.txt", FileModified, false, 1, 1},
+		{"plain.txt", "tab\there.txt", FileRenamed, false, 0, 0},
+		{"", "new.txt", FileAdded, false, 1, 0},
+	}
+	if got := summarize(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
@@ -2,11 +2,14 @@
 
 package vcs
 
-import "os"
+import (
+	"context"
+	"os"
+)
 
-func handleSubmodules(g *GitRepo, dir string) ([]byte, error) {
+func handleSubmodules(ctx context.Context, g *GitRepo, dir string) ([]byte, error) {
 	// Generate path
 	path := EscapePathSeparator(dir + "$path" + string(os.PathSeparator))
 
-	return g.RunFromDir("git", "submodule", "foreach", "--recursive", "git checkout-index -f -a --prefix="+path)
+	return g.RunFromDirContext(ctx, "git", "submodule", "foreach", "--recursive", "git checkout-index -f -a --prefix="+path)
 }
@@ -3,14 +3,15 @@
 package vcs
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-func handleSubmodules(g *GitRepo, dir string) ([]byte, error) {
+func handleSubmodules(ctx context.Context, g *GitRepo, dir string) ([]byte, error) {
 	// Get the submodule directories
-	out, err := g.RunFromDir("git", "submodule", "foreach", "--quiet", "--recursive", "echo $sm_path")
+	out, err := g.RunFromDirContext(ctx, "git", "submodule", "foreach", "--quiet", "--recursive", "echo $sm_path")
 	if err != nil {
 		return out, err
 	}
@@ -34,9 +35,8 @@ func handleSubmodules(g *GitRepo, dir string) ([]byte, error) {
 		// Call checkout-index directly in the submodule rather than in the
 		// parent project. This stils git submodule foreach that has trouble
 		// on Windows within Go where $sm_path isn't being handled properly
-		c := g.CmdFromDir("git", "checkout-index", "-f", "-a", "--prefix="+fpth)
-		c.Dir = filepath.Join(c.Dir, pth)
-		out, err := c.CombinedOutput()
+		dir := filepath.Join(g.LocalPath(), pth)
+		out, err := g.combinedOutput(ctx, dir, envForDir(dir), "git", "checkout-index", "-f", "-a", "--prefix="+fpth)
 		cOut = append(cOut, out...)
 		if err != nil {
 			return cOut, err
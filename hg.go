@@ -1,12 +1,19 @@
 package vcs
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,10 +21,10 @@ import (
 var hgDetectURL = regexp.MustCompile("default = (?P<foo>.+)\n")
 
 // NewHgRepo creates a new instance of HgRepo. The remote and local directories
-// need to be passed in.
-func NewHgRepo(remote, local string) (*HgRepo, error) {
-	ins := depInstalled("hg")
-	if !ins {
+// need to be passed in. Any opts are applied to the repo.
+func NewHgRepo(remote, local string, opts ...Option) (*HgRepo, error) {
+	c := newConfig(opts)
+	if !c.installed("hg") {
 		return nil, NewLocalError("hg is not installed", nil, "")
 	}
 	ltype, err := DetectVcsFromFS(local)
@@ -30,17 +37,16 @@ func NewHgRepo(remote, local string) (*HgRepo, error) {
 	r := &HgRepo{}
 	r.setRemote(remote)
 	r.setLocalPath(local)
+	r.vcsType = Hg
 	r.Logger = Logger
+	r.configure(c)
 
 	// Make sure the local Hg repo is configured the same as the remote when
 	// A remote value was passed in.
 	if err == nil && r.CheckLocal() {
 		// An Hg repo was found so test that the URL there matches
 		// the repo passed in here.
-		c := exec.Command("hg", "paths")
-		c.Dir = local
-		c.Env = envForDir(c.Dir)
-		out, err := c.CombinedOutput()
+		out, err := r.RunFromDir("hg", "paths")
 		if err != nil {
 			return nil, NewLocalError("Unable to retrieve local repo information", err, string(out))
 		}
@@ -74,7 +80,12 @@ func (s HgRepo) Vcs() Type {
 
 // Get is used to perform an initial clone of a repository.
 func (s *HgRepo) Get() error {
-	out, err := s.run("hg", "clone", "--", s.Remote(), s.LocalPath())
+	return s.GetContext(context.Background())
+}
+
+// GetContext is the context-aware version of Get.
+func (s *HgRepo) GetContext(ctx context.Context) error {
+	out, err := s.runContext(ctx, "hg", "clone", "--", s.Remote(), s.LocalPath())
 	if err != nil {
 		return NewRemoteError("Unable to get repository", err, string(out))
 	}
@@ -83,7 +94,12 @@ func (s *HgRepo) Get() error {
 
 // Init will initialize a mercurial repository at local location.
 func (s *HgRepo) Init() error {
-	out, err := s.run("hg", "init", "--", s.LocalPath())
+	return s.InitContext(context.Background())
+}
+
+// InitContext is the context-aware version of Init.
+func (s *HgRepo) InitContext(ctx context.Context) error {
+	out, err := s.runContext(ctx, "hg", "init", "--", s.LocalPath())
 	if err != nil {
 		return NewLocalError("Unable to initialize repository", err, string(out))
 	}
@@ -92,19 +108,29 @@ func (s *HgRepo) Init() error {
 
 // Update performs a Mercurial pull to an existing checkout.
 func (s *HgRepo) Update() error {
-	return s.UpdateVersion(``)
+	return s.UpdateContext(context.Background())
+}
+
+// UpdateContext is the context-aware version of Update.
+func (s *HgRepo) UpdateContext(ctx context.Context) error {
+	return s.UpdateVersionContext(ctx, ``)
 }
 
 // UpdateVersion sets the version of a package currently checked out via Hg.
 func (s *HgRepo) UpdateVersion(version string) error {
-	out, err := s.RunFromDir("hg", "pull")
+	return s.UpdateVersionContext(context.Background(), version)
+}
+
+// UpdateVersionContext is the context-aware version of UpdateVersion.
+func (s *HgRepo) UpdateVersionContext(ctx context.Context, version string) error {
+	out, err := s.RunFromDirContext(ctx, "hg", "pull")
 	if err != nil {
 		return NewLocalError("Unable to update checked out version", err, string(out))
 	}
 	if len(strings.TrimSpace(version)) > 0 {
-		out, err = s.RunFromDir("hg", "update", "--", version)
+		out, err = s.RunFromDirContext(ctx, "hg", "update", "--", version)
 	} else {
-		out, err = s.RunFromDir("hg", "update")
+		out, err = s.RunFromDirContext(ctx, "hg", "update")
 	}
 	if err != nil {
 		return NewLocalError("Unable to update checked out version", err, string(out))
@@ -114,11 +140,21 @@ func (s *HgRepo) UpdateVersion(version string) error {
 
 // Version retrieves the current version.
 func (s *HgRepo) Version() (string, error) {
-	c := s.CmdFromDir("hg", "--debug", "identify")
+	return s.VersionContext(context.Background())
+}
+
+// VersionContext is the context-aware version of Version.
+func (s *HgRepo) VersionContext(ctx context.Context) (string, error) {
 	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
-	c.Stdout = stdout
-	c.Stderr = stderr
-	if err := c.Run(); err != nil {
+	err := s.exec(ctx, &Command{
+		Name:   "hg",
+		Args:   []string{"--debug", "identify"},
+		Dir:    s.LocalPath(),
+		Env:    envForDir(s.LocalPath()),
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
 		return "", NewLocalError("Unable to retrieve checked out version", err, stderr.String())
 	}
 	if stderr.Len() > 0 {
@@ -137,18 +173,23 @@ func (s *HgRepo) Version() (string, error) {
 // * Tag if on a tag
 // * Otherwise a revision id
 func (s *HgRepo) Current() (string, error) {
-	out, err := s.RunFromDir("hg", "branch")
+	return s.CurrentContext(context.Background())
+}
+
+// CurrentContext is the context-aware version of Current.
+func (s *HgRepo) CurrentContext(ctx context.Context) (string, error) {
+	out, err := s.RunFromDirContext(ctx, "hg", "branch")
 	if err != nil {
 		return "", err
 	}
 	branch := strings.TrimSpace(string(out))
 
-	tip, err := s.CommitInfo("max(branch(" + branch + "))")
+	tip, err := s.CommitInfoContext(ctx, "max(branch("+branch+"))")
 	if err != nil {
 		return "", err
 	}
 
-	curr, err := s.Version()
+	curr, err := s.VersionContext(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -158,7 +199,7 @@ func (s *HgRepo) Current() (string, error) {
 		return branch, nil
 	}
 
-	ts, err := s.TagsFromCommit(curr)
+	ts, err := s.TagsFromCommitContext(ctx, curr)
 	if err != nil {
 		return "", err
 	}
@@ -171,11 +212,16 @@ func (s *HgRepo) Current() (string, error) {
 
 // Date retrieves the date on the latest commit.
 func (s *HgRepo) Date() (time.Time, error) {
-	version, err := s.Version()
+	return s.DateContext(context.Background())
+}
+
+// DateContext is the context-aware version of Date.
+func (s *HgRepo) DateContext(ctx context.Context) (time.Time, error) {
+	version, err := s.VersionContext(ctx)
 	if err != nil {
 		return time.Time{}, NewLocalError("Unable to retrieve revision date", err, "")
 	}
-	out, err := s.RunFromDir("hg", "log", "-r", version, "--template", "{date|isodatesec}")
+	out, err := s.RunFromDirContext(ctx, "hg", "log", hgRev(version), "--template", "{date|isodatesec}")
 	if err != nil {
 		return time.Time{}, NewLocalError("Unable to retrieve revision date", err, string(out))
 	}
@@ -197,7 +243,12 @@ func (s *HgRepo) CheckLocal() bool {
 
 // Branches returns a list of available branches
 func (s *HgRepo) Branches() ([]string, error) {
-	out, err := s.RunFromDir("hg", "branches")
+	return s.BranchesContext(context.Background())
+}
+
+// BranchesContext is the context-aware version of Branches.
+func (s *HgRepo) BranchesContext(ctx context.Context) ([]string, error) {
+	out, err := s.RunFromDirContext(ctx, "hg", "branches")
 	if err != nil {
 		return []string{}, NewLocalError("Unable to retrieve branches", err, string(out))
 	}
@@ -207,7 +258,12 @@ func (s *HgRepo) Branches() ([]string, error) {
 
 // Tags returns a list of available tags
 func (s *HgRepo) Tags() ([]string, error) {
-	out, err := s.RunFromDir("hg", "tags")
+	return s.TagsContext(context.Background())
+}
+
+// TagsContext is the context-aware version of Tags.
+func (s *HgRepo) TagsContext(ctx context.Context) ([]string, error) {
+	out, err := s.RunFromDirContext(ctx, "hg", "tags")
 	if err != nil {
 		return []string{}, NewLocalError("Unable to retrieve tags", err, string(out))
 	}
@@ -218,69 +274,337 @@ func (s *HgRepo) Tags() ([]string, error) {
 // IsReference returns if a string is a reference. A reference can be a
 // commit id, branch, or tag.
 func (s *HgRepo) IsReference(r string) bool {
-	_, err := s.RunFromDir("hg", "log", "-r", r)
+	return s.IsReferenceContext(context.Background(), r)
+}
+
+// IsReferenceContext is the context-aware version of IsReference.
+func (s *HgRepo) IsReferenceContext(ctx context.Context, r string) bool {
+	_, err := s.RunFromDirContext(ctx, "hg", "log", hgRev(r))
 	return err == nil
 }
 
 // IsDirty returns if the checkout has been modified from the checked
-// out reference.
+// out reference. Untracked files only count when the repo was created with
+// WithCountUntracked.
 func (s *HgRepo) IsDirty() bool {
-	out, err := s.RunFromDir("hg", "diff")
-	return err != nil || len(out) != 0
+	return s.IsDirtyContext(context.Background())
 }
 
-// CommitInfo retrieves metadata about a commit.
-func (s *HgRepo) CommitInfo(id string) (*CommitInfo, error) {
-	out, err := s.RunFromDir("hg", "log", "-r", id, "--style=xml")
+// IsDirtyContext is the context-aware version of IsDirty.
+func (s *HgRepo) IsDirtyContext(ctx context.Context) bool {
+	entries, err := s.status(ctx, StatusOptions{}, s.countUntracked)
+	return err != nil || isDirty(entries, s.countUntracked)
+}
+
+// Status lists the files in the working copy that differ from the checked
+// out revision. See StatusRepo for details.
+func (s *HgRepo) Status(opts StatusOptions) ([]StatusEntry, error) {
+	return s.StatusContext(context.Background(), opts)
+}
+
+// StatusContext is the context-aware version of Status.
+func (s *HgRepo) StatusContext(ctx context.Context, opts StatusOptions) ([]StatusEntry, error) {
+	return s.status(ctx, opts, true)
+}
+
+// status lists the files as StatusContext does, leaving out the untracked
+// files when untracked is false.
+func (s *HgRepo) status(ctx context.Context, opts StatusOptions, untracked bool) ([]StatusEntry, error) {
+	args := []string{"status", "--copies", "--subrepos"}
+	// Selecting a status lists only those selected.
+	switch {
+	case opts.Ignored:
+		args = append(args, "-mardui")
+	case !untracked:
+		args = append(args, "-mard")
+	}
+	out, stderr, err := s.outputFromDir(ctx, "hg", args...)
 	if err != nil {
-		return nil, ErrRevisionUnavailable
+		return nil, NewLocalError("Unable to retrieve status", err, stderr)
+	}
+	resolve, stderr, err := s.outputFromDir(ctx, "hg", "resolve", "--list")
+	if err != nil {
+		return nil, NewLocalError("Unable to retrieve status", err, stderr)
 	}
 
-	type Author struct {
-		Name  string `xml:",chardata"`
-		Email string `xml:"email,attr"`
+	var subrepos []string
+	if b, err := os.ReadFile(filepath.Join(s.LocalPath(), ".hgsub")); err == nil {
+		for _, l := range strings.Split(string(b), "\n") {
+			if p, _, ok := strings.Cut(l, "="); ok && !strings.HasPrefix(strings.TrimSpace(l), "#") {
+				subrepos = append(subrepos, strings.TrimSpace(p))
+			}
+		}
 	}
-	type Logentry struct {
-		Node   string `xml:"node,attr"`
-		Author Author `xml:"author"`
-		Date   string `xml:"date"`
-		Msg    string `xml:"msg"`
+	return parseHgStatus(out, resolve, subrepos), nil
+}
+
+// parseHgStatus parses the output of hg status --copies --subrepos and hg
+// resolve --list. Files within the subrepos are reported as a change to the
+// subrepo.
+func parseHgStatus(status, resolve []byte, subrepos []string) []StatusEntry {
+	statuses := map[byte]FileStatus{
+		'M': FileModified,
+		'A': FileAdded,
+		'R': FileDeleted,
+		'!': FileMissing,
+		'?': FileUntracked,
+		'I': FileIgnored,
+	}
+
+	set := statusSet{}
+	var last string
+	for _, l := range strings.Split(string(status), "\n") {
+		l = strings.TrimSuffix(l, "\r")
+		// The source of a copy or rename is indented below the added file.
+		if strings.HasPrefix(l, "  ") && last != "" {
+			set[last].OrigPath = filepath.ToSlash(l[2:])
+			continue
+		}
+		last = ""
+		if len(l) < 3 || l[1] != ' ' {
+			continue
+		}
+		st, ok := statuses[l[0]]
+		if !ok {
+			continue
+		}
+		p := filepath.ToSlash(l[2:])
+		if sub := hgSubrepo(p, subrepos); sub != "" {
+			if st != FileIgnored {
+				set.add(StatusEntry{Path: sub, Status: FileExternal})
+			}
+			continue
+		}
+		set.add(StatusEntry{Path: p, Status: st})
+		if st == FileAdded {
+			last = p
+		}
 	}
-	type Log struct {
-		XMLName xml.Name   `xml:"log"`
-		Logs    []Logentry `xml:"logentry"`
+
+	// A copy whose source was removed is a rename.
+	for _, e := range set {
+		if e.OrigPath == "" {
+			continue
+		}
+		if src, ok := set[e.OrigPath]; ok && src.Status == FileDeleted {
+			e.Status = FileRenamed
+			delete(set, e.OrigPath)
+		} else {
+			e.OrigPath = ""
+		}
 	}
 
-	logs := &Log{}
-	err = xml.Unmarshal(out, &logs)
+	for _, l := range strings.Split(string(resolve), "\n") {
+		l = strings.TrimSuffix(l, "\r")
+		if strings.HasPrefix(l, "U ") {
+			set.add(StatusEntry{Path: filepath.ToSlash(l[2:]), Status: FileConflicted})
+		}
+	}
+	return set.entries()
+}
+
+// hgRev returns the option selecting the revision rev. The revision is part
+// of the option so that hg cannot read one starting with a dash as an option.
+func hgRev(rev string) string {
+	return "--rev=" + rev
+}
+
+// hgRevsetString quotes s as a string in a revset, which hg resolves like a
+// revision given on its own.
+func hgRevsetString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// hgSubrepo returns the subrepo containing the path p, if any.
+func hgSubrepo(p string, subrepos []string) string {
+	for _, sub := range subrepos {
+		if strings.HasPrefix(p, sub+"/") {
+			return sub
+		}
+	}
+	return ""
+}
+
+// Diff returns the parsed differences between the revisions from and to.
+// See DiffRepo for their meaning.
+func (s *HgRepo) Diff(from, to string, paths ...string) (*Diff, error) {
+	return s.DiffContext(context.Background(), from, to, paths...)
+}
+
+// DiffContext is the context-aware version of Diff.
+func (s *HgRepo) DiffContext(ctx context.Context, from, to string, paths ...string) (*Diff, error) {
+	return diff(ctx, func(ctx context.Context, w io.Writer) error {
+		return s.WriteDiffContext(ctx, w, from, to, paths...)
+	})
+}
+
+// WriteDiff writes the unified diff between the revisions from and to to w.
+// See DiffRepo for their meaning.
+func (s *HgRepo) WriteDiff(w io.Writer, from, to string, paths ...string) error {
+	return s.WriteDiffContext(context.Background(), w, from, to, paths...)
+}
+
+// WriteDiffContext is the context-aware version of WriteDiff.
+func (s *HgRepo) WriteDiffContext(ctx context.Context, w io.Writer, from, to string, paths ...string) error {
+	if from == "" {
+		from = "."
+	}
+	args := []string{"diff", "--git", hgRev(from)}
+	if to != "" {
+		args = append(args, hgRev(to))
+	}
+	args = append(append(args, "--"), paths...)
+	return s.writeDiff(ctx, w, "hg", args...)
+}
+
+// ReadFile returns the contents of the file at path in the revision rev. See
+// FileRepo for details.
+func (s *HgRepo) ReadFile(rev, path string) (io.ReadCloser, error) {
+	return s.ReadFileContext(context.Background(), rev, path)
+}
+
+// ReadFileContext is the context-aware version of ReadFile.
+func (s *HgRepo) ReadFileContext(ctx context.Context, rev, path string) (io.ReadCloser, error) {
+	fi, err := s.StatContext(ctx, rev, path)
 	if err != nil {
-		return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
+		return nil, err
 	}
-	if len(logs.Logs) == 0 {
-		return nil, ErrRevisionUnavailable
+	if rev == "" {
+		rev = "."
 	}
+	return s.readFile(ctx, fi, "hg", "cat", hgRev(rev), "--", "path:"+fi.Path)
+}
 
-	ci := &CommitInfo{
-		Commit:  logs.Logs[0].Node,
-		Author:  logs.Logs[0].Author.Name + " <" + logs.Logs[0].Author.Email + ">",
-		Message: logs.Logs[0].Msg,
+// Stat describes the file or directory at path in the revision rev. See
+// FileRepo for details.
+func (s *HgRepo) Stat(rev, path string) (*FileInfo, error) {
+	return s.StatContext(context.Background(), rev, path)
+}
+
+// StatContext is the context-aware version of Stat.
+func (s *HgRepo) StatContext(ctx context.Context, rev, path string) (*FileInfo, error) {
+	if rev == "" {
+		rev = "."
 	}
+	path = cleanRepoPath(path)
+	if path == "." {
+		if err := fileNotFound(ctx, s.CommitInfoContext, rev); err != ErrFileNotFound {
+			return nil, err
+		}
+		return &FileInfo{Path: path, Mode: fs.ModeDir | 0755}, nil
+	}
+	// hg files exits with 1 when nothing matches and lists the files under
+	// a directory.
+	out, _, err := s.outputFromDir(ctx, "hg", "files", hgRev(rev), "--template", hgFilesTemplate, "--", "path:"+path)
+	if err != nil {
+		return nil, fileNotFound(ctx, s.CommitInfoContext, rev)
+	}
+	if fi, ok := parseHgFiles(string(out), path); ok {
+		return fi, nil
+	}
+	return nil, ErrFileNotFound
+}
 
-	if logs.Logs[0].Date != "" {
-		ci.Date, err = time.Parse(time.RFC3339, logs.Logs[0].Date)
-		if err != nil {
+// hgFilesTemplate is the hg files template read by parseHgFiles.
+const hgFilesTemplate = "{size} {flags} {path}\n"
+
+// parseHgFiles finds path in the output of hg files. It is a directory when
+// files are listed under it.
+func parseHgFiles(out, path string) (*FileInfo, bool) {
+	for _, l := range strings.Split(out, "\n") {
+		f := strings.SplitN(l, " ", 3)
+		if len(f) != 3 {
+			continue
+		}
+		switch p := filepath.ToSlash(f[2]); {
+		case p == path:
+			fi := &FileInfo{Path: path, Mode: 0644}
+			fi.Size, _ = strconv.ParseInt(f[0], 10, 64)
+			switch f[1] {
+			case "x":
+				fi.Mode = 0755
+			case "l":
+				fi.Mode = fs.ModeSymlink | 0777
+			}
+			return fi, true
+		case strings.HasPrefix(p, path+"/"):
+			return &FileInfo{Path: path, Mode: fs.ModeDir | 0755}, true
+		}
+	}
+	return nil, false
+}
+
+// CommitInfo retrieves metadata about a commit.
+func (s *HgRepo) CommitInfo(id string) (*CommitInfo, error) {
+	return s.CommitInfoContext(context.Background(), id)
+}
+
+// CommitInfoContext is the context-aware version of CommitInfo.
+func (s *HgRepo) CommitInfoContext(ctx context.Context, id string) (*CommitInfo, error) {
+	out, err := s.RunFromDirContext(ctx, "hg", "log", hgRev(id), "-l", "1", "--template", hgCommitTemplate)
+	if err != nil {
+		if isContextErr(err) {
 			return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
 		}
+		return nil, ErrRevisionUnavailable
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, ErrRevisionUnavailable
 	}
 
+	ci, err := parseHgCommit(strings.TrimSuffix(string(out), "\x1e"))
+	if err != nil {
+		return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
+	}
+	return ci, nil
+}
+
+// hgCommitTemplate is the hg log template read by parseHgCommit. The fields
+// are separated by NUL characters, the description is last, and each commit
+// ends with a record separator.
+const hgCommitTemplate = `{node}\x00{p1node}\x00{p2node}\x00{author|person}\x00{author|email}\x00{date|rfc3339date}\x00{branch}\x00{desc}\x1e`
+
+// hgNullID is the id hg gives the missing parents of a commit.
+const hgNullID = "0000000000000000000000000000000000000000"
+
+// parseHgCommit parses a commit in the hgCommitTemplate.
+func parseHgCommit(rec string) (*CommitInfo, error) {
+	f := strings.SplitN(strings.TrimLeft(rec, "\n"), "\x00", 8)
+	if len(f) != 8 {
+		return nil, fmt.Errorf("unexpected log entry %q", rec)
+	}
+	ci := &CommitInfo{
+		Commit:      f[0],
+		AuthorName:  f[3],
+		AuthorEmail: f[4],
+		Branch:      f[6],
+	}
+	for _, p := range f[1:3] {
+		if p != "" && p != hgNullID {
+			ci.Parents = append(ci.Parents, p)
+		}
+	}
+	ci.Author = joinPerson(ci.AuthorName, ci.AuthorEmail)
+	ci.CommitterName, ci.CommitterEmail = ci.AuthorName, ci.AuthorEmail
+	var err error
+	if ci.Date, err = time.Parse(time.RFC3339, f[5]); err != nil {
+		return nil, err
+	}
+	ci.CommitDate = ci.Date
+	ci.setMessage(f[7])
 	return ci, nil
 }
 
 // TagsFromCommit retrieves tags from a commit id.
 func (s *HgRepo) TagsFromCommit(id string) ([]string, error) {
+	return s.TagsFromCommitContext(context.Background(), id)
+}
+
+// TagsFromCommitContext is the context-aware version of TagsFromCommit.
+func (s *HgRepo) TagsFromCommitContext(ctx context.Context, id string) ([]string, error) {
 	// Hg has a single tag per commit. If a second tag is added to a commit a
 	// new commit is created and the tag is attached to that new commit.
-	out, err := s.RunFromDir("hg", "log", "-r", id, "--style=xml")
+	out, err := s.RunFromDirContext(ctx, "hg", "log", hgRev(id), "--style=xml")
 	if err != nil {
 		return []string{}, NewLocalError("Unable to retrieve tags", err, string(out))
 	}
@@ -310,16 +634,109 @@ func (s *HgRepo) TagsFromCommit(id string) ([]string, error) {
 	return []string{}, nil
 }
 
+// AncestorTags retrieves the tags on a commit id and its ancestors.
+func (s *HgRepo) AncestorTags(id string) ([]string, error) {
+	return s.AncestorTagsContext(context.Background(), id)
+}
+
+// AncestorTagsContext is the context-aware version of AncestorTags.
+func (s *HgRepo) AncestorTagsContext(ctx context.Context, id string) ([]string, error) {
+	out, err := s.RunFromDirContext(ctx, "hg", "log", "-r", "ancestors("+hgRevsetString(id)+") and tag()", "--template", "{tags}\n")
+	if err != nil {
+		return []string{}, NewLocalError("Unable to retrieve tags", err, string(out))
+	}
+	var tags []string
+	for _, t := range strings.Fields(string(out)) {
+		if t != "tip" {
+			tags = append(tags, t)
+		}
+	}
+	return tags, nil
+}
+
+// Log returns an iterator over the commits selected by opts, newest first.
+func (s *HgRepo) Log(opts LogOptions) iter.Seq2[*CommitInfo, error] {
+	return s.LogContext(context.Background(), opts)
+}
+
+// LogContext is the context-aware version of Log.
+func (s *HgRepo) LogContext(ctx context.Context, opts LogOptions) iter.Seq2[*CommitInfo, error] {
+	to := "."
+	if opts.To != "" {
+		to = hgRevsetString(opts.To)
+	}
+	revs := "::" + to
+	if opts.FirstParent {
+		revs = "_firstancestors(" + to + ")"
+	}
+	if opts.From != "" {
+		revs += " - ::" + hgRevsetString(opts.From)
+	}
+	args := []string{"log", "--template", hgCommitTemplate, "-r", "reverse(" + revs + ")"}
+	if opts.Author != "" {
+		// The literal: prefix keeps the author from being read as a regular
+		// expression. Hg matches it ignoring case.
+		args = append(args, "-u", "literal:"+opts.Author)
+	}
+	// Hg reads the dates as Unix times with a time zone offset.
+	since, until := opts.window()
+	switch {
+	case !opts.Since.IsZero() && !opts.Until.IsZero():
+		args = append(args, "-d", fmt.Sprintf("%d 0 to %d 0", since, until))
+	case !opts.Since.IsZero():
+		args = append(args, "-d", fmt.Sprintf(">%d 0", since))
+	case !opts.Until.IsZero():
+		args = append(args, "-d", fmt.Sprintf("<%d 0", until))
+	}
+	if n := opts.limit(0, true); n != "" {
+		args = append(args, "-l", n)
+	}
+	if opts.Path != "" {
+		args = append(args, "--", opts.Path)
+	}
+
+	return logSeq(ctx, opts, func(ctx context.Context, emit func(*CommitInfo) bool) error {
+		return s.streamFromDir(ctx, func(r io.Reader) error {
+			sc := bufio.NewScanner(r)
+			sc.Buffer(nil, 1<<20)
+			sc.Split(scanRecords)
+			for sc.Scan() {
+				if strings.TrimSpace(sc.Text()) == "" {
+					continue
+				}
+				ci, err := parseHgCommit(sc.Text())
+				if err != nil {
+					return err
+				}
+				if !emit(ci) {
+					return errStopLog
+				}
+			}
+			return sc.Err()
+		}, "hg", args...)
+	})
+}
+
 // Ping returns if remote location is accessible.
 func (s *HgRepo) Ping() bool {
-	_, err := s.run("hg", "identify", "--", s.Remote())
+	return s.PingContext(context.Background())
+}
+
+// PingContext is the context-aware version of Ping.
+func (s *HgRepo) PingContext(ctx context.Context) bool {
+	_, err := s.runContext(ctx, "hg", "identify", "--", s.Remote())
 	return err == nil
 }
 
 // ExportDir exports the current revision to the passed in directory.
 func (s *HgRepo) ExportDir(dir string) error {
+	return s.ExportDirContext(context.Background(), dir)
+}
 
-	out, err := s.RunFromDir("hg", "archive", "--", dir)
+// ExportDirContext is the context-aware version of ExportDir.
+func (s *HgRepo) ExportDirContext(ctx context.Context, dir string) error {
+
+	out, err := s.RunFromDirContext(ctx, "hg", "archive", "--", dir)
 	s.log(out)
 	if err != nil {
 		return NewLocalError("Unable to export source", err, string(out))
@@ -327,3 +744,27 @@ func (s *HgRepo) ExportDir(dir string) error {
 
 	return nil
 }
+
+// ExportModuleZip writes the Go module zip file for the module modulePath at
+// version, found in subdir of the repo, to w. The current revision is
+// exported and the files are laid out as the go command expects, with the
+// rules of golang.org/x/mod/zip applied.
+func (s *HgRepo) ExportModuleZip(w io.Writer, modulePath, version, subdir string) error {
+	return s.ExportModuleZipContext(context.Background(), w, modulePath, version, subdir)
+}
+
+// ExportModuleZipContext is the context-aware version of ExportModuleZip.
+func (s *HgRepo) ExportModuleZipContext(ctx context.Context, w io.Writer, modulePath, version, subdir string) error {
+	return writeModuleZip(ctx, s.ExportDirContext, w, modulePath, version, subdir)
+}
+
+// DirHash returns the h1: hash, as recorded in go.sum, of the module zip file
+// ExportModuleZip writes for the current revision.
+func (s *HgRepo) DirHash(modulePath, version, subdir string) (string, error) {
+	return s.DirHashContext(context.Background(), modulePath, version, subdir)
+}
+
+// DirHashContext is the context-aware version of DirHash.
+func (s *HgRepo) DirHashContext(ctx context.Context, modulePath, version, subdir string) (string, error) {
+	return moduleHash(ctx, s.ExportDirContext, modulePath, version, subdir)
+}
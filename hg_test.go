@@ -1,15 +1,24 @@
 package vcs
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 )
 
-// Canary test to ensure HgRepo implements the Repo interface.
-var _ Repo = &HgRepo{}
+// Canary test to ensure HgRepo implements the Repo interface and the optional
+// interfaces.
+var (
+	_ Repo        = &HgRepo{}
+	_ DiffRepo    = &HgRepo{}
+	_ FileRepo    = &HgRepo{}
+	_ HistoryRepo = &HgRepo{}
+	_ StatusRepo  = &HgRepo{}
+)
 
 // To verify hg is working we perform integration testing
 // with a known hg service.
@@ -283,3 +292,149 @@ func TestHgInit(t *testing.T) {
 		t.Errorf("Hg Init reporting wrong initial version: %s", v)
 	}
 }
+
+func TestParseHgStatus(t *testing.T) {
+	status := "M a.txt\nA new.txt\n  old.txt\nA copy.txt\n  a.txt\nR old.txt\n! gone.txt\n? junk.txt\nI build.log\nM sub/x.txt\n? sub/y.txt\n"
+	resolve := "U a.txt\nR b.txt\n"
+	entries := parseHgStatus([]byte(status), []byte(resolve), []string{"sub"})
+	expected := []StatusEntry{
+		{Path: "a.txt", Status: FileConflicted},
+		{Path: "build.log", Status: FileIgnored},
+		{Path: "copy.txt", Status: FileAdded},
+		{Path: "gone.txt", Status: FileMissing},
+		{Path: "junk.txt", Status: FileUntracked},
+		{Path: "new.txt", OrigPath: "old.txt", Status: FileRenamed},
+		{Path: "sub", Status: FileExternal},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+}
+
+func TestParseHgFiles(t *testing.T) {
+	out := "2  a.txt\n5 x run.sh\n1 l link\n3  dir/b.txt\n"
+	tests := []struct {
+		path     string
+		expected *FileInfo
+	}{
+		{"a.txt", &FileInfo{Path: "a.txt", Size: 2, Mode: 0644}},
+		{"run.sh", &FileInfo{Path: "run.sh", Size: 5, Mode: 0755}},
+		{"link", &FileInfo{Path: "link", Size: 1, Mode: fs.ModeSymlink | 0777}},
+		{"dir", &FileInfo{Path: "dir", Mode: fs.ModeDir | 0755}},
+		{"di", nil},
+	}
+	for _, tc := range tests {
+		fi, _ := parseHgFiles(out, tc.path)
+		if !reflect.DeepEqual(fi, tc.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tc.path, tc.expected, fi)
+		}
+	}
+}
+
+func TestHgRevsetString(t *testing.T) {
+	for s, expected := range map[string]string{
+		"1.0.0":               `'1.0.0'`,
+		"it's":                `'it\'s'`,
+		`a\') or all() or ('`: `'a\\\') or all() or (\''`,
+	} {
+		if got := hgRevsetString(s); got != expected {
+			t.Errorf("%s: expected %s, got %s", s, expected, got)
+		}
+	}
+}
+
+func TestParseHgCommit(t *testing.T) {
+	const (
+		node = "a5f5a2c6b4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9"
+		p1   = "0f1e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7d6"
+	)
+	rec := "\n" + strings.Join([]string{node, p1, hgNullID, "Test Author", "author@example.com",
+		"2015-07-30T10:00:00-04:00", "default", "Update README.md\n\nSigned-off-by: Bob <bob@example.com>\n"}, "\x00")
+	ci, err := parseHgCommit(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := time.Date(2015, 7, 30, 10, 0, 0, 0, time.FixedZone("", -4*60*60))
+	expected := &CommitInfo{
+		Commit:         node,
+		Author:         "Test Author <author@example.com>",
+		AuthorName:     "Test Author",
+		AuthorEmail:    "author@example.com",
+		CommitterName:  "Test Author",
+		CommitterEmail: "author@example.com",
+		Date:           d,
+		CommitDate:     d,
+		Parents:        []string{p1},
+		Branch:         "default",
+		Message:        "Update README.md\n\nSigned-off-by: Bob <bob@example.com>",
+		Trailers:       []Trailer{{Key: "Signed-off-by", Value: "Bob <bob@example.com>"}},
+	}
+	if !reflect.DeepEqual(ci, expected) {
+		t.Errorf("expected %+v, got %+v", expected, ci)
+	}
+
+	if _, err := parseHgCommit(node + "\x00" + p1); err == nil {
+		t.Error("expected an error for a truncated entry")
+	}
+}
+
+func TestParseHgDiff(t *testing.T) {
+	d, err := ParseDiff(strings.NewReader(`diff --git a/bin.dat b/bin.dat
+new file mode 100644
+Binary file bin.dat has changed
+diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ a
+
+-b
++c
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fileSummary{
+		{"", "bin.dat", FileAdded, true, 0, 0},
+		{"a.txt", "a.txt", FileModified, false, 1, 1},
+	}
+	if got := summarize(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestHgLogFilters(t *testing.T) {
+	since := time.Date(2015, 7, 29, 13, 46, 39, 0, time.UTC)
+	rec := strings.Join([]string{"a5f5a2c6b4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9", hgNullID, hgNullID, "Alice", "alice@example.com",
+		"2015-07-29T14:00:00Z", "default", "Commit"}, "\x00") + "\x1e"
+	tests := []struct {
+		opts LogOptions
+		args string
+	}{
+		{LogOptions{Author: "alice", Since: since, Until: since.Add(time.Hour), Max: 2}, "-u literal:alice -d 1438177599 0 to 1438181199 0 -l 2"},
+		{LogOptions{Since: since}, "-d >1438177599 0"},
+		{LogOptions{Until: since.Add(time.Hour)}, "-d <1438181199 0"},
+	}
+	for _, tc := range tests {
+		f := &fakeRunner{responses: map[string]fakeResponse{
+			"hg log --template " + hgCommitTemplate + " -r reverse(::.) " + tc.args: {out: rec},
+		}}
+		repo := &HgRepo{}
+		repo.setLocalPath(t.TempDir())
+		repo.Runner = f
+
+		n := 0
+		for ci, err := range repo.Log(tc.opts) {
+			if err != nil {
+				t.Fatalf("%s: %s", tc.args, err)
+			}
+			if ci.AuthorName != "Alice" {
+				t.Errorf("%s: unexpected commit %+v", tc.args, ci)
+			}
+			n++
+		}
+		if n != 1 {
+			t.Errorf("%s: expected 1 commit, got %d", tc.args, n)
+		}
+	}
+}
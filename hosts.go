@@ -0,0 +1,307 @@
+package vcs
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// HostRule teaches NewRepo how to detect the VCS of remotes on a host without
+// making a request to it. Rules are registered with RegisterHost.
+type HostRule struct {
+	// Host is compared with the host of a remote, such as github.com. A rule
+	// with an empty host is generic and applies to every remote no rule for a
+	// specific host has matched.
+	Host string
+
+	// Pattern is a regular expression matched against the host and path of a
+	// remote, such as github.com/Masterminds/vcs/subpkg. The first capturing
+	// group should match the root of the repository. When the host of a remote
+	// matches but the pattern does not the remote is not a repository and
+	// detection fails.
+	Pattern string
+
+	// Vcs is the VCS of every remote the rule matches. When it is empty Check
+	// determines the VCS.
+	Vcs Type
+
+	// Check determines the VCS of a remote the rule matches. It is passed the
+	// named capturing groups of Pattern and the remote. It can make requests
+	// to the host, such as an API call. An error that is not a *RemoteError
+	// is reported as ErrCannotDetectVCS.
+	Check func(m map[string]string, u *url.URL) (Type, error)
+
+	// Priority orders the rules. They are tried in ascending order of
+	// priority and then in the order they were registered. The built-in rules
+	// for hosts have a priority of 0 and the built-in generic rules, such as
+	// the one for remotes ending in .git, .hg, .svn, or .bzr, have
+	// PriorityGeneric.
+	Priority int
+}
+
+// PriorityGeneric is the priority of the built-in rules that apply to any
+// host, such as the one that detects the VCS from the extension of a remote
+// like example.com/foo.git.
+const PriorityGeneric = 100
+
+type hostRule struct {
+	HostRule
+	regex *regexp.Regexp
+}
+
+// builtinHostRules are registered when the package is initialized.
+var builtinHostRules = []HostRule{
+	{
+		Host:    "github.com",
+		Vcs:     Git,
+		Pattern: `^(github\.com[/|:][A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+	},
+	{
+		Host:    "bitbucket.org",
+		Pattern: `^(bitbucket\.org/(?P<name>[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+))(/[A-Za-z0-9_.\-]+)*$`,
+		Vcs:     Git,
+	},
+	{
+		Host:    "launchpad.net",
+		Pattern: `^(launchpad\.net/(([A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)?|~[A-Za-z0-9_.\-]+/(\+junk|[A-Za-z0-9_.\-]+)/[A-Za-z0-9_.\-]+))(/[A-Za-z0-9_.\-]+)*$`,
+		Vcs:     Bzr,
+	},
+	{
+		Host:    "git.launchpad.net",
+		Vcs:     Git,
+		Pattern: `^(git\.launchpad\.net/(([A-Za-z0-9_.\-]+)|~[A-Za-z0-9_.\-]+/(\+git|[A-Za-z0-9_.\-]+)/[A-Za-z0-9_.\-]+))$`,
+	},
+	{
+		Host:    "hub.jazz.net",
+		Vcs:     Git,
+		Pattern: `^(hub\.jazz\.net/git/[a-z0-9]+/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+	},
+	{
+		Host:    "go.googlesource.com",
+		Vcs:     Git,
+		Pattern: `^(go\.googlesource\.com/[A-Za-z0-9_.\-]+/?)$`,
+	},
+	{
+		Host:    "git.openstack.org",
+		Vcs:     Git,
+		Pattern: `^(git\.openstack\.org/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)$`,
+	},
+	{
+		Host:    "hg.code.sf.net",
+		Pattern: `^(hg.code.sf.net/p/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)*$`,
+		Vcs:     Hg,
+	},
+	{
+		Host:    "git.code.sf.net",
+		Pattern: `^(git.code.sf.net/p/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)*$`,
+		Vcs:     Git,
+	},
+	{
+		Host:    "svn.code.sf.net",
+		Pattern: `^(svn.code.sf.net/p/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)*$`,
+		Vcs:     Svn,
+	},
+	{
+		Host:    "svn.riouxsvn.com",
+		Pattern: `^(svn.riouxsvn.com/[A-Za-z0-9_.\-]+(.*)?)*$`,
+		Vcs:     Svn,
+	},
+	// gopkg.in serves Git repos hosted on GitHub at paths that include the
+	// major version, such as gopkg.in/yaml.v3 and gopkg.in/user/pkg.v1.
+	{
+		Host:    "gopkg.in",
+		Vcs:     Git,
+		Pattern: `^(gopkg\.in/(?:[A-Za-z0-9_\-]+/)?[A-Za-z0-9_.\-]+\.v[0-9]+(?:-unstable)?)(/[A-Za-z0-9_.\-]+)*$`,
+	},
+	// Codeberg runs Forgejo and gitea.com runs Gitea. Other installs of
+	// either are detected using the go-get meta tags they serve.
+	{
+		Host:    "codeberg.org",
+		Vcs:     Git,
+		Pattern: `^(codeberg\.org/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+	},
+	{
+		Host:    "gitea.com",
+		Vcs:     Git,
+		Pattern: `^(gitea\.com/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+	},
+	// Sourcehut hosts each VCS on its own domain.
+	{
+		Host:    "git.sr.ht",
+		Vcs:     Git,
+		Pattern: `^(git\.sr\.ht/~[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+	},
+	{
+		Host:    "hg.sr.ht",
+		Vcs:     Hg,
+		Pattern: `^(hg\.sr\.ht/~[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+	},
+	// Azure DevOps repos are at org/project/_git/repo. When the repo has the
+	// same name as the project the project can be left out.
+	{
+		Host:    "dev.azure.com",
+		Vcs:     Git,
+		Pattern: `^(dev\.azure\.com/[^/]+/(?:[^/]+/)?_git/[^/]+)(/.*)?$`,
+	},
+	{
+		Host:    "ssh.dev.azure.com",
+		Vcs:     Git,
+		Pattern: `^(ssh\.dev\.azure\.com/v3/[^/]+/[^/]+/[^/]+)(/.*)?$`,
+	},
+	// If none of the previous detect the type they will fall to these
+	// generic rules. They match hosts that can have any name, such as
+	// installs of Bitbucket Server, or that vary by region.
+	{
+		Vcs:      Git,
+		Pattern:  `^([A-Za-z0-9\-]+\.visualstudio\.com/(?:[^/]+/){0,2}_git/[^/]+)(/.*)?$`,
+		Priority: PriorityGeneric,
+	},
+	{
+		Vcs:      Git,
+		Pattern:  `^(git-codecommit\.[a-z0-9\-]+\.amazonaws\.com(?:\.cn)?/v1/repos/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+		Priority: PriorityGeneric,
+	},
+	// GitLab supports nested subgroups so the root of a repo cannot be told
+	// from the path alone. Paths into the web UI separate the repo from the
+	// rest of the path with /-/ and a .git extension ends the repo. The rules
+	// have no host so other gitlab.com paths fall through to the go-get meta
+	// tags, which GitLab serves with the root, as the go command does.
+	{
+		Vcs:      Git,
+		Pattern:  `^(gitlab\.com/[A-Za-z0-9_.\-]+(?:/[A-Za-z0-9_.\-]+)+)/-(?:/.*)?$`,
+		Priority: PriorityGeneric,
+	},
+	{
+		Vcs:      Git,
+		Pattern:  `^(gitlab\.com/[A-Za-z0-9_.\-]+(?:/[A-Za-z0-9_.\-]+)*?/[A-Za-z0-9_.\-]+\.git)(/[A-Za-z0-9_.\-]+)*$`,
+		Priority: PriorityGeneric,
+	},
+	// Bitbucket Server, and Bitbucket Data Center, serve repos below /scm/
+	// with project or ~user and the repo name. Other servers, such as
+	// SCM-Manager, use /scm/ for every VCS so only hosts named bitbucket, or
+	// installs below /bitbucket, are matched. Rules for other installs can be
+	// registered with RegisterHost.
+	{
+		Vcs:      Git,
+		Pattern:  `^((?:bitbucket\.[^/]+|[^/]+(?:/[^/]+)*?/bitbucket)/scm/~?[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/.*)?$`,
+		Priority: PriorityGeneric,
+	},
+	// Lastly look for the type in a generic sense by the extension to the
+	// path.
+	{
+		Pattern:  `^(.+\.(?P<type>git|hg|svn|bzr))$`,
+		Check:    checkURL,
+		Priority: PriorityGeneric,
+	},
+}
+
+var (
+	hostRulesMu sync.RWMutex
+	hostRules   []*hostRule
+)
+
+func init() {
+	for _, r := range builtinHostRules {
+		if err := RegisterHost(r); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterHost adds a rule used to detect the VCS of remotes. An error is
+// returned when the pattern of the rule is not a valid regular expression or
+// the rule has neither a Vcs nor a Check. It is safe to call from multiple
+// goroutines.
+func RegisterHost(r HostRule) error {
+	if r.Vcs == "" && r.Check == nil {
+		return errors.New("host rule needs a Vcs or Check")
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return err
+	}
+
+	hostRulesMu.Lock()
+	defer hostRulesMu.Unlock()
+	// Readers hold on to the slice without the lock so a new one is made.
+	rules := append(slices.Clone(hostRules), &hostRule{HostRule: r, regex: re})
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+	hostRules = rules
+	return nil
+}
+
+// matchHost finds the first rule that matches u. It returns the rule along
+// with the match of its pattern. The rule is nil when a rule for the host of u
+// exists but no rule matched, meaning u is not a repository, or when no rule
+// matched at all. ok reports if a rule for the host of u exists.
+func matchHost(u *url.URL) (r *hostRule, m []string, ok bool) {
+	hostRulesMu.RLock()
+	rules := hostRules
+	hostRulesMu.RUnlock()
+
+	uCheck := u.Host + u.Path
+	for _, r := range rules {
+		if r.Host != "" && r.Host != u.Host {
+			continue
+		}
+		if r.Host != "" {
+			ok = true
+		} else if ok {
+			// Generic rules only apply to hosts without specific rules.
+			continue
+		}
+
+		// Make sure the pattern matches for an actual repo location. For
+		// example, we should fail if the VCS listed is github.com/masterminds
+		// as that's not actually a repo.
+		if m := r.regex.FindStringSubmatch(uCheck); m != nil {
+			return r, m, ok
+		}
+	}
+	return nil, nil, ok
+}
+
+// detectVcsFromHost detects the VCS of u, and the root of the repository
+// containing it, using the host rules. found reports if a rule for the host of
+// u exists, in which case the result is final.
+func detectVcsFromHost(u *url.URL) (t Type, root string, found bool, err error) {
+	r, m, found := matchHost(u)
+	if r == nil {
+		return "", "", found, ErrCannotDetectVCS
+	}
+	if len(m) > 1 {
+		root = m[1]
+	} else {
+		root = m[0]
+	}
+
+	// If we are here the host matches. If the host has a singular
+	// VCS type, such as Github, we can return the type right away.
+	if r.Vcs != "" {
+		return r.Vcs, root, true, nil
+	}
+
+	// Run additional checks to determine try and determine the repo
+	// for the matched service.
+	info := make(map[string]string)
+	for i, name := range r.regex.SubexpNames() {
+		if name != "" {
+			info[name] = m[i]
+		}
+	}
+	t, err = r.Check(info, u)
+	if err != nil {
+		switch err.(type) {
+		case *RemoteError:
+			return "", "", true, err
+		}
+		return "", "", true, ErrCannotDetectVCS
+	}
+
+	return t, root, true, nil
+}
@@ -0,0 +1,77 @@
+package vcs
+
+import (
+	"net/url"
+	"testing"
+)
+
+func registerTestHost(t *testing.T, r HostRule) {
+	orig := hostRules
+	t.Cleanup(func() {
+		hostRulesMu.Lock()
+		hostRules = orig
+		hostRulesMu.Unlock()
+	})
+	if err := RegisterHost(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterHost(t *testing.T) {
+	registerTestHost(t, HostRule{
+		Host:    "code.example.com",
+		Pattern: `^(code\.example\.com/hg/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+		Vcs:     Hg,
+	})
+	var checked map[string]string
+	registerTestHost(t, HostRule{
+		Host:    "scm.example.com",
+		Pattern: `^(scm\.example\.com/(?P<kind>git|svn)/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+		Check: func(m map[string]string, _ *url.URL) (Type, error) {
+			checked = m
+			return Type(m["kind"]), nil
+		},
+	})
+	// Takes precedence over the built-in rule for github.com.
+	registerTestHost(t, HostRule{
+		Host:     "github.com",
+		Pattern:  `^github\.com/example/mirror-hg$`,
+		Vcs:      Hg,
+		Priority: -1,
+	})
+
+	tests := []struct {
+		url string
+		t   Type
+		err error
+	}{
+		{"https://code.example.com/hg/foo", Hg, nil},
+		{"https://code.example.com/hg/foo/sub/pkg", Hg, nil},
+		{"https://code.example.com/git/foo", "", ErrCannotDetectVCS},
+		// The generic rule does not apply to hosts with rules.
+		{"https://code.example.com/other/foo.git", "", ErrCannotDetectVCS},
+		{"https://scm.example.com/svn/foo/trunk", Svn, nil},
+		{"https://scm.example.com/git/foo", Git, nil},
+		{"https://github.com/example/mirror-hg", Hg, nil},
+		{"https://github.com/example/other", Git, nil},
+		{"https://example.org/foo.bzr", Bzr, nil},
+	}
+	for _, tc := range tests {
+		ty, err := detectVcsFromURL(tc.url)
+		if ty != tc.t || err != tc.err {
+			t.Errorf("%s: expected %q %v, got %q %v", tc.url, tc.t, tc.err, ty, err)
+		}
+	}
+	if checked["kind"] != "git" {
+		t.Errorf("named groups not passed to Check: %v", checked)
+	}
+}
+
+func TestRegisterHostInvalid(t *testing.T) {
+	if err := RegisterHost(HostRule{Host: "example.com", Pattern: `(`, Vcs: Git}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+	if err := RegisterHost(HostRule{Host: "example.com", Pattern: `.*`}); err == nil {
+		t.Error("expected an error for a rule without a VCS")
+	}
+}
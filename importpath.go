@@ -0,0 +1,247 @@
+package vcs
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ModProxy is the Vcs of a RepoRoot for a module served by a module proxy, as
+// declared with a go-import meta tag like "example.com/mod mod
+// https://proxy.example.com". It is not a VCS a Repo can be created for.
+const ModProxy Type = "mod"
+
+// RepoRoot describes the repository an import path is in.
+type RepoRoot struct {
+	// Root is the import path of the root of the repository, such as
+	// golang.org/x/net for golang.org/x/net/context.
+	Root string
+
+	// Vcs is the VCS of the repository. It is ModProxy when the only
+	// go-import meta tag for the import path names a module proxy.
+	Vcs Type
+
+	// RepoURL is the location of the repository that can be passed to
+	// NewRepo, or the module proxy when Vcs is ModProxy.
+	RepoURL string
+
+	// Subdir is the directory within the repository that corresponds to
+	// Root. It is declared by the optional fourth field of the go-import meta
+	// tag and is usually empty.
+	Subdir string
+
+	// SourceHome, SourceDir, and SourceFile are the home page and the
+	// templates for links to directories and files in the repository from a
+	// go-source meta tag. They are empty when there is no such tag.
+	SourceHome string
+	SourceDir  string
+	SourceFile string
+}
+
+// ResolveImportPath finds the repository a Go import path, such as
+// golang.org/x/net/context, is in. Paths on hosts known to the host rules,
+// such as github.com, are resolved without network access. Otherwise the
+// go-import and go-source meta tags served at https://<path>?go-get=1 are
+// used following the rules of the go command:
+//
+//   - The tag with the longest prefix of the import path is used.
+//   - More than one tag for that prefix is an error, except that a tag for a
+//     VCS is preferred over one naming a module proxy.
+//   - When the prefix is shorter than the import path the tags served for the
+//     prefix must agree.
+//
+// The opts are used to configure the requests, such as with WithHTTPClient.
+func ResolveImportPath(path string, opts ...Option) (*RepoRoot, error) {
+	return ResolveImportPathContext(context.Background(), path, opts...)
+}
+
+// ResolveImportPathContext is the context-aware version of ResolveImportPath.
+func ResolveImportPathContext(ctx context.Context, path string, opts ...Option) (*RepoRoot, error) {
+	path = strings.TrimSuffix(path, "/")
+
+	u, err := parseRemote("https://" + path)
+	if err != nil {
+		return nil, err
+	}
+	if t, root, found, err := detectVcsFromHost(u); found {
+		if err != nil {
+			return nil, err
+		}
+		return &RepoRoot{Root: root, Vcs: t, RepoURL: "https://" + root}, nil
+	}
+
+	return resolveMeta(ctx, newConfig(opts).httpClient, "https", path)
+}
+
+// resolveMeta resolves importPath using the meta tags served for it over
+// scheme.
+func resolveMeta(ctx context.Context, client *http.Client, scheme, importPath string) (*RepoRoot, error) {
+	imports, sources, err := fetchMeta(ctx, client, scheme+"://"+importPath+"?go-get=1")
+	if err != nil {
+		return nil, err
+	}
+	im, err := matchGoImport(imports, importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the root agrees. Otherwise any page could claim to be the
+	// root of an import path below it.
+	if im.Prefix != importPath {
+		rootImports, _, err := fetchMeta(ctx, client, scheme+"://"+im.Prefix+"?go-get=1")
+		if err != nil {
+			return nil, err
+		}
+		rim, err := matchGoImport(rootImports, im.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		if rim != im {
+			return nil, NewRemoteError("The go-import meta tags of the import path and its root do not match", nil,
+				fmt.Sprintf("%s: %s %s %s\n%s: %s %s %s", importPath, im.Prefix, im.Vcs, im.RepoURL, im.Prefix, rim.Prefix, rim.Vcs, rim.RepoURL))
+		}
+	}
+
+	rr := &RepoRoot{Root: im.Prefix, Vcs: im.Vcs, RepoURL: im.RepoURL, Subdir: im.Subdir}
+	var src *metaSource
+	for i := range sources {
+		s := &sources[i]
+		if hasPathPrefix(importPath, s.Prefix) && (src == nil || len(s.Prefix) > len(src.Prefix)) {
+			src = s
+		}
+	}
+	if src != nil {
+		rr.SourceHome, rr.SourceDir, rr.SourceFile = src.Home, src.Dir, src.File
+	}
+	return rr, nil
+}
+
+// metaImport is the content of a go-import meta tag.
+type metaImport struct {
+	Prefix  string
+	Vcs     Type
+	RepoURL string
+	Subdir  string
+}
+
+// metaSource is the content of a go-source meta tag.
+type metaSource struct {
+	Prefix, Home, Dir, File string
+}
+
+// fetchMeta requests url and parses the meta tags from the response.
+func fetchMeta(ctx context.Context, client *http.Client, url string) ([]metaImport, []metaSource, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, NewRemoteError("Unable to fetch go-import meta tags", contextErr(ctx, err), "")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// As with the go command the tags are used even from an error page.
+	imports, sources, err := parseMetaGoImports(resp.Body)
+	if len(imports) == 0 {
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, NewRemoteError("Unable to fetch go-import meta tags", err, url+": "+resp.Status)
+		}
+		return nil, nil, ErrCannotDetectVCS
+	}
+	return imports, sources, nil
+}
+
+// parseMetaGoImports returns the go-import and go-source meta tags in the head
+// of the HTML document read from r.
+func parseMetaGoImports(r io.Reader) (imports []metaImport, sources []metaSource, err error) {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charsetReader
+	d.Strict = false
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if err == io.EOF || len(imports) > 0 {
+				err = nil
+			}
+			return imports, sources, err
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return imports, sources, nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return imports, sources, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") {
+			continue
+		}
+
+		f := strings.Fields(attrValue(e.Attr, "content"))
+		switch attrValue(e.Attr, "name") {
+		case "go-import":
+			if len(f) == 3 || len(f) == 4 {
+				im := metaImport{Prefix: f[0], Vcs: Type(f[1]), RepoURL: f[2]}
+				if len(f) == 4 {
+					im.Subdir = f[3]
+				}
+				imports = append(imports, im)
+			}
+		case "go-source":
+			if len(f) == 4 {
+				sources = append(sources, metaSource{Prefix: f[0], Home: f[1], Dir: f[2], File: f[3]})
+			}
+		}
+	}
+}
+
+// matchGoImport returns the go-import tag for importPath. The tag with the
+// longest prefix of the path is used. A tag for a VCS is preferred over a tag
+// for a module proxy with the same prefix. Other tags with the same prefix
+// are ambiguous unless they are duplicates.
+func matchGoImport(imports []metaImport, importPath string) (metaImport, error) {
+	var match *metaImport
+	ambiguous := false
+	for i := range imports {
+		im := &imports[i]
+		if !hasPathPrefix(importPath, im.Prefix) {
+			continue
+		}
+		switch {
+		case match == nil || len(im.Prefix) > len(match.Prefix):
+			match, ambiguous = im, false
+		case len(im.Prefix) < len(match.Prefix), *im == *match:
+		case match.Vcs == ModProxy && im.Vcs != ModProxy:
+			match, ambiguous = im, false
+		case im.Vcs == ModProxy && match.Vcs != ModProxy:
+		default:
+			ambiguous = true
+		}
+	}
+
+	if match == nil {
+		return metaImport{}, ErrCannotDetectVCS
+	}
+	if ambiguous {
+		return metaImport{}, NewRemoteError("Multiple go-import meta tags match the import path", nil, importPath)
+	}
+	return *match, nil
+}
+
+// hasPathPrefix reports whether the slash separated path s begins with the
+// elements in prefix.
+func hasPathPrefix(s, prefix string) bool {
+	if len(s) == len(prefix) {
+		return s == prefix
+	}
+	if prefix == "" {
+		return true
+	}
+	return strings.HasPrefix(s, prefix) && (s[len(prefix)] == '/' || prefix[len(prefix)-1] == '/')
+}
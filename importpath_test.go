@@ -0,0 +1,133 @@
+package vcs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveImportPath(t *testing.T) {
+	// pages maps a path to the meta tags served for it. The host is filled in
+	// for %[1]s.
+	pages := map[string]string{
+		"/net": `<meta name="go-import" content="%[1]s/net git https://git.example.com/net">
+			<meta name="go-source" content="%[1]s/net https://git.example.com/net https://git.example.com/net/tree{/dir} https://git.example.com/net/blob{/dir}/{file}#L{line}">`,
+		"/net/context": `<meta name="go-import" content="%[1]s/net git https://git.example.com/net">`,
+		"/mono/tools": `<meta name="go-import" content="%[1]s/mono git https://git.example.com/mono">
+			<meta name="go-import" content="%[1]s/mono/tools git https://git.example.com/mono tools">`,
+		"/both": `<meta name="go-import" content="%[1]s/both mod https://proxy.example.com">
+			<meta name="go-import" content="%[1]s/both hg https://hg.example.com/both">`,
+		"/proxy":    `<meta name="go-import" content="%[1]s/proxy mod https://proxy.example.com">`,
+		"/dup":      `<meta name="go-import" content="%[1]s/dup git https://git.example.com/a"><meta name="go-import" content="%[1]s/dup git https://git.example.com/a">`,
+		"/amb":      `<meta name="go-import" content="%[1]s/amb git https://git.example.com/a"><meta name="go-import" content="%[1]s/amb hg https://hg.example.com/a">`,
+		"/liar/pkg": `<meta name="go-import" content="%[1]s/liar git https://evil.example.com/liar">`,
+		"/liar":     `<meta name="go-import" content="%[1]s/liar git https://git.example.com/liar">`,
+		"/body":     `</head><body><meta name="go-import" content="%[1]s/body git https://git.example.com/body">`,
+	}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("go-get") != "1" {
+			t.Errorf("go-get=1 missing from %s", r.URL)
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "<!DOCTYPE html><html><head>"+page+"</head><body>Hi</body></html>", r.Host)
+	}))
+	defer srv.Close()
+	host := srv.Listener.Addr().String()
+
+	tests := []struct {
+		path string
+		rr   RepoRoot
+		err  string
+	}{
+		{path: "/net", rr: RepoRoot{Root: "/net", Vcs: Git, RepoURL: "https://git.example.com/net",
+			SourceHome: "https://git.example.com/net", SourceDir: "https://git.example.com/net/tree{/dir}", SourceFile: "https://git.example.com/net/blob{/dir}/{file}#L{line}"}},
+		{path: "/net/context", rr: RepoRoot{Root: "/net", Vcs: Git, RepoURL: "https://git.example.com/net"}},
+		{path: "/mono/tools", rr: RepoRoot{Root: "/mono/tools", Vcs: Git, RepoURL: "https://git.example.com/mono", Subdir: "tools"}},
+		{path: "/both", rr: RepoRoot{Root: "/both", Vcs: Hg, RepoURL: "https://hg.example.com/both"}},
+		{path: "/proxy", rr: RepoRoot{Root: "/proxy", Vcs: ModProxy, RepoURL: "https://proxy.example.com"}},
+		{path: "/dup", rr: RepoRoot{Root: "/dup", Vcs: Git, RepoURL: "https://git.example.com/a"}},
+		{path: "/amb", err: "Multiple go-import meta tags"},
+		{path: "/liar/pkg", err: "do not match"},
+		{path: "/body", err: ErrCannotDetectVCS.Error()},
+		{path: "/missing", err: "Unable to fetch"},
+	}
+	for _, tc := range tests {
+		rr, err := ResolveImportPath(host+tc.path, WithHTTPClient(srv.Client()))
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tc.path, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tc.path, err)
+			continue
+		}
+		tc.rr.Root = host + tc.rr.Root
+		if *rr != tc.rr {
+			t.Errorf("%s: expected %+v, got %+v", tc.path, tc.rr, *rr)
+		}
+	}
+}
+
+func TestResolveImportPathHostRules(t *testing.T) {
+	rr, err := ResolveImportPath("github.com/Masterminds/vcs/vcstest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := RepoRoot{Root: "github.com/Masterminds/vcs", Vcs: Git, RepoURL: "https://github.com/Masterminds/vcs"}
+	if *rr != expected {
+		t.Errorf("expected %+v, got %+v", expected, *rr)
+	}
+
+	if _, err := ResolveImportPath("github.com/Masterminds"); err != ErrCannotDetectVCS {
+		t.Errorf("expected ErrCannotDetectVCS, got %v", err)
+	}
+
+	// The root of a GitLab repo in a subgroup is found with the meta tags.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<meta name="go-import" content="gitlab.com/group/subgroup/repo git https://gitlab.com/group/subgroup/repo.git">`)
+	}))
+	defer srv.Close()
+	base := srv.Client().Transport
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host != "gitlab.com" {
+			t.Errorf("unexpected request to %s", r.URL)
+		}
+		r = r.Clone(r.Context())
+		r.URL.Host = srv.Listener.Addr().String()
+		return base.RoundTrip(r)
+	})}
+	rr, err = ResolveImportPath("gitlab.com/group/subgroup/repo/pkg", WithHTTPClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = RepoRoot{Root: "gitlab.com/group/subgroup/repo", Vcs: Git, RepoURL: "https://gitlab.com/group/subgroup/repo.git"}
+	if *rr != expected {
+		t.Errorf("expected %+v, got %+v", expected, *rr)
+	}
+}
+
+func TestHasPathPrefix(t *testing.T) {
+	tests := []struct {
+		s, prefix string
+		want      bool
+	}{
+		{"example.com/a/b", "example.com/a", true},
+		{"example.com/a", "example.com/a", true},
+		{"example.com/ab", "example.com/a", false},
+		{"example.com/a", "example.com/a/b", false},
+		{"example.com/a", "example.com/", true},
+	}
+	for _, tc := range tests {
+		if got := hasPathPrefix(tc.s, tc.prefix); got != tc.want {
+			t.Errorf("hasPathPrefix(%q, %q) = %v", tc.s, tc.prefix, got)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogOptions selects the commits listed by Log. The zero value lists every
+// commit reachable from the checked out revision, newest first.
+type LogOptions struct {
+	// From excludes the commits reachable from it, such as the tag of the
+	// previous release. With To it selects a range of revisions.
+	From string
+
+	// To is the revision the history is listed from. It defaults to the
+	// checked out revision.
+	To string
+
+	// Path limits the commits to those that changed the file or directory,
+	// relative to the root of the working copy.
+	Path string
+
+	// Author limits the commits to those whose Author contains it, ignoring
+	// case.
+	Author string
+
+	// Since and Until limit the commits to those with a CommitDate in the
+	// window. A zero time leaves that end of the window open.
+	Since, Until time.Time
+
+	// Max is the most commits to list. Zero means there is no limit.
+	Max int
+
+	// FirstParent follows only the first parent of merge commits. Svn has no
+	// merge commits and ignores it.
+	FirstParent bool
+}
+
+// HistoryRepo is the optional interface of repos that can list their
+// commits.
+type HistoryRepo interface {
+	// Log returns an iterator over the commits selected by opts, newest
+	// first. The commits are read from the VCS as the iteration progresses
+	// and stopping the iteration stops the VCS. An error ends the iteration.
+	Log(opts LogOptions) iter.Seq2[*CommitInfo, error]
+
+	// LogContext is the context-aware version of Log.
+	LogContext(ctx context.Context, opts LogOptions) iter.Seq2[*CommitInfo, error]
+}
+
+// matches reports whether ci passes the Author, Since, and Until filters of
+// o. The VCS applies them where it can so this is a final check of the
+// commits it lists.
+func (o *LogOptions) matches(ci *CommitInfo) bool {
+	if o.Author != "" && !strings.Contains(strings.ToLower(ci.Author), strings.ToLower(o.Author)) {
+		return false
+	}
+	if !o.Since.IsZero() && ci.CommitDate.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && ci.CommitDate.After(o.Until) {
+		return false
+	}
+	return true
+}
+
+// limit returns the number of commits to ask the VCS for, or an empty string
+// when there is no Max. extra is added for commits the caller will skip.
+// native reports whether the VCS applies all of the Author, Since, and Until
+// filters itself. Otherwise Max cannot be applied by the VCS as commits are
+// filtered afterwards.
+func (o *LogOptions) limit(extra int, native bool) string {
+	if o.Max <= 0 || !native {
+		return ""
+	}
+	return strconv.Itoa(o.Max + extra)
+}
+
+// window returns Since and Until in seconds since the Unix epoch, the
+// precision the VCS record times with, rounded to stay inside the window.
+func (o *LogOptions) window() (since, until int64) {
+	since, until = o.Since.Unix(), o.Until.Unix()
+	if o.Since.Nanosecond() > 0 {
+		since++
+	}
+	return since, until
+}
+
+// errStopLog is returned by the parsers of log output when the iteration
+// over the commits stops early.
+var errStopLog = errors.New("log iteration stopped")
+
+// logSeq returns an iterator over the commits produced by run. run passes
+// each commit to emit and returns errStopLog when emit returns false. The
+// Author, Since, Until, and Max filters of opts are checked for the commits.
+func logSeq(ctx context.Context, opts LogOptions, run func(ctx context.Context, emit func(*CommitInfo) bool) error) iter.Seq2[*CommitInfo, error] {
+	return func(yield func(*CommitInfo, error) bool) {
+		n := 0
+		stopped := false
+		err := run(ctx, func(ci *CommitInfo) bool {
+			if !opts.matches(ci) {
+				return true
+			}
+			if !yield(ci, nil) {
+				stopped = true
+				return false
+			}
+			n++
+			return opts.Max <= 0 || n < opts.Max
+		})
+		if err != nil && !stopped {
+			yield(nil, err)
+		}
+	}
+}
+
+// streamFromDir runs a command in the local directory and passes its
+// standard output to read as it is produced. When read returns errStopLog
+// the command is stopped and nil is returned.
+func (b *base) streamFromDir(ctx context.Context, read func(io.Reader) error, cmd string, args ...string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	stderr := &cappedBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		err := b.exec(ctx, &Command{
+			Name:   cmd,
+			Args:   args,
+			Dir:    b.local,
+			Env:    envForDir(b.local),
+			Stdout: pw,
+			Stderr: stderr,
+		})
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	rerr := read(pr)
+	cancel()
+	_ = pr.Close()
+	err := <-done
+
+	switch {
+	case rerr == errStopLog:
+		return nil
+	case err != nil:
+		return NewLocalError("Unable to retrieve commit history", err, stderr.String())
+	case rerr != nil:
+		return NewLocalError("Unable to retrieve commit history", rerr, "")
+	}
+	return nil
+}
+
+// scanRecords is a bufio.SplitFunc that splits input at record separator
+// characters.
+func scanRecords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, '\x1e'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// decodeLogEntries calls decode for each logentry element in the XML log read
+// from r, as produced by svn.
+func decodeLogEntries(r io.Reader, decode func(d *xml.Decoder, start *xml.StartElement) error) error {
+	d := xml.NewDecoder(r)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if se, ok := t.(xml.StartElement); ok && se.Name.Local == "logentry" {
+			if err := decode(d, &se); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package vcs
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGitLog(t *testing.T) {
+	alice, bob := "Alice <alice@example.com>", "Bob <bob@example.com>"
+	repo, ids := newGitTestRepo(t,
+		gitTestCommit{Author: alice, Files: map[string]string{"a.txt": "a\n"}},
+		gitTestCommit{Author: bob, Files: map[string]string{"b/b.txt": "b\n"}},
+		gitTestCommit{Author: alice, Files: map[string]string{"a.txt": "c\n"}},
+		gitTestCommit{Author: bob, Files: map[string]string{"a.txt": "d\n"}, Message: "Commit 4\n\nSigned-off-by: " + bob},
+	)
+
+	date := func(i int) time.Time {
+		return gitTestDate.Add(time.Duration(i) * time.Hour)
+	}
+	tests := []struct {
+		name     string
+		opts     LogOptions
+		expected []int
+	}{
+		{"all", LogOptions{}, []int{3, 2, 1, 0}},
+		{"from", LogOptions{From: ids[0]}, []int{3, 2, 1}},
+		{"range", LogOptions{From: ids[0], To: ids[2]}, []int{2, 1}},
+		{"path", LogOptions{Path: "b"}, []int{1}},
+		{"author", LogOptions{Author: "alice"}, []int{2, 0}},
+		{"window", LogOptions{Since: date(1), Until: date(2)}, []int{2, 1}},
+		{"max", LogOptions{Max: 2}, []int{3, 2}},
+		{"max from", LogOptions{From: ids[0], Max: 3}, []int{3, 2, 1}},
+		{"max author", LogOptions{Author: "ALICE", Max: 1}, []int{2}},
+		{"max window", LogOptions{Since: date(1), Max: 2}, []int{3, 2}},
+		{"email", LogOptions{Author: "bob@EXAMPLE.com"}, []int{3, 1}},
+		{"regexp", LogOptions{Author: "a.ice"}, nil},
+	}
+	for _, tc := range tests {
+		var got []string
+		for ci, err := range repo.Log(tc.opts) {
+			if err != nil {
+				t.Fatalf("%s: %s", tc.name, err)
+			}
+			got = append(got, ci.Commit)
+		}
+		var expected []string
+		for _, i := range tc.expected {
+			expected = append(expected, ids[i])
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, expected, got)
+		}
+	}
+
+	// The entries are the same as those of CommitInfo.
+	for ci, err := range repo.Log(LogOptions{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := repo.CommitInfo(ci.Commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ci.Author != expected.Author || !ci.Date.Equal(expected.Date) || ci.Message != expected.Message ||
+			!slices.Equal(ci.Parents, expected.Parents) || !slices.Equal(ci.Trailers, expected.Trailers) {
+			t.Errorf("expected %+v, got %+v", expected, ci)
+		}
+	}
+
+	ci, err := repo.CommitInfo(ids[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ci.Parents, []string{ids[2]}) {
+		t.Errorf("expected the parent %s, got %v", ids[2], ci.Parents)
+	}
+	if len(ci.Trailers) != 1 || ci.Trailers[0] != (Trailer{Key: "Signed-off-by", Value: bob}) {
+		t.Errorf("unexpected trailers %v", ci.Trailers)
+	}
+
+	n := 0
+	for _, err := range repo.LogContext(context.Background(), LogOptions{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("expected the iteration to stop after 1 commit, got %d", n)
+	}
+
+	for _, err := range repo.Log(LogOptions{To: "does-not-exist"}) {
+		if err == nil {
+			t.Error("expected an error for an unknown revision")
+		}
+	}
+}
+
+// TestGitLogCommitDate checks Since and Until select commits by the time they
+// were committed rather than authored.
+func TestGitLogCommitDate(t *testing.T) {
+	rebased := gitTestDate.Add(48 * time.Hour)
+	repo, ids := newGitTestRepo(t,
+		gitTestCommit{Files: map[string]string{"a.txt": "a\n"}},
+		gitTestCommit{Files: map[string]string{"a.txt": "b\n"}, CommitDate: rebased},
+	)
+
+	tests := []struct {
+		opts     LogOptions
+		expected []string
+	}{
+		{LogOptions{Until: gitTestDate.Add(time.Hour)}, []string{ids[0]}},
+		{LogOptions{Since: rebased, Until: rebased}, []string{ids[1]}},
+		{LogOptions{Since: rebased.Add(time.Second)}, nil},
+	}
+	for _, tc := range tests {
+		var got []string
+		for ci, err := range repo.Log(tc.opts) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, ci.Commit)
+		}
+		if !slices.Equal(got, tc.expected) {
+			t.Errorf("%+v: expected %v, got %v", tc.opts, tc.expected, got)
+		}
+	}
+}
+
+func TestGitLogFilters(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{}}
+	repo := &GitRepo{}
+	repo.setLocalPath(t.TempDir())
+	repo.Runner = f
+
+	since := time.Date(2015, 7, 29, 13, 46, 39, 500, time.UTC)
+	for _, err := range repo.Log(LogOptions{Author: "alice", Since: since, Until: since.Add(time.Hour), Max: 2}) {
+		if err == nil {
+			t.Fatal("expected an error from the fake runner")
+		}
+	}
+	if len(f.calls) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(f.calls))
+	}
+	expected := []string{"log", "--format=" + gitCommitFormat + "%x1e", "--fixed-strings", "--regexp-ignore-case", "--author=alice",
+		"--since=@1438177600", "--until=@1438181199", "-n", "2", "HEAD", "--"}
+	if !slices.Equal(f.calls[0].Args, expected) {
+		t.Errorf("expected %q, got %q", expected, f.calls[0].Args)
+	}
+}
+
+func TestGitLogFirstParent(t *testing.T) {
+	repo, ids := newGitTestRepo(t,
+		gitTestCommit{Files: map[string]string{"a.txt": "a\n"}},
+		gitTestCommit{Branch: "side", Files: map[string]string{"b.txt": "b\n"}},
+		gitTestCommit{Files: map[string]string{"a.txt": "c\n"}},
+	)
+	out, err := exec.Command("git", "-C", repo.LocalPath(), "-c", "user.name=Test", "-c", "user.email=test@example.com",
+		"merge", "--no-ff", "-m", "Merge side", "origin/side").CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	merge, err := repo.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for ci, err := range repo.Log(LogOptions{FirstParent: true}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ci.Commit)
+	}
+	expected := []string{merge, ids[2], ids[0]}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	ci, err := repo.CommitInfo(merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parents := []string{ids[2], ids[1]}; !slices.Equal(ci.Parents, parents) {
+		t.Errorf("expected the parents %v, got %v", parents, ci.Parents)
+	}
+	if ci.CommitterName != "Test" || ci.CommitterEmail != "test@example.com" {
+		t.Errorf("unexpected committer %s <%s>", ci.CommitterName, ci.CommitterEmail)
+	}
+}
+
+func TestGitRevisionOptions(t *testing.T) {
+	repo, ids := newGitTestRepo(t, gitTestCommit{Files: map[string]string{"a.txt": "a\n"}})
+
+	// A revision starting with a dash must not be read by git as an option.
+	out := filepath.Join(t.TempDir(), "out")
+	rev := "--output=" + out
+	for _, opts := range []LogOptions{{To: rev}, {From: rev}} {
+		for _, err := range repo.Log(opts) {
+			if err != ErrRevisionUnavailable {
+				t.Errorf("%+v: expected ErrRevisionUnavailable, got %v", opts, err)
+			}
+		}
+	}
+	if err := repo.WriteDiff(io.Discard, rev, ""); err != ErrRevisionUnavailable {
+		t.Errorf("expected ErrRevisionUnavailable, got %v", err)
+	}
+	if err := repo.WriteDiff(io.Discard, ids[0], rev); err != ErrRevisionUnavailable {
+		t.Errorf("expected ErrRevisionUnavailable, got %v", err)
+	}
+	if _, err := repo.ReadFile(rev, "a.txt"); err != ErrRevisionUnavailable {
+		t.Errorf("expected ErrRevisionUnavailable, got %v", err)
+	}
+	if _, err := repo.CommitInfo(rev); err != ErrRevisionUnavailable {
+		t.Errorf("expected ErrRevisionUnavailable, got %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("expected git not to write %s, got %v", out, err)
+	}
+}
@@ -0,0 +1,69 @@
+package vcs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// maxLoggedOutput is the most command output, in bytes, included in a log
+// record. Output from commands such as git show-ref can be very large.
+const maxLoggedOutput = 1024
+
+// slogger returns the structured logger for the repo.
+func (b *base) slogger() *slog.Logger {
+	if b.Slog != nil {
+		return b.Slog
+	}
+	return slog.Default()
+}
+
+// logCommand emits a debug record for a command run by the repo. The record
+// is passed ctx so handlers can add values, such as a request id, from it.
+func (b *base) logCommand(ctx context.Context, c *Command, start time.Time, out *cappedBuffer, err error) {
+	code := 0
+	if err != nil {
+		code = exitCode(err)
+	}
+	attrs := []slog.Attr{
+		slog.String("vcs", string(b.vcsType)),
+		slog.String("cmd", c.Name),
+		slog.Any("args", c.Args),
+		slog.String("dir", c.Dir),
+		slog.String("local", b.local),
+		slog.String("remote", b.remote),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("exit_code", code),
+		slog.String("output", out.String()),
+	}
+	if out.truncated() {
+		attrs = append(attrs, slog.Bool("output_truncated", true))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	b.slogger().LogAttrs(ctx, slog.LevelDebug, "vcs command", attrs...)
+}
+
+// cappedBuffer is a writer that keeps the first maxLoggedOutput bytes written
+// to it and discards the rest.
+type cappedBuffer struct {
+	buf []byte
+	n   int
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	c.n += len(p)
+	if room := maxLoggedOutput - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (c *cappedBuffer) String() string {
+	return string(c.buf)
+}
+
+func (c *cappedBuffer) truncated() bool {
+	return c.n > len(c.buf)
+}
@@ -0,0 +1,88 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogCommandRecords(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"git rev-parse HEAD": {out: "806b07b08faa21cfbdae93027904f80174679402\n"},
+		"git show-ref":       {out: strings.Repeat("x", 2*maxLoggedOutput), code: 1},
+	}}
+	useRunner(t, f)
+
+	local := t.TempDir()
+	repo, err := NewGitRepo("https://example.com/foo.git", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	repo.Slog = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	if _, err := repo.Version(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.TagsContext(context.Background()); err == nil {
+		t.Fatal("expected the failing command to return an error")
+	}
+
+	var records []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		r := map[string]any{}
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected a record per command, got %d: %s", len(records), buf.String())
+	}
+
+	r := records[0]
+	if r["msg"] != "vcs command" || r["vcs"] != "git" || r["cmd"] != "git" || r["dir"] != local {
+		t.Errorf("unexpected record: %v", r)
+	}
+	if args, _ := r["args"].([]any); len(args) != 2 || args[0] != "rev-parse" {
+		t.Errorf("args not recorded: %v", r["args"])
+	}
+	if r["exit_code"] != 0.0 || r["output"] != "806b07b08faa21cfbdae93027904f80174679402\n" {
+		t.Errorf("result not recorded: %v", r)
+	}
+	if _, ok := r["duration"]; !ok {
+		t.Error("duration not recorded")
+	}
+
+	r = records[1]
+	if r["exit_code"] != 1.0 || r["error"] == nil || r["output_truncated"] != true {
+		t.Errorf("failure not recorded: %v", r)
+	}
+	if out, _ := r["output"].(string); len(out) != maxLoggedOutput {
+		t.Errorf("output not truncated to %d bytes, got %d", maxLoggedOutput, len(out))
+	}
+}
+
+func TestSlogDisabled(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"git rev-parse HEAD": {out: "806b07b08faa21cfbdae93027904f80174679402\n"},
+	}}
+	useRunner(t, f)
+
+	repo, err := NewGitRepo("https://example.com/foo.git", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	repo.Slog = slog.New(slog.NewTextHandler(&buf, nil))
+
+	if _, err := repo.Version(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("records written below the handler level: %s", buf.String())
+	}
+}
@@ -0,0 +1,148 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Module describes where the source of a Go module is found.
+type Module struct {
+	// Path is the module path, such as github.com/foo/bar/v3.
+	Path string
+
+	// Major is the major version suffix of the module path, such as /v3 or
+	// .v3 for gopkg.in. It is empty for modules at v0 or v1.
+	Major string
+
+	// Root, Vcs, and RepoURL describe the repository the module is in. See
+	// RepoRoot.
+	Root    string
+	Vcs     Type
+	RepoURL string
+
+	// Subdir is the directory within the repository that holds the module.
+	// It is empty when the module is at the root of the repository.
+	Subdir string
+
+	// MajorSubdir is the directory used instead of Subdir when the module
+	// follows the major subdirectory convention, such as v3 for
+	// github.com/foo/bar/v3. The go command uses it when it contains a go.mod
+	// for the module. It is empty when the module path has no /vN suffix.
+	MajorSubdir string
+
+	// TagPrefix is prepended to a version to get the tag for it, such as
+	// sub/dir/ for the tag sub/dir/v3.1.0. It is empty when the module is at
+	// the root of the repository.
+	TagPrefix string
+}
+
+// Tag returns the tag for version, such as v3.1.0, of the module.
+func (m *Module) Tag(version string) string {
+	return m.TagPrefix + version
+}
+
+// ResolveModule finds the repository of the Go module with the module path
+// modPath, such as github.com/foo/bar/v3, gopkg.in/yaml.v3, or a vanity path
+// served with go-import meta tags. The repository is found as
+// ResolveImportPath does and the opts are used the same way.
+// ErrInvalidModulePath is returned when the major version suffix of the path
+// is not valid.
+//
+// When the only go-import meta tag names a module proxy the Vcs of the
+// Module is ModProxy and only Path, Major, Root, and RepoURL are set.
+func ResolveModule(modPath string, opts ...Option) (*Module, error) {
+	return ResolveModuleContext(context.Background(), modPath, opts...)
+}
+
+// ResolveModuleContext is the context-aware version of ResolveModule.
+func ResolveModuleContext(ctx context.Context, modPath string, opts ...Option) (*Module, error) {
+	modPath = strings.TrimSuffix(modPath, "/")
+	prefix, major, ok := splitPathVersion(modPath)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidModulePath, modPath)
+	}
+
+	rr, err := ResolveImportPathContext(ctx, modPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Module{
+		Path:    modPath,
+		Major:   major,
+		Root:    rr.Root,
+		Vcs:     rr.Vcs,
+		RepoURL: rr.RepoURL,
+	}
+	if rr.Vcs == ModProxy {
+		return m, nil
+	}
+
+	// The major version suffix is not part of the directory in the repo,
+	// except in the major subdirectory convention. When the root of the repo
+	// includes the suffix, as for gopkg.in, the module is at the root.
+	var dir string
+	if len(prefix) > len(rr.Root) {
+		dir = prefix[len(rr.Root)+1:]
+	}
+	if rr.Subdir != "" {
+		dir = path.Join(rr.Subdir, dir)
+	}
+	m.Subdir = dir
+	if dir != "" {
+		m.TagPrefix = dir + "/"
+	}
+	if strings.HasPrefix(major, "/") && len(modPath) > len(rr.Root) {
+		m.MajorSubdir = path.Join(dir, major[1:])
+	}
+	return m, nil
+}
+
+// splitPathVersion splits the major version suffix from a module path. For
+// most paths it is /vN, with N at least 2. For gopkg.in paths it is .vN or
+// .vN-unstable. ok is false when the suffix is not valid, such as /v1 or /v02.
+// This follows SplitPathVersion from golang.org/x/mod/module.
+func splitPathVersion(modPath string) (prefix, major string, ok bool) {
+	if strings.HasPrefix(modPath, "gopkg.in/") {
+		return splitGopkgIn(modPath)
+	}
+
+	i := len(modPath)
+	dot := false
+	for i > 0 && ('0' <= modPath[i-1] && modPath[i-1] <= '9' || modPath[i-1] == '.') {
+		if modPath[i-1] == '.' {
+			dot = true
+		}
+		i--
+	}
+	if i <= 1 || i == len(modPath) || modPath[i-1] != 'v' || modPath[i-2] != '/' {
+		return modPath, "", true
+	}
+	prefix, major = modPath[:i-2], modPath[i-2:]
+	if dot || len(major) <= 2 || major[2] == '0' || major == "/v1" {
+		return modPath, "", false
+	}
+	return prefix, major, true
+}
+
+// splitGopkgIn is splitPathVersion for gopkg.in paths, which always have a
+// major version suffix.
+func splitGopkgIn(modPath string) (prefix, major string, ok bool) {
+	i := len(modPath)
+	if strings.HasSuffix(modPath, "-unstable") {
+		i -= len("-unstable")
+	}
+	for i > 0 && '0' <= modPath[i-1] && modPath[i-1] <= '9' {
+		i--
+	}
+	if i <= 1 || modPath[i-1] != 'v' || modPath[i-2] != '.' {
+		return modPath, "", false
+	}
+	prefix, major = modPath[:i-2], modPath[i-2:]
+	if len(major) <= 2 || major[2] == '0' && major != ".v0" {
+		return modPath, "", false
+	}
+	return prefix, major, true
+}
@@ -0,0 +1,70 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveModule(t *testing.T) {
+	tests := []struct {
+		path string
+		m    Module
+	}{
+		{"github.com/foo/bar", Module{Root: "github.com/foo/bar"}},
+		{"github.com/foo/bar/v3", Module{Major: "/v3", Root: "github.com/foo/bar", MajorSubdir: "v3"}},
+		{"github.com/foo/bar/sub/dir", Module{Root: "github.com/foo/bar", Subdir: "sub/dir", TagPrefix: "sub/dir/"}},
+		{"github.com/foo/bar/sub/dir/v3", Module{Major: "/v3", Root: "github.com/foo/bar", Subdir: "sub/dir", MajorSubdir: "sub/dir/v3", TagPrefix: "sub/dir/"}},
+		{"gopkg.in/yaml.v3", Module{Major: ".v3", Root: "gopkg.in/yaml.v3"}},
+		{"gopkg.in/user/pkg.v2-unstable", Module{Major: ".v2-unstable", Root: "gopkg.in/user/pkg.v2-unstable"}},
+	}
+	for _, tc := range tests {
+		m, err := ResolveModule(tc.path)
+		if err != nil {
+			t.Errorf("%s: %s", tc.path, err)
+			continue
+		}
+		tc.m.Path = tc.path
+		tc.m.Vcs = Git
+		tc.m.RepoURL = "https://" + tc.m.Root
+		if *m != tc.m {
+			t.Errorf("%s: expected %+v, got %+v", tc.path, tc.m, *m)
+		}
+	}
+
+	for _, p := range []string{"github.com/foo/bar/v1", "github.com/foo/bar/v02", "gopkg.in/yaml", "gopkg.in/yaml.v03"} {
+		if _, err := ResolveModule(p); !errors.Is(err, ErrInvalidModulePath) {
+			t.Errorf("%s: expected ErrInvalidModulePath, got %v", p, err)
+		}
+	}
+}
+
+func TestResolveModuleVanity(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s/tools/v2 git https://git.example.com/mono tools"></head></html>`, r.Host)
+	}))
+	defer srv.Close()
+	host := srv.Listener.Addr().String()
+
+	m, err := ResolveModule(host+"/tools/v2", WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Module{
+		Path:      host + "/tools/v2",
+		Major:     "/v2",
+		Root:      host + "/tools/v2",
+		Vcs:       Git,
+		RepoURL:   "https://git.example.com/mono",
+		Subdir:    "tools",
+		TagPrefix: "tools/",
+	}
+	if *m != expected {
+		t.Errorf("expected %+v, got %+v", expected, *m)
+	}
+	if tag := m.Tag("v2.1.0"); tag != "tools/v2.1.0" {
+		t.Errorf("unexpected tag %s", tag)
+	}
+}
@@ -0,0 +1,322 @@
+package vcs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Limits on the contents of a module zip file enforced by the go command.
+const (
+	maxModuleZipSize = 500 << 20
+	maxGoModSize     = 16 << 20
+	maxLicenseSize   = 16 << 20
+)
+
+// ModuleExporter is implemented by repos that can export the checked out
+// revision as a Go module. BzrRepo, GitRepo, HgRepo, and SvnRepo implement
+// it.
+type ModuleExporter interface {
+	// ExportModuleZipContext writes the module zip file for the module
+	// modulePath at version, found in subdir of the repo, to w.
+	ExportModuleZipContext(ctx context.Context, w io.Writer, modulePath, version, subdir string) error
+
+	// DirHashContext returns the h1: hash of the module zip file written by
+	// ExportModuleZipContext.
+	DirHashContext(ctx context.Context, modulePath, version, subdir string) (string, error)
+}
+
+// moduleFile is a file in a module zip.
+type moduleFile struct {
+	// name is the slash separated path of the file within the module.
+	name string
+
+	// path is the location of the file on disk.
+	path string
+}
+
+// exportModule exports the source of a repo with export and returns the files
+// of the module in subdir, following the rules of golang.org/x/mod/zip:
+//
+//   - Directories of other VCS metadata are skipped.
+//   - Directories containing a go.mod file, other than subdir, hold other
+//     modules and are skipped.
+//   - Files in packages within vendor directories are skipped.
+//   - Files that are not regular, such as symbolic links, are skipped.
+//   - When subdir has no LICENSE file the one at the root of the repo is
+//     used.
+//
+// The exported files are removed by calling cleanup.
+func exportModule(ctx context.Context, export func(context.Context, string) error, subdir string) (files []moduleFile, cleanup func(), err error) {
+	tmp, err := os.MkdirTemp("", "go-vcs-module")
+	if err != nil {
+		return nil, nil, NewLocalError("Unable to create directory", err, "")
+	}
+	cleanup = func() {
+		_ = os.RemoveAll(tmp)
+	}
+
+	root := filepath.Join(tmp, "export")
+	if err := export(ctx, root); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	subdir = strings.Trim(path.Clean("/"+filepath.ToSlash(subdir)), "/")
+	dir := filepath.Join(root, filepath.FromSlash(subdir))
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		cleanup()
+		return nil, nil, NewLocalError("Unable to find the module directory", err, subdir)
+	}
+
+	hasLicense := false
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if p == dir {
+				return nil
+			}
+			switch d.Name() {
+			case ".bzr", ".git", ".hg", ".svn":
+				return filepath.SkipDir
+			}
+			if _, err := os.Lstat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || isVendoredPackage(name) {
+			return nil
+		}
+		// Added by hg archive. The go command drops it for every VCS.
+		if name == ".hg_archival.txt" {
+			return nil
+		}
+		if name == "LICENSE" {
+			hasLicense = true
+		}
+		files = append(files, moduleFile{name: name, path: p})
+		return nil
+	})
+	if err != nil {
+		cleanup()
+		return nil, nil, NewLocalError("Unable to read the exported source", err, "")
+	}
+
+	if !hasLicense && subdir != "" {
+		license := filepath.Join(root, "LICENSE")
+		if fi, err := os.Lstat(license); err == nil && fi.Mode().IsRegular() {
+			files = append(files, moduleFile{name: "LICENSE", path: license})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	if err := checkModuleFiles(files); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return files, cleanup, nil
+}
+
+// checkModuleFiles reports files that the go command would reject in a
+// module zip.
+func checkModuleFiles(files []moduleFile) error {
+	var total int64
+	seen := map[string]string{}
+	for _, f := range files {
+		fi, err := os.Stat(f.path)
+		if err != nil {
+			return NewLocalError("Unable to read the exported source", err, "")
+		}
+		size := fi.Size()
+		total += size
+
+		if other, ok := seen[strings.ToLower(f.name)]; ok {
+			return fmt.Errorf("module files %s and %s differ only in case", other, f.name)
+		}
+		seen[strings.ToLower(f.name)] = f.name
+
+		switch {
+		case f.name == "go.mod" && size > maxGoModSize:
+			return fmt.Errorf("go.mod file too large (%d bytes; limit is %d)", size, maxGoModSize)
+		case f.name == "LICENSE" && size > maxLicenseSize:
+			return fmt.Errorf("LICENSE file too large (%d bytes; limit is %d)", size, maxLicenseSize)
+		case total > maxModuleZipSize:
+			return fmt.Errorf("module source tree too large (limit is %d bytes)", maxModuleZipSize)
+		}
+	}
+	return nil
+}
+
+// isVendoredPackage reports whether the file name is in a package within a
+// vendor directory. Files directly in a vendor directory, such as
+// vendor/modules.txt, are not.
+//
+// It is a copy of the function in golang.org/x/mod/zip, including the wrong
+// offset for nested vendor directories that the go command keeps so that the
+// hashes of existing modules do not change (https://go.dev/issue/31562).
+func isVendoredPackage(name string) bool {
+	var i int
+	if strings.HasPrefix(name, "vendor/") {
+		i += len("vendor/")
+	} else if j := strings.Index(name, "/vendor/"); j >= 0 {
+		// This offset looks incorrect; this should probably be
+		//
+		// 	i = j + len("/vendor/")
+		//
+		// (See https://golang.org/issue/31562 and https://golang.org/issue/37397.)
+		// Unfortunately, we can't fix it without invalidating module checksums.
+		i += len("/vendor/")
+	} else {
+		return false
+	}
+	return strings.Contains(name[i:], "/")
+}
+
+// extractTar writes the directories and regular files of the tar archive r
+// below dir. Other entries, such as symbolic links, are skipped as they are
+// not part of a module.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if name == "" {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("archive entry %q is outside the export directory", hdr.Name)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(p, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeTarFile(tr, p, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeTarFile(r io.Reader, p string, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeModuleZip writes the module zip for modulePath at version, from the
+// source exported by export, to w. The files are placed below
+// modulePath@version/ as the go command expects.
+func writeModuleZip(ctx context.Context, export func(context.Context, string) error, w io.Writer, modulePath, version, subdir string) error {
+	files, cleanup, err := exportModule(ctx, export, subdir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	prefix := modulePath + "@" + version + "/"
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		if err := addZipFile(zw, prefix+f.name, f.path); err != nil {
+			return NewLocalError("Unable to write module zip", err, "")
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return NewLocalError("Unable to write module zip", err, "")
+	}
+	return nil
+}
+
+func addZipFile(zw *zip.Writer, name, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	zf, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zf, f)
+	return err
+}
+
+// moduleHash returns the h1: hash, as recorded in go.sum, of the module zip
+// for modulePath at version from the source exported by export. It is the
+// base64 encoded SHA-256 of a summary listing the SHA-256 and name of each
+// file, as computed by golang.org/x/mod/sumdb/dirhash.Hash1.
+func moduleHash(ctx context.Context, export func(context.Context, string) error, modulePath, version, subdir string) (string, error) {
+	files, cleanup, err := exportModule(ctx, export, subdir)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	prefix := modulePath + "@" + version + "/"
+	h := sha256.New()
+	for _, f := range files {
+		name := prefix + f.name
+		if strings.Contains(name, "\n") {
+			return "", fmt.Errorf("module file name %q contains a newline", name)
+		}
+		sum, err := fileSHA256(f.path)
+		if err != nil {
+			return "", NewLocalError("Unable to read the exported source", err, "")
+		}
+		fmt.Fprintf(h, "%x  %s\n", sum, name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileSHA256(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
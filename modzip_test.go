@@ -0,0 +1,173 @@
+package vcs_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Masterminds/vcs"
+	"github.com/Masterminds/vcs/vcstest"
+)
+
+var (
+	_ vcs.ModuleExporter = &vcs.BzrRepo{}
+	_ vcs.ModuleExporter = &vcs.GitRepo{}
+	_ vcs.ModuleExporter = &vcs.HgRepo{}
+	_ vcs.ModuleExporter = &vcs.SvnRepo{}
+)
+
+func TestExportModuleZip(t *testing.T) {
+	for _, f := range []vcstest.Factory{vcstest.GitFactory(), vcstest.HgFactory(), vcstest.SvnFactory()} {
+		t.Run(string(f.Type), func(t *testing.T) {
+			b := vcstest.Build(t, f.Type, vcstest.Spec{Commits: []vcstest.Commit{{
+				Files: map[string]string{
+					"LICENSE":                           "root license\n",
+					"go.mod":                            "module example.com/repo\n",
+					"lib/go.mod":                        "module example.com/repo/lib\n",
+					"lib/lib.go":                        "package lib\n",
+					"lib/vendor/modules.txt":            "# example.com/dep v1.0.0\n",
+					"lib/vendor/example.com/dep/dep.go": "package dep\n",
+					"lib/nested/go.mod":                 "module example.com/repo/lib/nested\n",
+					"lib/nested/nested.go":              "package nested\n",
+					"lib/internal/internal.go":          "package internal\n",
+				},
+			}}})
+			repo, err := vcs.NewRepo(b.Remote, filepath.Join(t.TempDir(), "checkout"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Get(); err != nil {
+				t.Fatal(err)
+			}
+			me := repo.(vcs.ModuleExporter)
+
+			var buf bytes.Buffer
+			if err := me.ExportModuleZipContext(context.Background(), &buf, "example.com/repo/lib", "v1.0.0", "lib"); err != nil {
+				t.Fatal(err)
+			}
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, zf := range zr.File {
+				names = append(names, zf.Name)
+			}
+			expected := []string{
+				"example.com/repo/lib@v1.0.0/LICENSE",
+				"example.com/repo/lib@v1.0.0/go.mod",
+				"example.com/repo/lib@v1.0.0/internal/internal.go",
+				"example.com/repo/lib@v1.0.0/lib.go",
+				"example.com/repo/lib@v1.0.0/vendor/modules.txt",
+			}
+			if !slices.Equal(names, expected) {
+				t.Errorf("unexpected files in module zip:\n%v", names)
+			}
+
+			h, err := me.DirHashContext(context.Background(), "example.com/repo/lib", "v1.0.0", "lib")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// As reported by go mod download for the same files.
+			if h != "h1:21KoMW+SoK5yDfU/+q9dVQidhNz0KfSjNi1Fy+MZCkI=" {
+				t.Errorf("unexpected hash %s", h)
+			}
+		})
+	}
+}
+
+// TestDirHashMatchesGo compares DirHash with the hash go mod download records
+// for the same Git commit.
+func TestDirHashMatchesGo(t *testing.T) {
+	b := vcstest.Build(t, vcs.Git, vcstest.Spec{Commits: []vcstest.Commit{{
+		Files: map[string]string{
+			"LICENSE":              "license\n",
+			"go.mod":               "module example.com/m.git\n\ngo 1.20\n",
+			"m.go":                 "package m\n",
+			"crlf.txt":             "line\r\nline\r\n",
+			".gitattributes":       "ignored.txt export-ignore\nsubst.txt export-subst\n",
+			"ignored.txt":          "ignored\n",
+			"subst.txt":            "$Format:%H$\n",
+			"vendor/modules.txt":   "# example.com/dep v1.0.0\n",
+			"vendor/dep/dep.go":    "package dep\n",
+			"a/vendor/modules.txt": "# example.com/dep v1.0.0\n",
+			"a/vendor/dep/dep.go":  "package dep\n",
+			"sub/go.mod":           "module example.com/m.git/sub\n",
+			"sub/sub.go":           "package sub\n",
+		},
+	}}})
+	info := goModDownload(t, b, b.Revisions[0])
+
+	repo, err := vcs.NewGitRepo(b.Remote, filepath.Join(t.TempDir(), "checkout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Get(); err != nil {
+		t.Fatal(err)
+	}
+	// As on Windows, where the checkout would otherwise change line endings.
+	if out, err := exec.Command("git", "-C", repo.LocalPath(), "config", "core.autocrlf", "true").CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	h, err := repo.DirHash(goModulePath, info.Version, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != info.Sum {
+		t.Errorf("expected the hash %s of go mod download, got %s", info.Sum, h)
+	}
+}
+
+// goModulePath is the module that goModDownload fetches. The go.mod of the
+// fixture declares it.
+const goModulePath = "example.com/m.git"
+
+// goDownload is the output of go mod download -json.
+type goDownload struct {
+	Version, Sum string
+}
+
+// goModDownload runs go mod download for goModulePath at rev, fetched from the
+// Git fixture b through a url.insteadOf rewrite so that no network is needed.
+// The test is skipped when go is not installed.
+func goModDownload(t *testing.T, b *vcstest.Built, rev string) goDownload {
+	t.Helper()
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	dir := t.TempDir()
+	gitConfig := filepath.Join(dir, "gitconfig")
+	config := "[url \"" + b.Remote + "\"]\n\tinsteadOf = https://example.com/m\n[protocol \"file\"]\n\tallow = always\n"
+	if err := os.WriteFile(gitConfig, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goCmd, "mod", "download", "-json", goModulePath+"@"+rev)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL="+gitConfig,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GOMODCACHE="+filepath.Join(dir, "modcache"),
+		"GOFLAGS=-modcacherw",
+		"GOPROXY=direct",
+		"GOSUMDB=off",
+		"GOVCS=*:all",
+		"GOTOOLCHAIN=local",
+		"GO111MODULE=on",
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go mod download: %s: %s", err, out)
+	}
+	var d goDownload
+	if err := json.Unmarshal(out, &d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,188 @@
+package vcs
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+)
+
+// Option configures a Repo created by NewRepo or one of the constructors for a
+// specific VCS, such as NewGitRepo.
+type Option func(*config)
+
+type config struct {
+	logger     *slog.Logger
+	runner     Runner
+	env        []string
+	binary     string
+	remoteName string
+	httpClient *http.Client
+	timeout    time.Duration
+	workers    int
+
+	countUntracked bool
+
+	probe        bool
+	probeTimeout time.Duration
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// WithLogger sets the structured logger that receives a record for every
+// command the repo runs. See the Slog field of the repos.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithRunner sets the Runner used to execute commands, including the probes
+// run by the constructors, in place of DefaultRunner.
+func WithRunner(r Runner) Option {
+	return func(c *config) {
+		c.runner = r
+	}
+}
+
+// WithEnv adds environment variables, in the form "key=value", to every
+// command the repo runs. They take precedence over the environment of the
+// process. This can be used to pass credentials, such as GIT_ASKPASS or
+// GIT_SSH_COMMAND, to the VCS.
+func WithEnv(env ...string) Option {
+	return func(c *config) {
+		c.env = append(c.env, env...)
+	}
+}
+
+// WithBinary sets the program run for the VCS, such as /usr/local/bin/git, in
+// place of looking up the name of the VCS on the PATH.
+func WithBinary(path string) Option {
+	return func(c *config) {
+		c.binary = path
+	}
+}
+
+// WithRemoteName sets the name of the remote a GitRepo works with. It defaults
+// to origin. The other VCS do not name their remotes and ignore it.
+func WithRemoteName(name string) Option {
+	return func(c *config) {
+		c.remoteName = name
+	}
+}
+
+// WithHTTPClient sets the client used for HTTP requests, such as the go-get
+// lookups NewRepo makes to detect the VCS of a remote and the Launchpad API
+// calls made by BzrRepo. It defaults to http.DefaultClient. Use a client with
+// a Timeout, proxy, root CAs, or a Transport that adds authentication headers
+// for private servers as needed.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// WithTimeout limits how long each command run by the repo can take. When it
+// is exceeded the command is killed and context.DeadlineExceeded is reported.
+// It applies in addition to any deadline on a context passed to the
+// Context methods.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithWorkers sets how many repos Discover creates concurrently. It defaults
+// to one and is ignored by the constructors.
+func WithWorkers(n int) Option {
+	return func(c *config) {
+		c.workers = n
+	}
+}
+
+// WithProbe makes NewRepo detect the VCS of a remote it cannot otherwise
+// detect, such as https://example.com/code/project, by running each VCS
+// against it. git ls-remote, hg identify, svn info, and bzr info are run in
+// parallel, without prompting for credentials, and the first VCS to succeed is
+// used. The probes are stopped after timeout, or 30 seconds when it is not
+// positive. A detected VCS is remembered for the remote so later calls do not
+// probe again.
+func WithProbe(timeout time.Duration) Option {
+	return func(c *config) {
+		c.probe = true
+		c.probeTimeout = timeout
+	}
+}
+
+// WithCountUntracked makes IsDirty report a working copy with untracked files,
+// which are not under version control and not ignored, as dirty. By default
+// only changes to tracked files count.
+func WithCountUntracked() Option {
+	return func(c *config) {
+		c.countUntracked = true
+	}
+}
+
+// installed reports whether the program for the VCS named name is available
+// to the configured Runner.
+func (c *config) installed(name string) bool {
+	r := c.runner
+	if r == nil {
+		r = DefaultRunner
+	}
+	if c.binary != "" {
+		name = c.binary
+	}
+	_, err := r.LookPath(name)
+	return err == nil
+}
+
+// configure applies the options to a repo.
+func (b *base) configure(c *config) {
+	b.Slog = c.logger
+	b.Runner = c.runner
+	if b.Runner == nil {
+		b.Runner = DefaultRunner
+	}
+	b.env = c.env
+	b.binary = c.binary
+	b.httpClient = c.httpClient
+	b.timeout = c.timeout
+	b.countUntracked = c.countUntracked
+}
+
+// prepare returns c with the configured binary and environment applied.
+func (b *base) prepare(c *Command) *Command {
+	if b.binary == "" && len(b.env) == 0 {
+		return c
+	}
+
+	p := *c
+	if b.binary != "" && p.Name == string(b.vcsType) {
+		p.Name = b.binary
+	}
+	if len(b.env) > 0 {
+		env := p.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		p.Env = mergeEnvLists(b.env, slices.Clone(env))
+	}
+	return &p
+}
+
+// withTimeout bounds ctx by the configured timeout.
+func (b *base) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, b.timeout)
+}
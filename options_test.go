@@ -0,0 +1,174 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptions(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"/opt/git/bin/git config --get remote.upstream.url": {out: "https://example.com/foo.git\n"},
+		"/opt/git/bin/git rev-parse HEAD":                   {out: "806b07b08faa21cfbdae93027904f80174679402\n"},
+	}}
+	// Options must be used in place of the defaults.
+	useRunner(t, &fakeRunner{})
+
+	local := t.TempDir()
+	if err := os.Mkdir(filepath.Join(local, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewGitRepo("", local,
+		WithRunner(f),
+		WithBinary("/opt/git/bin/git"),
+		WithRemoteName("upstream"),
+		WithEnv("GIT_TERMINAL_PROMPT=0", "VCS_TEST=1"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.RemoteLocation != "upstream" {
+		t.Errorf("remote name not set. Got %q", repo.RemoteLocation)
+	}
+	if repo.Remote() != "https://example.com/foo.git" {
+		t.Errorf("remote not read from the named remote. Got %q", repo.Remote())
+	}
+	if _, err := repo.Version(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.calls) != 2 {
+		t.Fatalf("expected 2 commands to run, got %d", len(f.calls))
+	}
+	for _, c := range f.calls {
+		if !slices.Contains(c.Env, "VCS_TEST=1") || !slices.Contains(c.Env, "GIT_TERMINAL_PROMPT=0") {
+			t.Errorf("environment not passed to %s", strings.Join(c.Args, " "))
+		}
+	}
+
+	cmd := repo.CmdFromDir("git", "status")
+	if cmd.Path != "/opt/git/bin/git" || !slices.Contains(cmd.Env, "VCS_TEST=1") {
+		t.Errorf("CmdFromDir does not use the options: %s %v", cmd.Path, cmd.Env)
+	}
+}
+
+func TestOptionsNotInstalled(t *testing.T) {
+	_, err := NewHgRepo("", t.TempDir(), WithRunner(&fakeRunner{}), WithBinary("/does/not/exist/hg"))
+	if err != nil {
+		t.Errorf("the runner was not used to find the binary: %s", err)
+	}
+	_, err = NewHgRepo("", t.TempDir(), WithBinary(filepath.Join(t.TempDir(), "hg")))
+	if err == nil {
+		t.Error("expected an error for a missing binary")
+	}
+}
+
+func TestOptionsCountUntracked(t *testing.T) {
+	// Without WithCountUntracked each VCS is asked to leave the untracked
+	// files out of the status.
+	tests := []struct {
+		name        string
+		new         func(remote, local string, opts ...Option) (Repo, error)
+		skip, count string
+	}{
+		{"git", func(r, l string, o ...Option) (Repo, error) { return NewGitRepo(r, l, o...) }, "--untracked-files=no", "--untracked-files=all"},
+		{"hg", func(r, l string, o ...Option) (Repo, error) { return NewHgRepo(r, l, o...) }, "-mard", ""},
+		{"svn", func(r, l string, o ...Option) (Repo, error) { return NewSvnRepo(r, l, o...) }, "--quiet", ""},
+		{"bzr", func(r, l string, o ...Option) (Repo, error) { return NewBzrRepo(r, l, o...) }, "--versioned", ""},
+	}
+	for _, tc := range tests {
+		for _, count := range []bool{false, true} {
+			f := &fakeRunner{}
+			opts := []Option{WithRunner(f)}
+			expected, unexpected := tc.skip, tc.count
+			if count {
+				opts = append(opts, WithCountUntracked())
+				expected, unexpected = tc.count, tc.skip
+			}
+			repo, err := tc.new("https://example.com/foo", t.TempDir(), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.calls = nil
+			repo.IsDirty()
+			if len(f.calls) != 1 || expected != "" && !slices.Contains(f.calls[0].Args, expected) ||
+				slices.Contains(f.calls[0].Args, unexpected) {
+				t.Errorf("%s: expected status with %q and without %q, got %v", tc.name, expected, unexpected, f.calls)
+			}
+		}
+	}
+}
+
+// blockingRunner runs every command until its context is done.
+type blockingRunner struct{ fakeRunner }
+
+func (*blockingRunner) Run(ctx context.Context, _ *Command) error {
+	<-ctx.Done()
+	return &ExitError{Code: -1}
+}
+
+func TestOptionsTimeout(t *testing.T) {
+	repo, err := NewGitRepo("https://example.com/foo.git", t.TempDir(), WithRunner(&blockingRunner{}), WithTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.Version()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the command to time out, got %v", err)
+	}
+
+	// Methods that tell a missing revision or file from other failures report
+	// the timeout rather than a missing revision or file.
+	if _, err := repo.Current(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Current: expected the command to time out, got %v", err)
+	}
+	if _, err := repo.CommitInfo("master"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CommitInfo: expected the command to time out, got %v", err)
+	}
+	if _, err := repo.ReadFile("master", "a.txt"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ReadFile: expected the command to time out, got %v", err)
+	}
+	hg, err := NewHgRepo("https://example.com/foo", t.TempDir(), WithRunner(&blockingRunner{}), WithTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hg.CommitInfo("default"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Hg CommitInfo: expected the command to time out, got %v", err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestOptionsHTTPClient(t *testing.T) {
+	var requested string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requested = r.URL.String()
+		body := `<html><head><meta name="go-import" content="example.org/foo git https://git.example.org/foo.git"></head></html>`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+	})}
+
+	repo, err := NewRepo("https://example.org/foo", t.TempDir(), WithHTTPClient(client), WithRunner(&fakeRunner{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requested != "https://example.org/foo?go-get=1" {
+		t.Errorf("the client was not used for detection. Requested %q", requested)
+	}
+	if repo.Vcs() != Git || repo.Remote() != "https://git.example.org/foo.git" {
+		t.Errorf("unexpected repo %s %s", repo.Vcs(), repo.Remote())
+	}
+	if repo.(*GitRepo).httpClient != client {
+		t.Error("the client was not passed on to the repo")
+	}
+}
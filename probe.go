@@ -0,0 +1,87 @@
+package vcs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultProbeTimeout bounds probing when WithProbe is not given a timeout.
+const defaultProbeTimeout = 30 * time.Second
+
+// probeCache holds the VCS detected by probing for each remote.
+var probeCache sync.Map
+
+// probeRemote detects the VCS of remote by running the probe of every
+// registered backend whose program is installed. The first VCS whose probe
+// succeeds is returned and the others are stopped.
+func probeRemote(ctx context.Context, c *config, remote string) (Type, error) {
+	if t, ok := probeCache.Load(remote); ok {
+		return t.(Type), nil
+	}
+
+	timeout := c.probeTimeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	pctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	runner := c.runner
+	if runner == nil {
+		runner = DefaultRunner
+	}
+
+	found := make(chan Type)
+	var wg sync.WaitGroup
+	for _, b := range registeredBackends() {
+		if b.Probe == nil {
+			continue
+		}
+		cmd := b.Probe(remote)
+		if cmd == nil {
+			continue
+		}
+		if _, err := runner.LookPath(cmd.Name); err != nil {
+			continue
+		}
+
+		// The probe is run as a repo of the VCS would run it, except that a
+		// binary set with WithBinary is meant for a single VCS and is not
+		// used.
+		p := &base{vcsType: b.typ}
+		p.configure(c)
+		p.binary = ""
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.exec(pctx, cmd); err != nil {
+				return
+			}
+			select {
+			case found <- b.typ:
+			case <-pctx.Done():
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	t, ok := <-found
+	// Stop the other probes and wait for them so no process outlives the
+	// call.
+	cancel()
+	for range found {
+	}
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return NoVCS, err
+		}
+		return NoVCS, ErrCannotDetectVCS
+	}
+	probeCache.Store(remote, t)
+	return t, nil
+}
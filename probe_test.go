@@ -0,0 +1,98 @@
+package vcs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// notFoundClient answers every request with a 404 so detection falls through
+// to probing without making requests.
+var notFoundClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    r,
+	}, nil
+})}
+
+// probeRunner blocks the probes of every VCS but one until their context is
+// done.
+type probeRunner struct {
+	fakeRunner
+	vcs string
+}
+
+func (p *probeRunner) Run(ctx context.Context, cmd *Command) error {
+	if len(cmd.Args) > 0 && cmd.Args[len(cmd.Args)-1] == "https://example.com/code/project" {
+		p.mu.Lock()
+		p.calls = append(p.calls, cmd)
+		p.mu.Unlock()
+		if cmd.Name == p.vcs {
+			return nil
+		}
+		<-ctx.Done()
+		return &ExitError{Code: -1}
+	}
+	return p.fakeRunner.Run(ctx, cmd)
+}
+
+func TestProbe(t *testing.T) {
+	probeCache.Delete("https://example.com/code/project")
+	t.Cleanup(func() { probeCache.Delete("https://example.com/code/project") })
+
+	r := &probeRunner{vcs: "hg"}
+	opts := []Option{WithRunner(r), WithHTTPClient(notFoundClient), WithProbe(time.Minute)}
+	repo, err := NewRepo("https://example.com/code/project", t.TempDir(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Vcs() != Hg || repo.Remote() != "https://example.com/code/project" {
+		t.Errorf("expected an Hg repo for the remote, got %s %s", repo.Vcs(), repo.Remote())
+	}
+
+	probes := len(r.calls)
+	if probes != 4 {
+		t.Errorf("expected a probe for each VCS, got %d", probes)
+	}
+	for _, c := range r.calls {
+		if c.Name == "git" && !strings.Contains(strings.Join(c.Env, "\n"), "GIT_TERMINAL_PROMPT=0") {
+			t.Error("git probe can prompt for credentials")
+		}
+	}
+
+	if _, err := NewRepo("https://example.com/code/project", t.TempDir(), opts...); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.calls) != probes {
+		t.Error("detected VCS was not cached for the remote")
+	}
+}
+
+func TestProbeFailure(t *testing.T) {
+	// Without WithProbe nothing is run.
+	r := &probeRunner{vcs: "none"}
+	_, err := NewRepo("https://example.com/code/project", t.TempDir(), WithRunner(r), WithHTTPClient(notFoundClient))
+	if err != ErrCannotDetectVCS || len(r.calls) != 0 {
+		t.Errorf("expected ErrCannotDetectVCS without probing, got %v after %d probes", err, len(r.calls))
+	}
+
+	_, err = NewRepo("https://example.com/code/project", t.TempDir(), WithRunner(r), WithHTTPClient(notFoundClient), WithProbe(10*time.Millisecond))
+	if err != ErrCannotDetectVCS {
+		t.Errorf("expected ErrCannotDetectVCS when every probe fails, got %v", err)
+	}
+	if _, ok := probeCache.Load("https://example.com/code/project"); ok {
+		t.Error("failed probe was cached")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = NewRepoContext(ctx, "https://example.com/code/project", t.TempDir(), WithRunner(r), WithHTTPClient(notFoundClient), WithProbe(time.Minute))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
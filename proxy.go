@@ -0,0 +1,485 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Proxy is an http.Handler that serves Go modules from their repositories
+// using the GOPROXY protocol. It can be used as the GOPROXY for the go
+// command, for example to serve modules from internal servers to builds
+// without internet access. The repository of a module is found with
+// ResolveModule, once per module, and is checked out to a directory of the
+// Proxy the first time it is requested. Git and Hg repos can be served, as
+// can those of other backends that implement RepoContext and AncestorTagger.
+// Svn repos, whose tags are not listed, and Bzr repos, which do not support
+// pseudo-versions, cannot be served and requests for their modules get a 404.
+//
+// Versions are the semantic version tags of a module, see ModuleVersion, and
+// pseudo-versions for other revisions. A branch, tag, or commit id can be
+// requested in place of a version in .info requests and the canonical version
+// for it is returned.
+type Proxy struct {
+	dir  string
+	opts []Option
+
+	mu      sync.Mutex
+	locks   map[string]*sync.Mutex
+	modules map[string]*Module
+}
+
+// NewProxy returns a Proxy that keeps its checkouts in dir. The opts are
+// used to resolve modules and are passed to the constructor of each repo.
+func NewProxy(dir string, opts ...Option) *Proxy {
+	return &Proxy{
+		dir:     dir,
+		opts:    opts,
+		locks:   map[string]*sync.Mutex{},
+		modules: map[string]*Module{},
+	}
+}
+
+// proxyInfo is the JSON served for .info and @latest requests.
+type proxyInfo struct {
+	Version string
+	Time    time.Time
+}
+
+// errNotFound is reported to the go command with a 404 so it tries the next
+// proxy, if any.
+var errNotFound = errors.New("not found")
+
+// ServeHTTP implements the GOPROXY protocol:
+//
+//	GET $GOPROXY/<module>/@v/list
+//	GET $GOPROXY/<module>/@v/<version>.info
+//	GET $GOPROXY/<module>/@v/<version>.mod
+//	GET $GOPROXY/<module>/@v/<version>.zip
+//	GET $GOPROXY/<module>/@latest
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, contentType, err := p.serve(r.Context(), strings.TrimPrefix(r.URL.Path, "/"))
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, errNotFound) || errors.Is(err, ErrInvalidModulePath) ||
+			errors.Is(err, ErrCannotDetectVCS) || errors.Is(err, ErrRevisionUnavailable) ||
+			errors.Is(err, ErrUnsupported) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", contentType)
+	_, _ = io.Copy(w, body)
+}
+
+// serve returns the response for the request for urlPath. The caller closes
+// the body.
+func (p *Proxy) serve(ctx context.Context, urlPath string) (io.ReadCloser, string, error) {
+	var escMod, file string
+	if i := strings.Index(urlPath, "/@v/"); i >= 0 {
+		escMod, file = urlPath[:i], urlPath[i+len("/@v/"):]
+	} else if mod, ok := strings.CutSuffix(urlPath, "/@latest"); ok {
+		escMod, file = mod, "@latest"
+	} else {
+		return nil, "", fmt.Errorf("%w: %s", errNotFound, urlPath)
+	}
+	modPath, ok := unescapeModulePath(escMod)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", ErrInvalidModulePath, escMod)
+	}
+
+	m, err := p.module(ctx, modPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if m.Vcs == ModProxy {
+		return nil, "", fmt.Errorf("%w: %s is served by the module proxy %s", errNotFound, modPath, m.RepoURL)
+	}
+	if m.Vcs == Svn || m.Vcs == Bzr {
+		return nil, "", fmt.Errorf("%w: %s is in a %s repo, which cannot be served", errNotFound, modPath, m.Vcs)
+	}
+
+	unlock := p.lock(m.Root)
+	defer unlock()
+
+	switch file {
+	case "list":
+		repo, err := p.repo(ctx, m, true)
+		if err != nil {
+			return nil, "", err
+		}
+		versions, err := p.versions(ctx, repo, m)
+		if err != nil {
+			return nil, "", err
+		}
+		var b strings.Builder
+		for _, v := range versions {
+			b.WriteString(v + "\n")
+		}
+		return textBody(b.String()), "text/plain; charset=utf-8", nil
+
+	case "@latest":
+		repo, err := p.repo(ctx, m, true)
+		if err != nil {
+			return nil, "", err
+		}
+		return p.latest(ctx, repo, m)
+	}
+
+	var ext string
+	for _, e := range []string{".info", ".mod", ".zip"} {
+		if strings.HasSuffix(file, e) {
+			ext = e
+		}
+	}
+	version, ok := unescapeModulePath(strings.TrimSuffix(file, ext))
+	if ext == "" || !ok {
+		return nil, "", fmt.Errorf("%w: %s", errNotFound, urlPath)
+	}
+
+	repo, err := p.repo(ctx, m, false)
+	if err != nil {
+		return nil, "", err
+	}
+	rev, canonical, ci, err := p.resolveVersion(ctx, repo, m, version)
+	if err != nil {
+		return nil, "", err
+	}
+	if ext == ".info" {
+		return infoJSON(canonical, ci)
+	}
+	if canonical != version {
+		return nil, "", fmt.Errorf("%w: %s is not a canonical version, use %s", errNotFound, version, canonical)
+	}
+
+	if err := repo.(RepoContext).UpdateVersionContext(ctx, rev); err != nil {
+		return nil, "", err
+	}
+	subdir, goMod, err := moduleDir(repo, m)
+	if err != nil {
+		return nil, "", err
+	}
+	if ext == ".mod" {
+		if goMod == nil {
+			goMod = []byte("module " + m.Path + "\n")
+		}
+		return textBody(string(goMod)), "text/plain; charset=utf-8", nil
+	}
+
+	me, ok := repo.(ModuleExporter)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s repos cannot be exported as modules", errNotFound, repo.Vcs())
+	}
+
+	// The zip is written to a temporary file rather than memory as modules
+	// can be large, and the response is only started once it is complete.
+	f, err := os.CreateTemp("", "vcs-proxy-*.zip")
+	if err != nil {
+		return nil, "", NewLocalError("Unable to create temporary file", err, "")
+	}
+	tf := &tempFile{f}
+	if err := me.ExportModuleZipContext(ctx, tf, m.Path, canonical, subdir); err != nil {
+		_ = tf.Close()
+		return nil, "", err
+	}
+	if _, err := tf.Seek(0, io.SeekStart); err != nil {
+		_ = tf.Close()
+		return nil, "", NewLocalError("Unable to read temporary file", err, "")
+	}
+	return tf, "application/zip", nil
+}
+
+// module returns the Module for modPath, resolving it the first time it is
+// requested.
+func (p *Proxy) module(ctx context.Context, modPath string) (*Module, error) {
+	p.mu.Lock()
+	m, ok := p.modules[modPath]
+	p.mu.Unlock()
+	if ok {
+		return m, nil
+	}
+
+	m, err := ResolveModuleContext(ctx, modPath, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.modules[modPath] = m
+	p.mu.Unlock()
+	return m, nil
+}
+
+// tempFile is a temporary file that is removed when it is closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	_ = os.Remove(f.Name())
+	return err
+}
+
+// textBody returns s as the body of a response.
+func textBody(s string) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(s))
+}
+
+// lock serializes the use of the checkout for the repo with the root.
+func (p *Proxy) lock(root string) func() {
+	p.mu.Lock()
+	l, ok := p.locks[root]
+	if !ok {
+		l = &sync.Mutex{}
+		p.locks[root] = l
+	}
+	p.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// repo returns the checkout of the repo for m, creating it when needed. An
+// existing checkout is updated when update is true.
+func (p *Proxy) repo(ctx context.Context, m *Module, update bool) (Repo, error) {
+	escRoot, _ := escapeModulePath(m.Root)
+	local := filepath.Join(p.dir, filepath.FromSlash(escRoot))
+	repo, err := newRepoOfType(m.Vcs, m.RepoURL, local, p.opts)
+	if err != nil {
+		return nil, err
+	}
+	rc, ok := repo.(RepoContext)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s repos do not implement RepoContext", errNotFound, m.Vcs)
+	}
+	if _, ok := repo.(AncestorTagger); !ok {
+		return nil, fmt.Errorf("%w: %s repos do not implement AncestorTagger", errNotFound, m.Vcs)
+	}
+
+	if _, err := os.Stat(local); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			return nil, NewLocalError("Unable to create directory", err, "")
+		}
+		return repo, rc.GetContext(ctx)
+	}
+	if update {
+		return repo, rc.UpdateContext(ctx)
+	}
+	return repo, nil
+}
+
+// versions returns the tagged versions of m in ascending order.
+func (p *Proxy) versions(ctx context.Context, repo Repo, m *Module) ([]string, error) {
+	tags, err := repo.(RepoContext).TagsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	major := moduleMajor(m)
+	var versions []string
+	var parsed []semver
+	for _, t := range tags {
+		if v, ok := highestVersion([]string{t}, m.TagPrefix, major); ok {
+			sv, _ := parseSemver(v)
+			versions = append(versions, v)
+			parsed = append(parsed, sv)
+		}
+	}
+	sort.Sort(byVersion{versions, parsed})
+	return versions, nil
+}
+
+// latest returns the info for the highest release of m, or the highest
+// prerelease when there are no releases. When m has no versions the
+// pseudo-version of the head of the default branch is used.
+func (p *Proxy) latest(ctx context.Context, repo Repo, m *Module) (io.ReadCloser, string, error) {
+	versions, err := p.versions(ctx, repo, m)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := defaultRevision(repo)
+	if len(versions) > 0 {
+		v := versions[len(versions)-1]
+		for i := len(versions) - 1; i >= 0; i-- {
+			if sv, _ := parseSemver(versions[i]); sv.prerelease == "" {
+				v = versions[i]
+				break
+			}
+		}
+		query = v
+	}
+
+	_, canonical, ci, err := p.resolveVersion(ctx, repo, m, query)
+	if err != nil {
+		return nil, "", err
+	}
+	return infoJSON(canonical, ci)
+}
+
+// resolveVersion finds the revision for version, which is a version of m or a
+// revision in the repo, and returns it along with its canonical version and
+// commit.
+func (p *Proxy) resolveVersion(ctx context.Context, repo Repo, m *Module, version string) (rev, canonical string, ci *CommitInfo, err error) {
+	rc := repo.(RepoContext)
+	rev = version
+	sv, isSemver := parseSemver(version)
+	if isSemver && !hasMajor(sv, moduleMajor(m)) {
+		return "", "", nil, fmt.Errorf("%w: %s is not a version of %s", errNotFound, version, m.Path)
+	}
+	switch {
+	case isSemver && isPseudoVersion(sv):
+		rev = version[strings.LastIndex(version, "-")+1:]
+	case isSemver:
+		rev = m.TagPrefix + version
+	}
+
+	ci, err = rc.CommitInfoContext(ctx, rev)
+	if err == ErrRevisionUnavailable {
+		// The revision may be newer than the checkout.
+		if err = rc.UpdateContext(ctx); err == nil {
+			ci, err = rc.CommitInfoContext(ctx, rev)
+		}
+	}
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%w: unknown revision %s: %v", errNotFound, version, err)
+	}
+
+	// A tag is its own version even when the commit has other tags.
+	if isSemver && !isPseudoVersion(sv) {
+		return rev, version, ci, nil
+	}
+	canonical, err = ModuleVersionContext(ctx, repo, rev, m)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return rev, canonical, ci, nil
+}
+
+// moduleDir returns the directory of m in the checked out revision of repo and
+// the content of its go.mod file, which is nil when there is none. For a
+// major version the major subdirectory is used when it holds the go.mod of
+// the module.
+func moduleDir(repo Repo, m *Module) (string, []byte, error) {
+	if m.MajorSubdir != "" {
+		b, err := os.ReadFile(filepath.Join(repo.LocalPath(), filepath.FromSlash(m.MajorSubdir), "go.mod"))
+		if err == nil && goModPath(b) == m.Path {
+			return m.MajorSubdir, b, nil
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(repo.LocalPath(), filepath.FromSlash(m.Subdir), "go.mod"))
+	if os.IsNotExist(err) {
+		return m.Subdir, nil, nil
+	} else if err != nil {
+		return "", nil, NewLocalError("Unable to read go.mod", err, "")
+	}
+	return m.Subdir, b, nil
+}
+
+// goModPath returns the module path declared by the go.mod file content b.
+func goModPath(b []byte) string {
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) >= 2 && f[0] == "module" {
+			return strings.Trim(f[1], `"`+"`")
+		}
+	}
+	return ""
+}
+
+// defaultRevision returns the revision of the head of the default branch of
+// repo as the VCS names it.
+func defaultRevision(repo Repo) string {
+	switch r := repo.(type) {
+	case *GitRepo:
+		return r.RemoteLocation + "/HEAD"
+	case *HgRepo:
+		return "default"
+	}
+	return "HEAD"
+}
+
+func infoJSON(version string, ci *CommitInfo) (io.ReadCloser, string, error) {
+	b, err := json.Marshal(proxyInfo{Version: version, Time: commitTime(ci).UTC()})
+	if err != nil {
+		return nil, "", err
+	}
+	return textBody(string(b)), "application/json", nil
+}
+
+// byVersion sorts versions by their semantic version.
+type byVersion struct {
+	versions []string
+	parsed   []semver
+}
+
+func (b byVersion) Len() int { return len(b.versions) }
+
+func (b byVersion) Less(i, j int) bool { return compareSemver(b.parsed[i], b.parsed[j]) < 0 }
+
+func (b byVersion) Swap(i, j int) {
+	b.versions[i], b.versions[j] = b.versions[j], b.versions[i]
+	b.parsed[i], b.parsed[j] = b.parsed[j], b.parsed[i]
+}
+
+// escapeModulePath escapes a module path or version for use in a URL or file
+// path on a case-insensitive file system. Upper case letters are replaced by
+// an exclamation mark followed by the lower case letter.
+func escapeModulePath(s string) (string, bool) {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '!' || r >= 0x80:
+			return "", false
+		case 'A' <= r && r <= 'Z':
+			b.WriteByte('!')
+			b.WriteRune(r + 'a' - 'A')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), true
+}
+
+// unescapeModulePath reverses escapeModulePath.
+func unescapeModulePath(s string) (string, bool) {
+	var b strings.Builder
+	bang := false
+	for _, r := range s {
+		switch {
+		case r >= 0x80 || 'A' <= r && r <= 'Z':
+			return "", false
+		case bang:
+			if r < 'a' || r > 'z' {
+				return "", false
+			}
+			b.WriteRune(r + 'A' - 'a')
+			bang = false
+		case r == '!':
+			bang = true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if bang || path.Clean("/"+b.String()) != "/"+b.String() {
+		return "", false
+	}
+	return b.String(), true
+}
@@ -0,0 +1,128 @@
+package vcs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxy(t *testing.T) {
+	repo, ids := newGitTestRepo(t,
+		gitTestCommit{Files: map[string]string{"go.mod": "module MOD\n", "a.go": "package a\n"}, Tags: []string{"v1.0.0"},
+			CommitDate: gitTestDate.Add(30 * time.Minute)},
+		gitTestCommit{Files: map[string]string{"a.go": "package a // v1.1.0\n"}, Tags: []string{"v1.1.0", "v1.2.0-rc.1"}},
+		gitTestCommit{Files: map[string]string{"a.go": "package a // next\n"}, CommitDate: gitTestDate.Add(3 * time.Hour)},
+	)
+
+	// The module path is served by a vanity server pointing at the repo. The
+	// Svn module cannot be served.
+	var lookups atomic.Int32
+	meta := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/Svn") {
+			fmt.Fprintf(w, `<meta name="go-import" content="%s/Svn svn https://%[1]s/svn">`, r.Host)
+			return
+		}
+		lookups.Add(1)
+		fmt.Fprintf(w, `<meta name="go-import" content="%s/Mod git %s">`, r.Host, repo.Remote())
+	}))
+	defer meta.Close()
+	modPath := meta.Listener.Addr().String() + "/Mod"
+	escPath := strings.Replace(modPath, "/Mod", "/!mod", 1)
+
+	proxy := httptest.NewServer(NewProxy(t.TempDir(), WithHTTPClient(meta.Client())))
+	defer proxy.Close()
+	get := func(p string) (int, []byte) {
+		t.Helper()
+		resp, err := http.Get(proxy.URL + "/" + escPath + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, body
+	}
+	info := func(p string) (string, string) {
+		t.Helper()
+		code, body := get(p)
+		if code != http.StatusOK {
+			t.Fatalf("%s: %d %s", p, code, body)
+		}
+		var i struct{ Version, Time string }
+		if err := json.Unmarshal(body, &i); err != nil {
+			t.Fatal(err)
+		}
+		return i.Version, i.Time
+	}
+
+	if code, body := get("/@v/list"); code != http.StatusOK || string(body) != "v1.0.0\nv1.1.0\nv1.2.0-rc.1\n" {
+		t.Errorf("unexpected list: %d %q", code, body)
+	}
+	if v, tm := info("/@v/v1.0.0.info"); v != "v1.0.0" || tm != "2015-07-29T14:16:39Z" {
+		t.Errorf("unexpected info for v1.0.0: %s %s", v, tm)
+	}
+	if v, _ := info("/@latest"); v != "v1.1.0" {
+		t.Errorf("unexpected latest version %s", v)
+	}
+	// Versions and times are those of the commits rather than the authors.
+	pseudo := "v1.2.0-rc.1.0.20150729164639-" + ids[2][:12]
+	if v, _ := info("/@v/master.info"); v != pseudo {
+		t.Errorf("expected %s for master, got %s", pseudo, v)
+	}
+	if v, tm := info("/@v/" + pseudo + ".info"); v != pseudo || tm != "2015-07-29T16:46:39Z" {
+		t.Errorf("unexpected info for %s: %s %s", pseudo, v, tm)
+	}
+
+	if code, body := get("/@v/v1.0.0.mod"); code != http.StatusOK || string(body) != "module MOD\n" {
+		t.Errorf("unexpected go.mod: %d %q", code, body)
+	}
+	code, body := get("/@v/v1.1.0.zip")
+	if code != http.StatusOK {
+		t.Fatalf("zip: %d %s", code, body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, " ") != modPath+"@v1.1.0/a.go "+modPath+"@v1.1.0/go.mod" {
+		t.Errorf("unexpected zip contents %v", names)
+	}
+
+	for _, p := range []string{"/@v/v1.3.0.info", "/@v/v2.0.0.info", "/@v/master.zip", "/@v/list/extra"} {
+		if code, _ := get(p); code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", p, code)
+		}
+	}
+
+	// The module is resolved once for all of the requests.
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("expected the module to be resolved once, got %d lookups", n)
+	}
+
+	escSvn := strings.Replace(escPath, "/!mod", "/!svn", 1)
+	for _, p := range []string{"/@v/list", "/@latest", "/@v/v1.0.0.info"} {
+		resp, err := http.Get(proxy.URL + "/" + escSvn + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Svn %s: expected 404, got %d %s", p, resp.StatusCode, b)
+		}
+	}
+}
@@ -23,12 +23,31 @@
 // example, each VCS has its own version formats that need to be respected and
 // checkout out branches, if a branch is being worked with, is different in
 // each VCS.
+//
+// Features that other implementations of Repo need not provide are described
+// by optional interfaces rather than being part of Repo: HistoryRepo,
+// DiffRepo, StatusRepo, and FileRepo. BzrRepo, GitRepo, HgRepo, and SvnRepo
+// implement them. Use a type assertion to check for one. For example,
+//
+//	if hr, ok := repo.(vcs.HistoryRepo); ok {
+//		for ci, err := range hr.Log(vcs.LogOptions{Max: 10}) {
+//			...
+//		}
+//	}
+//
+// Support for other VCS can be added by registering a Backend with
+// RegisterBackend.
 package vcs
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
 	"regexp"
@@ -40,6 +59,9 @@ import (
 // where verbose output from each VCS will be written. The default logger does
 // not log data. To log data supply your own logger or change the output location
 // of the provided logger.
+//
+// Structured records for each command run by a repo are written to its Slog
+// field, or slog.Default(), at the debug level.
 var Logger *log.Logger
 
 func init() {
@@ -131,20 +153,91 @@ type Repo interface {
 	RunFromDir(cmd string, args ...string) ([]byte, error)
 
 	// CmdFromDir creates a new command that will be executed from repo's
-	// directory.
+	// directory. Unlike RunFromDir the command does not go through the repo's
+	// Runner.
 	CmdFromDir(cmd string, args ...string) *exec.Cmd
 
 	// ExportDir exports the current revision to the passed in directory.
 	ExportDir(string) error
 }
 
+// RepoContext is implemented by repositories whose operations can be bound to
+// a context.Context. When the context is canceled, or its deadline passes, the
+// running VCS command and any processes it started are killed. The error
+// returned in that case wraps the context error so it can be detected with
+// errors.Is(err, context.DeadlineExceeded) or errors.Is(err, context.Canceled).
+//
+// BzrRepo, GitRepo, HgRepo, and SvnRepo all implement this interface.
+type RepoContext interface {
+	Repo
+
+	// GetContext is the context-aware version of Get.
+	GetContext(context.Context) error
+
+	// InitContext is the context-aware version of Init.
+	InitContext(context.Context) error
+
+	// UpdateContext is the context-aware version of Update.
+	UpdateContext(context.Context) error
+
+	// UpdateVersionContext is the context-aware version of UpdateVersion.
+	UpdateVersionContext(context.Context, string) error
+
+	// VersionContext is the context-aware version of Version.
+	VersionContext(context.Context) (string, error)
+
+	// CurrentContext is the context-aware version of Current.
+	CurrentContext(context.Context) (string, error)
+
+	// DateContext is the context-aware version of Date.
+	DateContext(context.Context) (time.Time, error)
+
+	// BranchesContext is the context-aware version of Branches.
+	BranchesContext(context.Context) ([]string, error)
+
+	// TagsContext is the context-aware version of Tags.
+	TagsContext(context.Context) ([]string, error)
+
+	// IsReferenceContext is the context-aware version of IsReference.
+	IsReferenceContext(context.Context, string) bool
+
+	// IsDirtyContext is the context-aware version of IsDirty.
+	IsDirtyContext(context.Context) bool
+
+	// CommitInfoContext is the context-aware version of CommitInfo.
+	CommitInfoContext(context.Context, string) (*CommitInfo, error)
+
+	// TagsFromCommitContext is the context-aware version of TagsFromCommit.
+	TagsFromCommitContext(context.Context, string) ([]string, error)
+
+	// PingContext is the context-aware version of Ping.
+	PingContext(context.Context) bool
+
+	// RunFromDirContext is the context-aware version of RunFromDir.
+	RunFromDirContext(ctx context.Context, cmd string, args ...string) ([]byte, error)
+
+	// CmdFromDirContext is the context-aware version of CmdFromDir.
+	CmdFromDirContext(ctx context.Context, cmd string, args ...string) *exec.Cmd
+
+	// ExportDirContext is the context-aware version of ExportDir.
+	ExportDirContext(context.Context, string) error
+}
+
 // NewRepo returns a Repo based on trying to detect the source control from the
 // remote and local locations. The appropriate implementation will be returned
 // or an ErrCannotDetectVCS if the VCS type cannot be detected.
 // Note, this function may make calls to the Internet to determind help determine
 // the VCS.
-func NewRepo(remote, local string) (Repo, error) {
-	vtype, remote, err := detectVcsFromRemote(remote)
+// The opts are used for detection, such as WithHTTPClient and WithProbe, and
+// are passed on to the constructor for the VCS.
+func NewRepo(remote, local string, opts ...Option) (Repo, error) {
+	return NewRepoContext(context.Background(), remote, local, opts...)
+}
+
+// NewRepoContext is the context-aware version of NewRepo. The context bounds
+// the requests made to detect the VCS of the remote.
+func NewRepoContext(ctx context.Context, remote, local string, opts ...Option) (Repo, error) {
+	vtype, remote, err := detectRemote(ctx, newConfig(opts), remote)
 
 	// From the remote URL the VCS could not be detected. See if the local
 	// repo contains enough information to figure out the VCS. The reason the
@@ -158,19 +251,45 @@ func NewRepo(remote, local string) (Repo, error) {
 		return nil, err
 	}
 
-	switch vtype {
-	case Git:
-		return NewGitRepo(remote, local)
-	case Svn:
-		return NewSvnRepo(remote, local)
-	case Hg:
-		return NewHgRepo(remote, local)
-	case Bzr:
-		return NewBzrRepo(remote, local)
+	return newRepoOfType(vtype, remote, local, opts)
+}
+
+// detectRemote detects the VCS of remote and the location to use for it.
+func detectRemote(ctx context.Context, c *config, remote string) (Type, string, error) {
+	vtype, detected, err := detectVcsFromRemote(ctx, c.httpClient, remote)
+
+	// Running the VCS against the remote is slow so it is only done when
+	// asked for with WithProbe.
+	if err == ErrCannotDetectVCS && c.probe && remote != "" {
+		if vtype, err = probeRemote(ctx, c, remote); err == nil {
+			detected = remote
+		}
+	}
+	return vtype, detected, err
+}
+
+// Open returns a Repo for the working copy containing path, which can be the
+// root of the working copy or any directory within it. The VCS is detected
+// from the metadata directory, such as .git, found by walking up from path and
+// the remote is read from the configuration of the working copy. The Repo
+// uses the root of the working copy as its LocalPath. ErrCannotDetectVCS is
+// returned when path is not within a working copy.
+func Open(path string, opts ...Option) (Repo, error) {
+	root, vtype, err := findRoot(path)
+	if err != nil {
+		return nil, err
 	}
 
-	// Should never fall through to here but just in case.
-	return nil, ErrCannotDetectVCS
+	return newRepoOfType(vtype, "", root, opts)
+}
+
+// newRepoOfType calls the constructor of the backend for the VCS type.
+func newRepoOfType(vtype Type, remote, local string, opts []Option) (Repo, error) {
+	b, ok := lookupBackend(vtype)
+	if !ok || b.New == nil {
+		return nil, ErrCannotDetectVCS
+	}
+	return b.New(remote, local, opts...)
 }
 
 // CommitInfo contains metadata about a commit.
@@ -178,19 +297,56 @@ type CommitInfo struct {
 	// The commit id
 	Commit string
 
-	// Who authored the commit
+	// Who authored the commit, in the form "Name <email>". Svn records only
+	// a user name.
 	Author string
 
-	// Date of the commit
+	// When the commit was authored, in the time zone it was made in. Svn
+	// records times in UTC.
 	Date time.Time
 
-	// Commit message
+	// The full commit message
 	Message string
+
+	// The name and email of the author
+	AuthorName, AuthorEmail string
+
+	// Who made the commit and when. Git and Bzr record a committer separately
+	// from the author. For Hg and Svn they are the author and Date.
+	CommitterName, CommitterEmail string
+	CommitDate                    time.Time
+
+	// The ids of the parent commits, in order. A merge has more than one and
+	// the first commit has none. Bzr parents are in the form revid:ID, which
+	// bzr accepts as a revision.
+	Parents []string
+
+	// The named branch of the commit. Only Hg records it.
+	Branch string
+
+	// The trailers, such as Signed-off-by, at the end of Message
+	Trailers []Trailer
 }
 
 type base struct {
 	remote, local string
+	vcsType       Type
 	Logger        *log.Logger
+
+	// Slog receives a structured debug record for every command the repo
+	// runs. When nil slog.Default() is used.
+	Slog *slog.Logger
+
+	// Set using the Option values passed to the constructor.
+	env            []string
+	binary         string
+	httpClient     *http.Client
+	timeout        time.Duration
+	countUntracked bool
+
+	// Runner executes the VCS commands for the repo. When nil DefaultRunner
+	// is used.
+	Runner Runner
 }
 
 func (b *base) log(v interface{}) {
@@ -216,25 +372,101 @@ func (b *base) setLocalPath(local string) {
 }
 
 func (b base) run(cmd string, args ...string) ([]byte, error) {
-	out, err := exec.Command(cmd, args...).CombinedOutput()
+	return b.runContext(context.Background(), cmd, args...)
+}
+
+func (b base) runContext(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	out, err := b.combinedOutput(ctx, "", nil, cmd, args...)
 	b.log(out)
 	if err != nil {
-		err = fmt.Errorf("%s: %s", out, err)
+		err = fmt.Errorf("%s: %w", out, err)
 	}
 	return out, err
 }
 
 func (b *base) CmdFromDir(cmd string, args ...string) *exec.Cmd {
-	c := exec.Command(cmd, args...)
+	return b.CmdFromDirContext(context.Background(), cmd, args...)
+}
+
+func (b *base) CmdFromDirContext(ctx context.Context, cmd string, args ...string) *exec.Cmd {
+	p := b.prepare(&Command{Name: cmd, Args: args, Env: envForDir(b.local)})
+	c := commandContext(ctx, p.Name, p.Args...)
 	c.Dir = b.local
-	c.Env = envForDir(c.Dir)
+	c.Env = p.Env
 	return c
 }
 
 func (b *base) RunFromDir(cmd string, args ...string) ([]byte, error) {
-	c := b.CmdFromDir(cmd, args...)
-	out, err := c.CombinedOutput()
-	return out, err
+	return b.RunFromDirContext(context.Background(), cmd, args...)
+}
+
+func (b *base) RunFromDirContext(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	return b.combinedOutput(ctx, b.local, envForDir(b.local), cmd, args...)
+}
+
+func (b *base) runner() Runner {
+	if b.Runner != nil {
+		return b.Runner
+	}
+	return DefaultRunner
+}
+
+// exec runs c using the repo's Runner and logs it. When the command fails
+// because ctx is done the context error is returned.
+func (b *base) exec(ctx context.Context, c *Command) error {
+	c = b.prepare(c)
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+
+	if !b.slogger().Enabled(ctx, slog.LevelDebug) {
+		return contextErr(ctx, b.runner().Run(ctx, c))
+	}
+
+	out := &cappedBuffer{}
+	lc := *c
+	lc.Stdout = teeWriter(c.Stdout, out)
+	if c.Stderr == c.Stdout {
+		lc.Stderr = lc.Stdout
+	} else {
+		lc.Stderr = teeWriter(c.Stderr, out)
+	}
+
+	start := time.Now()
+	err := contextErr(ctx, b.runner().Run(ctx, &lc))
+	b.logCommand(ctx, c, start, out, err)
+	return err
+}
+
+// combinedOutput runs a command in dir, or the current working directory if
+// dir is empty, and returns its combined standard output and error.
+func (b *base) combinedOutput(ctx context.Context, dir string, env []string, cmd string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	err := b.exec(ctx, &Command{
+		Name:   cmd,
+		Args:   args,
+		Dir:    dir,
+		Env:    env,
+		Stdout: &out,
+		Stderr: &out,
+	})
+	return out.Bytes(), err
+}
+
+// outputFromDir runs a command in the local directory and returns its standard
+// output, which is not mixed with warnings written to the standard error. The
+// standard error is returned for reporting failures.
+func (b *base) outputFromDir(ctx context.Context, cmd string, args ...string) ([]byte, string, error) {
+	var out bytes.Buffer
+	stderr := &cappedBuffer{}
+	err := b.exec(ctx, &Command{
+		Name:   cmd,
+		Args:   args,
+		Dir:    b.local,
+		Env:    envForDir(b.local),
+		Stdout: &out,
+		Stderr: stderr,
+	})
+	return out.Bytes(), stderr.String(), err
 }
 
 func (b *base) referenceList(c, r string) []string {
@@ -247,6 +479,33 @@ func (b *base) referenceList(c, r string) []string {
 	return out
 }
 
+// commandContext creates a command bound to ctx. When the context is done the
+// whole process group of the command is killed rather than only the direct
+// child. VCS tools regularly spawn helpers (ssh, git-remote-https, etc) that
+// would otherwise be left running.
+func commandContext(ctx context.Context, cmd string, args ...string) *exec.Cmd {
+	c := exec.CommandContext(ctx, cmd, args...)
+	setProcessGroup(c)
+	return c
+}
+
+// contextErr returns the error of ctx, when it is done, in place of err. A
+// command killed because of its context fails with an unhelpful "signal:
+// killed". Returning the context error makes a timeout distinguishable.
+func contextErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// isContextErr reports whether err is from a done context. That is either
+// the context passed to a method or, as only the command sees it, the one
+// bounded by WithTimeout, so ctx.Err() of the former is not enough.
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
 func envForDir(dir string) []string {
 	env := os.Environ()
 	return mergeEnvLists([]string{"PWD=" + dir}, env)
@@ -268,9 +527,5 @@ NextVar:
 }
 
 func depInstalled(name string) bool {
-	if _, err := exec.LookPath(name); err != nil {
-		return false
-	}
-
-	return true
+	return newConfig(nil).installed(name)
 }
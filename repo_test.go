@@ -1,10 +1,24 @@
 package vcs
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
+)
+
+// Canary tests to ensure the repo types implement the RepoContext interface.
+var (
+	_ RepoContext = &BzrRepo{}
+	_ RepoContext = &GitRepo{}
+	_ RepoContext = &HgRepo{}
+	_ RepoContext = &SvnRepo{}
 )
 
 func ExampleNewRepo() {
@@ -43,6 +57,103 @@ func TestDepInstalled(t *testing.T) {
 	}
 }
 
+func TestRunFromDirContextDeadline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not killed on Windows")
+	}
+
+	repo, err := NewGitRepo("", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The backgrounded sleep keeps the output pipe open. Unless the whole
+	// process group is killed the call would block until it exits.
+	start := time.Now()
+	_, err = repo.RunFromDirContext(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("command was not killed when the deadline was hit, took %s", d)
+	}
+}
+
+func TestGetContextCanceled(t *testing.T) {
+	tempDir := t.TempDir()
+
+	repo, err := NewGitRepo(tempDir+"/remote", tempDir+"/local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = repo.GetContext(ctx)
+	if _, ok := err.(*RemoteError); !ok {
+		t.Errorf("expected a RemoteError, got %T", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected error to wrap context.Canceled, got %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q", root},
+		{"-C", root, "remote", "add", "origin", "https://example.com/foo.git"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+
+	repo, err := Open(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.(*GitRepo); !ok {
+		t.Errorf("Open returned a %T", repo)
+	}
+	if repo.LocalPath() != root {
+		t.Errorf("Open did not find the root of the repo. Got %q", repo.LocalPath())
+	}
+	if repo.Remote() != "https://example.com/foo.git" {
+		t.Errorf("Open did not read the remote. Got %q", repo.Remote())
+	}
+
+	if _, err := Open(t.TempDir()); err != ErrCannotDetectVCS {
+		t.Errorf("expected ErrCannotDetectVCS outside a repo, got %v", err)
+	}
+}
+
+func TestFindRootSvn(t *testing.T) {
+	// Old Svn working copies have a .svn directory in every directory.
+	root := t.TempDir()
+	for _, d := range []string{".svn", "a/.svn", "a/b/.svn"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, ty, err := findRoot(filepath.Join(root, "a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != root || ty != Svn {
+		t.Errorf("expected %s for %s, got %s for %s", Svn, root, ty, dir)
+	}
+}
+
 func testLogger(t *testing.T) *log.Logger {
 	return log.New(testWriter{t}, "test", log.LstdFlags)
 }
@@ -0,0 +1,86 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+)
+
+// Command describes a single invocation of a VCS tool made by a Repo.
+type Command struct {
+	// Name is the program to run, such as git or svn.
+	Name string
+
+	// Args holds the command line arguments, not including the program name.
+	Args []string
+
+	// Dir is the working directory. An empty value means the working
+	// directory of the calling process.
+	Dir string
+
+	// Env is the environment of the command. A nil value means the
+	// environment of the calling process.
+	Env []string
+
+	// Stdout and Stderr receive the output of the command. When they are the
+	// same writer the output is combined as it would be in a terminal.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Runner executes the commands needed by a Repo. Every call a repo makes to a
+// VCS binary, including the probes run by the constructors, goes through its
+// Runner. Provide your own to use fakes in tests, to run the tools inside a
+// sandbox or container, or to audit what is being executed.
+//
+// The one exception is CmdFromDir, which returns an *exec.Cmd for callers to
+// run themselves.
+type Runner interface {
+
+	// LookPath reports the location of the named program or an error when the
+	// program is not available.
+	LookPath(file string) (string, error)
+
+	// Run executes the command and waits for it to complete. A command that
+	// runs but does not succeed should return an error implementing
+	// ExitCode() int, as *exec.ExitError does. When ctx is done the command
+	// should be stopped.
+	Run(ctx context.Context, cmd *Command) error
+}
+
+// DefaultRunner is the Runner repos use when they have not been given one. It
+// can be replaced to change the behavior of every repo created afterwards.
+var DefaultRunner Runner = ExecRunner{}
+
+// ExecRunner is a Runner that executes commands as local processes using the
+// os/exec package. When the context of a command is done the process and
+// every process it started are killed.
+type ExecRunner struct{}
+
+// LookPath searches for the named program in the directories of the PATH
+// environment variable.
+func (ExecRunner) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+// Run executes the command as a local process.
+func (ExecRunner) Run(ctx context.Context, cmd *Command) error {
+	c := commandContext(ctx, cmd.Name, cmd.Args...)
+	c.Dir = cmd.Dir
+	c.Env = cmd.Env
+	c.Stdout = cmd.Stdout
+	c.Stderr = cmd.Stderr
+	return c.Run()
+}
+
+// exitCode returns the exit status of a command that failed with err. When
+// the status is not known, such as when the program could not be started, -1
+// is returned.
+func exitCode(err error) int {
+	var ec interface{ ExitCode() int }
+	if errors.As(err, &ec) {
+		return ec.ExitCode()
+	}
+	return -1
+}
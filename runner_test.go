@@ -0,0 +1,148 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRunner is a Runner that answers commands from a table keyed by the
+// command line instead of executing anything.
+type fakeRunner struct {
+	responses map[string]fakeResponse
+	calls     []*Command
+	mu        sync.Mutex
+}
+
+type fakeResponse struct {
+	out  string
+	code int
+}
+
+func (f *fakeRunner) LookPath(file string) (string, error) {
+	return "/fake/bin/" + file, nil
+}
+
+func (f *fakeRunner) Run(_ context.Context, cmd *Command) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, cmd)
+	line := strings.Join(append([]string{cmd.Name}, cmd.Args...), " ")
+	resp, ok := f.responses[line]
+	f.mu.Unlock()
+	if !ok {
+		return &ExitError{Code: 127}
+	}
+	if cmd.Stdout != nil {
+		_, _ = cmd.Stdout.Write([]byte(resp.out))
+	}
+	if resp.code != 0 {
+		return &ExitError{Code: resp.code}
+	}
+	return nil
+}
+
+func useRunner(t *testing.T, r Runner) {
+	orig := DefaultRunner
+	DefaultRunner = r
+	t.Cleanup(func() {
+		DefaultRunner = orig
+	})
+}
+
+func TestRunnerConstructorProbes(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"git config --get remote.origin.url": {out: "https://example.com/foo.git\n"},
+		"git rev-parse HEAD":                 {out: "806b07b08faa21cfbdae93027904f80174679402\n"},
+		"hg paths":                           {out: "default = https://example.com/hg/foo\n"},
+	}}
+	useRunner(t, f)
+
+	gitDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(gitDir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := NewGitRepo("", gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gr.Remote() != "https://example.com/foo.git" {
+		t.Errorf("Git remote not read through the runner. Got %q", gr.Remote())
+	}
+	v, err := gr.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "806b07b08faa21cfbdae93027904f80174679402" {
+		t.Errorf("Git version not read through the runner. Got %q", v)
+	}
+
+	hgDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(hgDir, ".hg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	hr, err := NewHgRepo("", hgDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hr.Remote() != "https://example.com/hg/foo" {
+		t.Errorf("Hg remote not read through the runner. Got %q", hr.Remote())
+	}
+
+	for _, c := range f.calls {
+		if c.Dir != gitDir && c.Dir != hgDir {
+			t.Errorf("%s run from %q instead of the local repo", c.Name, c.Dir)
+		}
+	}
+}
+
+func TestRunnerDetachedHead(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"git fetch --tags -- origin": {},
+		"git symbolic-ref -q HEAD":   {code: 1},
+		"git symbolic-ref HEAD":      {out: "fatal: ref HEAD is not a symbolic ref\n", code: 128},
+		"git rev-parse HEAD":         {out: "30605f6ac35fcb075ad0bfa9296f90a7d891523e\n"},
+		"git show-ref -d":            {out: "30605f6ac35fcb075ad0bfa9296f90a7d891523e refs/tags/1.0.0\n"},
+		"git show-ref":               {out: "30605f6ac35fcb075ad0bfa9296f90a7d891523e refs/tags/1.0.0\n"},
+		"git pull":                   {out: "should not pull\n", code: 1},
+	}}
+	useRunner(t, f)
+
+	repo, err := NewGitRepo("https://example.com/foo.git", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A detached head must not be pulled.
+	if err := repo.Update(); err != nil {
+		t.Errorf("Update on a detached head failed: %s", err)
+	}
+
+	c, err := repo.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != "1.0.0" {
+		t.Errorf("Current did not find the tag for a detached head. Got %q", c)
+	}
+
+	tags, err := repo.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0] != "1.0.0" {
+		t.Errorf("Tags not parsed from the runner output. Got %v", tags)
+	}
+
+	f.responses["git show-ref"] = fakeResponse{code: 1}
+	_, err = repo.Tags()
+	if _, ok := err.(*LocalError); !ok {
+		t.Errorf("expected a LocalError when the runner fails, got %T", err)
+	}
+	if !errors.As(err, new(*ExitError)) || exitCode(err) != 1 {
+		t.Errorf("the exit code of the runner was lost: %v", err)
+	}
+}
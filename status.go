@@ -0,0 +1,101 @@
+package vcs
+
+import (
+	"context"
+	"sort"
+)
+
+// The ways a file in a working copy can differ from the checked out revision,
+// in addition to those of a diff.
+const (
+	// FileUntracked is a file that is not under version control.
+	FileUntracked FileStatus = "untracked"
+
+	// FileIgnored is a file that is not under version control and is ignored
+	// by the VCS.
+	FileIgnored FileStatus = "ignored"
+
+	// FileConflicted is a file with unresolved conflicts from a merge or
+	// update.
+	FileConflicted FileStatus = "conflicted"
+
+	// FileMissing is a file under version control that was removed from the
+	// working copy without telling the VCS.
+	FileMissing FileStatus = "missing"
+
+	// FileExternal is a Git submodule, Hg subrepo, or Svn external with
+	// changes of its own.
+	FileExternal FileStatus = "external"
+)
+
+// StatusOptions selects the entries listed by Status.
+type StatusOptions struct {
+	// Ignored includes the files ignored by the VCS, which can be many.
+	Ignored bool
+}
+
+// StatusEntry is a file, or a directory, that differs from the checked out
+// revision.
+type StatusEntry struct {
+	// Path is the slash separated path relative to the root of the working
+	// copy.
+	Path string
+
+	// OrigPath is the path a renamed file had before.
+	OrigPath string
+
+	Status FileStatus
+}
+
+// StatusRepo is the optional interface of repos that can list the changes
+// in their working copy.
+type StatusRepo interface {
+	// Status lists the files that differ from the checked out revision,
+	// sorted by path. Untracked files are listed individually rather than
+	// by their directory where the VCS allows it.
+	Status(opts StatusOptions) ([]StatusEntry, error)
+
+	// StatusContext is the context-aware version of Status.
+	StatusContext(ctx context.Context, opts StatusOptions) ([]StatusEntry, error)
+}
+
+// isDirty reports whether entries, as returned by Status, include changes.
+// Untracked files are changes when untracked is true. Ignored files never
+// are.
+func isDirty(entries []StatusEntry, untracked bool) bool {
+	for _, e := range entries {
+		switch e.Status {
+		case FileIgnored:
+		case FileUntracked:
+			if untracked {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// statusSet collects the entries of a status by path. Conflicts take
+// precedence over other statuses reported for the same path.
+type statusSet map[string]*StatusEntry
+
+func (s statusSet) add(e StatusEntry) {
+	if prev, ok := s[e.Path]; ok && prev.Status == FileConflicted {
+		return
+	}
+	s[e.Path] = &e
+}
+
+// entries returns the entries sorted by path.
+func (s statusSet) entries() []StatusEntry {
+	entries := make([]StatusEntry, 0, len(s))
+	for _, e := range s {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
@@ -0,0 +1,87 @@
+package vcs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGitStatus(t *testing.T) {
+	repo, _ := newGitTestRepo(t, gitTestCommit{Files: map[string]string{
+		".gitignore": "*.log\n", "a.txt": "a\n", "b.txt": "b\n", "c.txt": "c\n", "dir/d.txt": "d\n",
+	}})
+	local := repo.LocalPath()
+	git := func(args ...string) {
+		out, err := exec.Command("git", append([]string{"-C", local}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		p := filepath.Join(local, filepath.FromSlash(name))
+		writeFixtureFile(t, filepath.Dir(p), filepath.Base(p), content)
+	}
+
+	entries, err := repo.Status(StatusOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected a clean working copy, got %+v", entries)
+	}
+
+	write("untracked/deep/e.txt", "e\n")
+	if repo.IsDirty() {
+		t.Error("IsDirty counted an untracked file")
+	}
+	counting, err := NewGitRepo(repo.Remote(), local, WithCountUntracked())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !counting.IsDirty() {
+		t.Error("IsDirty did not count an untracked file with WithCountUntracked")
+	}
+
+	git("mv", "a.txt", "renamed.txt")
+	git("rm", "-q", "b.txt")
+	if err := os.Remove(filepath.Join(local, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	write("new.txt", "new\n")
+	git("add", "new.txt")
+	write("dir/d.txt", "changed\n")
+	write("debug.log", "log\n")
+
+	entries, err = repo.Status(StatusOptions{Ignored: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []StatusEntry{
+		{Path: "b.txt", Status: FileDeleted},
+		{Path: "c.txt", Status: FileMissing},
+		{Path: "debug.log", Status: FileIgnored},
+		{Path: "dir/d.txt", Status: FileModified},
+		{Path: "new.txt", Status: FileAdded},
+		{Path: "renamed.txt", OrigPath: "a.txt", Status: FileRenamed},
+		{Path: "untracked/deep/e.txt", Status: FileUntracked},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+	if !repo.IsDirty() {
+		t.Error("IsDirty did not report the changes")
+	}
+
+	// Changes that are only staged make the working copy dirty.
+	git("reset", "-q", "--hard")
+	if repo.IsDirty() {
+		t.Error("IsDirty reported a clean working copy as dirty")
+	}
+	write("dir/d.txt", "staged\n")
+	git("add", "dir/d.txt")
+	if !repo.IsDirty() {
+		t.Error("IsDirty did not report staged changes")
+	}
+}
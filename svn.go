@@ -1,12 +1,16 @@
 package vcs
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"io/fs"
+	"iter"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -15,9 +19,10 @@ import (
 // need to be passed in. The remote location should include the branch for SVN.
 // For example, if the package is https://github.com/Masterminds/cookoo/ the remote
 // should be https://github.com/Masterminds/cookoo/trunk for the trunk branch.
-func NewSvnRepo(remote, local string) (*SvnRepo, error) {
-	ins := depInstalled("svn")
-	if !ins {
+// Any opts are applied to the repo.
+func NewSvnRepo(remote, local string, opts ...Option) (*SvnRepo, error) {
+	c := newConfig(opts)
+	if !c.installed("svn") {
 		return nil, NewLocalError("svn is not installed", nil, "")
 	}
 	ltype, err := DetectVcsFromFS(local)
@@ -30,14 +35,16 @@ func NewSvnRepo(remote, local string) (*SvnRepo, error) {
 	r := &SvnRepo{}
 	r.setRemote(remote)
 	r.setLocalPath(local)
+	r.vcsType = Svn
 	r.Logger = Logger
+	r.configure(c)
 
 	// Make sure the local SVN repo is configured the same as the remote when
 	// A remote value was passed in.
 	if err == nil && r.CheckLocal() {
 		// An SVN repo was found so test that the URL there matches
 		// the repo passed in here.
-		out, err := exec.Command("svn", "info", "--", local).CombinedOutput()
+		out, err := r.combinedOutput(context.Background(), "", nil, "svn", "info", "--", local)
 		if err != nil {
 			return nil, NewLocalError("Unable to retrieve local repo information", err, string(out))
 		}
@@ -74,13 +81,18 @@ func (s SvnRepo) Vcs() Type {
 // Note, because SVN isn't distributed this is a checkout without
 // a clone.
 func (s *SvnRepo) Get() error {
+	return s.GetContext(context.Background())
+}
+
+// GetContext is the context-aware version of Get.
+func (s *SvnRepo) GetContext(ctx context.Context) error {
 	remote := s.Remote()
 	if strings.HasPrefix(remote, "/") {
 		remote = "file://" + remote
 	} else if runtime.GOOS == "windows" && filepath.VolumeName(remote) != "" {
 		remote = "file:///" + remote
 	}
-	out, err := s.run("svn", "checkout", "--", remote, s.LocalPath())
+	out, err := s.runContext(ctx, "svn", "checkout", "--", remote, s.LocalPath())
 	if err != nil {
 		return NewRemoteError("Unable to get repository", err, string(out))
 	}
@@ -89,7 +101,12 @@ func (s *SvnRepo) Get() error {
 
 // Init will create a svn repository at remote location.
 func (s *SvnRepo) Init() error {
-	out, err := s.run("svnadmin", "create", s.Remote())
+	return s.InitContext(context.Background())
+}
+
+// InitContext is the context-aware version of Init.
+func (s *SvnRepo) InitContext(ctx context.Context) error {
+	out, err := s.runContext(ctx, "svnadmin", "create", s.Remote())
 
 	if err != nil && s.isUnableToCreateDir(err) {
 
@@ -100,7 +117,7 @@ func (s *SvnRepo) Init() error {
 				return NewLocalError("Unable to initialize repository", err, "")
 			}
 
-			out, err = s.run("svnadmin", "create", s.Remote())
+			out, err = s.runContext(ctx, "svnadmin", "create", s.Remote())
 			if err != nil {
 				return NewLocalError("Unable to initialize repository", err, string(out))
 			}
@@ -116,7 +133,12 @@ func (s *SvnRepo) Init() error {
 
 // Update performs an SVN update to an existing checkout.
 func (s *SvnRepo) Update() error {
-	out, err := s.RunFromDir("svn", "update")
+	return s.UpdateContext(context.Background())
+}
+
+// UpdateContext is the context-aware version of Update.
+func (s *SvnRepo) UpdateContext(ctx context.Context) error {
+	out, err := s.RunFromDirContext(ctx, "svn", "update")
 	if err != nil {
 		return NewRemoteError("Unable to update repository", err, string(out))
 	}
@@ -125,7 +147,12 @@ func (s *SvnRepo) Update() error {
 
 // UpdateVersion sets the version of a package currently checked out via SVN.
 func (s *SvnRepo) UpdateVersion(version string) error {
-	out, err := s.RunFromDir("svn", "update", "-r", version)
+	return s.UpdateVersionContext(context.Background(), version)
+}
+
+// UpdateVersionContext is the context-aware version of UpdateVersion.
+func (s *SvnRepo) UpdateVersionContext(ctx context.Context, version string) error {
+	out, err := s.RunFromDirContext(ctx, "svn", "update", "-r", version)
 	if err != nil {
 		return NewRemoteError("Unable to update checked out version", err, string(out))
 	}
@@ -134,6 +161,11 @@ func (s *SvnRepo) UpdateVersion(version string) error {
 
 // Version retrieves the current version.
 func (s *SvnRepo) Version() (string, error) {
+	return s.VersionContext(context.Background())
+}
+
+// VersionContext is the context-aware version of Version.
+func (s *SvnRepo) VersionContext(ctx context.Context) (string, error) {
 	type Commit struct {
 		Revision string `xml:"revision,attr"`
 	}
@@ -141,7 +173,7 @@ func (s *SvnRepo) Version() (string, error) {
 		Commit Commit `xml:"entry>commit"`
 	}
 
-	out, err := s.RunFromDir("svn", "info", "--xml")
+	out, err := s.RunFromDirContext(ctx, "svn", "info", "--xml")
 	if err != nil {
 		return "", NewLocalError("Unable to retrieve checked out version", err, string(out))
 	}
@@ -159,12 +191,17 @@ func (s *SvnRepo) Version() (string, error) {
 // * HEAD if on the tip.
 // * Otherwise a revision id
 func (s *SvnRepo) Current() (string, error) {
-	tip, err := s.CommitInfo("HEAD")
+	return s.CurrentContext(context.Background())
+}
+
+// CurrentContext is the context-aware version of Current.
+func (s *SvnRepo) CurrentContext(ctx context.Context) (string, error) {
+	tip, err := s.CommitInfoContext(ctx, "HEAD")
 	if err != nil {
 		return "", err
 	}
 
-	curr, err := s.Version()
+	curr, err := s.VersionContext(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -178,11 +215,16 @@ func (s *SvnRepo) Current() (string, error) {
 
 // Date retrieves the date on the latest commit.
 func (s *SvnRepo) Date() (time.Time, error) {
-	version, err := s.Version()
+	return s.DateContext(context.Background())
+}
+
+// DateContext is the context-aware version of Date.
+func (s *SvnRepo) DateContext(ctx context.Context) (time.Time, error) {
+	version, err := s.VersionContext(ctx)
 	if err != nil {
 		return time.Time{}, NewLocalError("Unable to retrieve revision date", err, "")
 	}
-	out, err := s.RunFromDir("svn", "pget", "svn:date", "--revprop", "-r", version)
+	out, err := s.RunFromDirContext(ctx, "svn", "pget", "svn:date", "--revprop", "-r", version)
 	if err != nil {
 		return time.Time{}, NewLocalError("Unable to retrieve revision date", err, string(out))
 	}
@@ -227,6 +269,11 @@ func (s *SvnRepo) Tags() ([]string, error) {
 	return []string{}, nil
 }
 
+// TagsContext is the context-aware version of Tags.
+func (s *SvnRepo) TagsContext(_ context.Context) ([]string, error) {
+	return s.Tags()
+}
+
 // Branches returns []string{} as there are no formal branches in SVN. Branches
 // are a convention. They are typically implemented as a copy of the trunk and
 // placed in the /branches/[tag name] directory. Since this is a convention the
@@ -237,10 +284,20 @@ func (s *SvnRepo) Branches() ([]string, error) {
 	return []string{}, nil
 }
 
+// BranchesContext is the context-aware version of Branches.
+func (s *SvnRepo) BranchesContext(_ context.Context) ([]string, error) {
+	return s.Branches()
+}
+
 // IsReference returns if a string is a reference. A reference is a commit id.
 // Branches and tags are part of the path.
 func (s *SvnRepo) IsReference(r string) bool {
-	out, err := s.RunFromDir("svn", "log", "-r", r)
+	return s.IsReferenceContext(context.Background(), r)
+}
+
+// IsReferenceContext is the context-aware version of IsReference.
+func (s *SvnRepo) IsReferenceContext(ctx context.Context, r string) bool {
+	out, err := s.RunFromDirContext(ctx, "svn", "log", "-r", r)
 
 	// This is a complete hack. There must be a better way to do this. Pull
 	// requests welcome. When the reference isn't real you get a line of
@@ -256,14 +313,298 @@ func (s *SvnRepo) IsReference(r string) bool {
 }
 
 // IsDirty returns if the checkout has been modified from the checked
-// out reference.
+// out reference. Untracked files only count when the repo was created with
+// WithCountUntracked.
 func (s *SvnRepo) IsDirty() bool {
-	out, err := s.RunFromDir("svn", "diff")
-	return err != nil || len(out) != 0
+	return s.IsDirtyContext(context.Background())
+}
+
+// IsDirtyContext is the context-aware version of IsDirty.
+func (s *SvnRepo) IsDirtyContext(ctx context.Context) bool {
+	entries, err := s.status(ctx, StatusOptions{}, s.countUntracked)
+	return err != nil || isDirty(entries, s.countUntracked)
+}
+
+// Status lists the files in the working copy that differ from the checked
+// out revision. See StatusRepo for details.
+func (s *SvnRepo) Status(opts StatusOptions) ([]StatusEntry, error) {
+	return s.StatusContext(context.Background(), opts)
+}
+
+// StatusContext is the context-aware version of Status.
+func (s *SvnRepo) StatusContext(ctx context.Context, opts StatusOptions) ([]StatusEntry, error) {
+	return s.status(ctx, opts, true)
+}
+
+// status lists the files as StatusContext does, leaving out the unversioned
+// files when untracked is false.
+func (s *SvnRepo) status(ctx context.Context, opts StatusOptions, untracked bool) ([]StatusEntry, error) {
+	args := []string{"status", "--xml"}
+	if opts.Ignored {
+		args = append(args, "--no-ignore")
+	}
+	if !untracked {
+		args = append(args, "--quiet")
+	}
+	out, stderr, err := s.outputFromDir(ctx, "svn", args...)
+	if err != nil {
+		return nil, NewLocalError("Unable to retrieve status", err, stderr)
+	}
+	entries, err := parseSvnStatus(out)
+	if err != nil {
+		return nil, NewLocalError("Unable to retrieve status", err, string(out))
+	}
+	return entries, nil
+}
+
+// parseSvnStatus parses the output of svn status --xml. The first target is
+// the working copy and the others are its externals, whose changes are
+// reported as a change to the external.
+func parseSvnStatus(out []byte) ([]StatusEntry, error) {
+	type WcStatus struct {
+		Item           string `xml:"item,attr"`
+		Props          string `xml:"props,attr"`
+		TreeConflicted string `xml:"tree-conflicted,attr"`
+		MovedFrom      string `xml:"moved-from,attr"`
+		MovedTo        string `xml:"moved-to,attr"`
+	}
+	type Entry struct {
+		Path     string   `xml:"path,attr"`
+		WcStatus WcStatus `xml:"wc-status"`
+	}
+	type Target struct {
+		Path    string  `xml:"path,attr"`
+		Entries []Entry `xml:"entry"`
+	}
+	type Status struct {
+		XMLName xml.Name `xml:"status"`
+		Targets []Target `xml:"target"`
+	}
+
+	st := &Status{}
+	if err := xml.Unmarshal(out, st); err != nil {
+		return nil, err
+	}
+
+	set := statusSet{}
+	for i, t := range st.Targets {
+		for _, e := range t.Entries {
+			w := e.WcStatus
+			var fs FileStatus
+			switch {
+			case w.Item == "conflicted" || w.Props == "conflicted" || w.TreeConflicted == "true":
+				fs = FileConflicted
+			case w.Item == "added" && w.MovedFrom != "":
+				fs = FileRenamed
+			case w.Item == "added":
+				fs = FileAdded
+			case w.Item == "deleted" && w.MovedTo != "":
+				// Listed as the destination of the move.
+				continue
+			case w.Item == "deleted":
+				fs = FileDeleted
+			case w.Item == "missing":
+				fs = FileMissing
+			case w.Item == "unversioned":
+				fs = FileUntracked
+			case w.Item == "ignored":
+				fs = FileIgnored
+			case w.Item == "modified" || w.Item == "replaced" || w.Item == "merged" ||
+				w.Item == "obstructed" || w.Item == "incomplete" || w.Props == "modified":
+				fs = FileModified
+			default:
+				// Unchanged entries, including the directories of externals.
+				continue
+			}
+
+			if i > 0 {
+				if fs != FileIgnored {
+					set.add(StatusEntry{Path: filepath.ToSlash(t.Path), Status: FileExternal})
+				}
+				continue
+			}
+			se := StatusEntry{Path: filepath.ToSlash(e.Path), Status: fs}
+			if fs == FileRenamed {
+				se.OrigPath = filepath.ToSlash(w.MovedFrom)
+			}
+			set.add(se)
+		}
+	}
+	return set.entries(), nil
+}
+
+// Diff returns the parsed differences between the revisions from and to.
+// See DiffRepo for their meaning.
+func (s *SvnRepo) Diff(from, to string, paths ...string) (*Diff, error) {
+	return s.DiffContext(context.Background(), from, to, paths...)
+}
+
+// DiffContext is the context-aware version of Diff.
+func (s *SvnRepo) DiffContext(ctx context.Context, from, to string, paths ...string) (*Diff, error) {
+	return diff(ctx, func(ctx context.Context, w io.Writer) error {
+		return s.WriteDiffContext(ctx, w, from, to, paths...)
+	})
+}
+
+// WriteDiff writes the unified diff between the revisions from and to to w.
+// See DiffRepo for their meaning.
+func (s *SvnRepo) WriteDiff(w io.Writer, from, to string, paths ...string) error {
+	return s.WriteDiffContext(context.Background(), w, from, to, paths...)
+}
+
+// WriteDiffContext is the context-aware version of WriteDiff.
+func (s *SvnRepo) WriteDiffContext(ctx context.Context, w io.Writer, from, to string, paths ...string) error {
+	if from == "" {
+		from = "BASE"
+	}
+	if to != "" {
+		from += ":" + to
+	}
+	args := append([]string{"diff", "--git", "--internal-diff", "-r", from, "--"}, paths...)
+	return s.writeDiff(ctx, w, "svn", args...)
+}
+
+// ReadFile returns the contents of the file at path in the revision rev. See
+// FileRepo for details.
+func (s *SvnRepo) ReadFile(rev, path string) (io.ReadCloser, error) {
+	return s.ReadFileContext(context.Background(), rev, path)
+}
+
+// ReadFileContext is the context-aware version of ReadFile.
+func (s *SvnRepo) ReadFileContext(ctx context.Context, rev, path string) (io.ReadCloser, error) {
+	fi, target, err := s.stat(ctx, rev, path)
+	if err != nil {
+		return nil, err
+	}
+	return s.readFile(ctx, fi, "svn", "cat", "--", target)
+}
+
+// Stat describes the file or directory at path in the revision rev. See
+// FileRepo for details.
+func (s *SvnRepo) Stat(rev, path string) (*FileInfo, error) {
+	return s.StatContext(context.Background(), rev, path)
+}
+
+// StatContext is the context-aware version of Stat.
+func (s *SvnRepo) StatContext(ctx context.Context, rev, path string) (*FileInfo, error) {
+	fi, _, err := s.stat(ctx, rev, path)
+	return fi, err
+}
+
+// stat describes the file or directory at path in the revision rev and
+// returns the target that svn cat reads it from. Files are looked up in the
+// listing of their directory as svn info does not give their size.
+func (s *SvnRepo) stat(ctx context.Context, rev, path string) (*FileInfo, string, error) {
+	path = cleanRepoPath(path)
+	url, rev, err := s.revisionURL(ctx, rev)
+	if err != nil {
+		return nil, "", err
+	}
+	target := url + "@" + rev
+	if path == "." {
+		_, stderr, err := s.outputFromDir(ctx, "svn", "info", "--xml", "--", target)
+		if err != nil {
+			return nil, "", svnFileError(err, stderr)
+		}
+		return &FileInfo{Path: path, Mode: fs.ModeDir | 0755}, target, nil
+	}
+	dir := url
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		dir += "/" + path[:i]
+	}
+	out, stderr, err := s.outputFromDir(ctx, "svn", "list", "--xml", "--", dir+"@"+rev)
+	if err != nil {
+		return nil, "", svnFileError(err, stderr)
+	}
+	fi, err := parseSvnList(out, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return fi, url + "/" + path + "@" + rev, nil
+}
+
+// revisionURL returns the URL of the working copy and rev. The revisions
+// that only apply to the working copy, such as BASE, are resolved to their
+// number so that they can be used with the URL.
+func (s *SvnRepo) revisionURL(ctx context.Context, rev string) (string, string, error) {
+	if rev == "" {
+		rev = "BASE"
+	}
+	args := []string{"info", "--xml"}
+	local := rev == "BASE" || rev == "COMMITTED" || rev == "PREV"
+	if local {
+		args = append(args, "-r", rev)
+	}
+	out, err := s.RunFromDirContext(ctx, "svn", args...)
+	if err != nil {
+		if !local || isContextErr(err) {
+			return "", "", NewLocalError("Unable to retrieve file information", err, string(out))
+		}
+		return "", "", ErrRevisionUnavailable
+	}
+	var info struct {
+		Entry struct {
+			Revision string `xml:"revision,attr"`
+			URL      string `xml:"url"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(out, &info); err != nil {
+		return "", "", NewLocalError("Unable to retrieve file information", err, string(out))
+	}
+	if local {
+		rev = info.Entry.Revision
+	}
+	return info.Entry.URL, rev, nil
+}
+
+// parseSvnList finds path in the output of svn list --xml for its directory.
+func parseSvnList(out []byte, path string) (*FileInfo, error) {
+	var lists struct {
+		Entries []struct {
+			Kind string `xml:"kind,attr"`
+			Name string `xml:"name"`
+			Size int64  `xml:"size"`
+		} `xml:"list>entry"`
+	}
+	if err := xml.Unmarshal(out, &lists); err != nil {
+		return nil, NewLocalError("Unable to retrieve file information", err, string(out))
+	}
+	name := path[strings.LastIndexByte(path, '/')+1:]
+	for _, e := range lists.Entries {
+		if e.Name != name {
+			continue
+		}
+		if e.Kind == "dir" {
+			return &FileInfo{Path: path, Mode: fs.ModeDir | 0755}, nil
+		}
+		return &FileInfo{Path: path, Size: e.Size, Mode: 0644}, nil
+	}
+	return nil, ErrFileNotFound
+}
+
+// svnFileError returns the error for a failed svn info or svn list of a
+// target at a revision.
+func svnFileError(err error, stderr string) error {
+	switch {
+	case isContextErr(err):
+		return NewLocalError("Unable to retrieve file information", err, stderr)
+	case strings.Contains(stderr, "E160006"):
+		// No such revision
+		return ErrRevisionUnavailable
+	case strings.Contains(stderr, "W160013"), strings.Contains(stderr, "W170000"), strings.Contains(stderr, "E200009"):
+		// The target does not exist in the revision.
+		return ErrFileNotFound
+	}
+	return NewRemoteError("Unable to retrieve file information", err, stderr)
 }
 
 // CommitInfo retrieves metadata about a commit.
 func (s *SvnRepo) CommitInfo(id string) (*CommitInfo, error) {
+	return s.CommitInfoContext(context.Background(), id)
+}
+
+// CommitInfoContext is the context-aware version of CommitInfo.
+func (s *SvnRepo) CommitInfoContext(ctx context.Context, id string) (*CommitInfo, error) {
 
 	// There are cases where Svn log doesn't return anything for HEAD or BASE.
 	// svn info does provide details for these but does not have elements like
@@ -276,7 +617,7 @@ func (s *SvnRepo) CommitInfo(id string) (*CommitInfo, error) {
 			Commit Commit `xml:"entry>commit"`
 		}
 
-		out, err := s.RunFromDir("svn", "info", "-r", id, "--xml")
+		out, err := s.RunFromDirContext(ctx, "svn", "info", "-r", id, "--xml")
 		if err != nil {
 			return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
 		}
@@ -292,7 +633,7 @@ func (s *SvnRepo) CommitInfo(id string) (*CommitInfo, error) {
 		}
 	}
 
-	out, err := s.RunFromDir("svn", "log", "-r", id, "--xml")
+	out, err := s.RunFromDirContext(ctx, "svn", "log", "-r", id, "--xml")
 	if err != nil {
 		// Newer versions of svn can return an error here if a revision is not found.
 		if strings.Contains(string(out), "No such revision") {
@@ -301,14 +642,9 @@ func (s *SvnRepo) CommitInfo(id string) (*CommitInfo, error) {
 		return nil, NewRemoteError("Unable to retrieve commit information", err, string(out))
 	}
 
-	type Logentry struct {
-		Author string `xml:"author"`
-		Date   string `xml:"date"`
-		Msg    string `xml:"msg"`
-	}
 	type Log struct {
-		XMLName xml.Name   `xml:"log"`
-		Logs    []Logentry `xml:"logentry"`
+		XMLName xml.Name      `xml:"log"`
+		Logs    []svnLogentry `xml:"logentry"`
 	}
 
 	logs := &Log{}
@@ -320,22 +656,129 @@ func (s *SvnRepo) CommitInfo(id string) (*CommitInfo, error) {
 		return nil, ErrRevisionUnavailable
 	}
 
-	ci := &CommitInfo{
-		Commit:  id,
-		Author:  logs.Logs[0].Author,
-		Message: logs.Logs[0].Msg,
+	ci, err := logs.Logs[0].commitInfo()
+	if err != nil {
+		return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
 	}
+	return ci, nil
+}
+
+// svnLogentry is an entry of the XML output of svn log.
+type svnLogentry struct {
+	Revision string `xml:"revision,attr"`
+	Author   string `xml:"author"`
+	Date     string `xml:"date"`
+	Msg      string `xml:"msg"`
+}
 
-	if len(logs.Logs[0].Date) > 0 {
-		ci.Date, err = time.Parse(time.RFC3339Nano, logs.Logs[0].Date)
+// commitInfo returns the CommitInfo for e. Svn history is linear so the
+// parent of a revision is the one before it.
+func (e *svnLogentry) commitInfo() (*CommitInfo, error) {
+	ci := &CommitInfo{
+		Commit:        e.Revision,
+		Author:        e.Author,
+		AuthorName:    e.Author,
+		CommitterName: e.Author,
+	}
+	if n, err := strconv.Atoi(e.Revision); err == nil && n > 1 {
+		ci.Parents = []string{strconv.Itoa(n - 1)}
+	}
+	if len(e.Date) > 0 {
+		t, err := time.Parse(time.RFC3339Nano, e.Date)
 		if err != nil {
-			return nil, NewLocalError("Unable to retrieve commit information", err, string(out))
+			return nil, err
 		}
+		ci.Date, ci.CommitDate = t, t
 	}
-
+	ci.setMessage(e.Msg)
 	return ci, nil
 }
 
+// Log returns an iterator over the commits selected by opts, newest first.
+func (s *SvnRepo) Log(opts LogOptions) iter.Seq2[*CommitInfo, error] {
+	return s.LogContext(context.Background(), opts)
+}
+
+// LogContext is the context-aware version of Log.
+func (s *SvnRepo) LogContext(ctx context.Context, opts LogOptions) iter.Seq2[*CommitInfo, error] {
+	return logSeq(ctx, opts, func(ctx context.Context, emit func(*CommitInfo) bool) error {
+		// The range of svn log includes its start, which is excluded here.
+		to, from, skip, extra := opts.To, "1", "", 0
+		if to == "" {
+			to = "BASE"
+		}
+		var fromInfo *CommitInfo
+		if opts.From != "" {
+			ci, err := s.CommitInfoContext(ctx, opts.From)
+			if err != nil {
+				return err
+			}
+			fromInfo, from, skip, extra = ci, ci.Commit, ci.Commit, 1
+		}
+
+		// A date in the range is the revision in effect at that time, the
+		// last one committed before it. The dates replace the ends of the
+		// range they narrow, which the dates of the revisions tell.
+		if !opts.Since.IsZero() || !opts.Until.IsZero() {
+			top, err := s.CommitInfoContext(ctx, to)
+			if err != nil {
+				return err
+			}
+			if !opts.Since.IsZero() {
+				if top.CommitDate.Before(opts.Since) {
+					return nil
+				}
+				// The revision in effect at Since can be older than it. It
+				// is removed by the final check of the dates.
+				if fromInfo == nil || fromInfo.CommitDate.Before(opts.Since) {
+					from, skip, extra = svnDate(opts.Since), "", 1
+				}
+			}
+			if !opts.Until.IsZero() && top.CommitDate.After(opts.Until) {
+				if fromInfo != nil && fromInfo.CommitDate.After(opts.Until) {
+					return nil
+				}
+				to = svnDate(opts.Until)
+			}
+		}
+
+		args := []string{"log", "--xml", "-r", to + ":" + from}
+		if n := opts.limit(extra, opts.Author == ""); n != "" {
+			args = append(args, "-l", n)
+		}
+		target := "."
+		if opts.Path != "" {
+			target = opts.Path
+		}
+		args = append(args, "--", target)
+
+		return s.streamFromDir(ctx, func(r io.Reader) error {
+			return decodeLogEntries(r, func(d *xml.Decoder, start *xml.StartElement) error {
+				var e svnLogentry
+				if err := d.DecodeElement(&e, start); err != nil {
+					return err
+				}
+				if skip != "" && e.Revision == skip {
+					return nil
+				}
+				ci, err := e.commitInfo()
+				if err != nil {
+					return err
+				}
+				if !emit(ci) {
+					return errStopLog
+				}
+				return nil
+			})
+		}, "svn", args...)
+	})
+}
+
+// svnDate returns the revision of Svn in effect at t.
+func svnDate(t time.Time) string {
+	return "{" + t.UTC().Format("2006-01-02T15:04:05Z") + "}"
+}
+
 // TagsFromCommit retrieves tags from a commit id.
 func (s *SvnRepo) TagsFromCommit(_ string) ([]string, error) {
 	// Svn tags are a convention implemented as paths. See the details on the
@@ -343,16 +786,31 @@ func (s *SvnRepo) TagsFromCommit(_ string) ([]string, error) {
 	return []string{}, nil
 }
 
+// TagsFromCommitContext is the context-aware version of TagsFromCommit.
+func (s *SvnRepo) TagsFromCommitContext(_ context.Context, id string) ([]string, error) {
+	return s.TagsFromCommit(id)
+}
+
 // Ping returns if remote location is accessible.
 func (s *SvnRepo) Ping() bool {
-	_, err := s.run("svn", "--non-interactive", "info", "--", s.Remote())
+	return s.PingContext(context.Background())
+}
+
+// PingContext is the context-aware version of Ping.
+func (s *SvnRepo) PingContext(ctx context.Context) bool {
+	_, err := s.runContext(ctx, "svn", "--non-interactive", "info", "--", s.Remote())
 	return err == nil
 }
 
 // ExportDir exports the current revision to the passed in directory.
 func (s *SvnRepo) ExportDir(dir string) error {
+	return s.ExportDirContext(context.Background(), dir)
+}
+
+// ExportDirContext is the context-aware version of ExportDir.
+func (s *SvnRepo) ExportDirContext(ctx context.Context, dir string) error {
 
-	out, err := s.RunFromDir("svn", "export", "--", ".", dir)
+	out, err := s.RunFromDirContext(ctx, "svn", "export", "--", ".", dir)
 	s.log(out)
 	if err != nil {
 		return NewLocalError("Unable to export source", err, string(out))
@@ -361,6 +819,41 @@ func (s *SvnRepo) ExportDir(dir string) error {
 	return nil
 }
 
+// ExportModuleZip writes the Go module zip file for the module modulePath at
+// version, found in subdir of the repo, to w. The current revision is
+// exported and the files are laid out as the go command expects, with the
+// rules of golang.org/x/mod/zip applied.
+// Externals are not included as they are not part of a module.
+func (s *SvnRepo) ExportModuleZip(w io.Writer, modulePath, version, subdir string) error {
+	return s.ExportModuleZipContext(context.Background(), w, modulePath, version, subdir)
+}
+
+// ExportModuleZipContext is the context-aware version of ExportModuleZip.
+func (s *SvnRepo) ExportModuleZipContext(ctx context.Context, w io.Writer, modulePath, version, subdir string) error {
+	return writeModuleZip(ctx, s.exportSource, w, modulePath, version, subdir)
+}
+
+// DirHash returns the h1: hash, as recorded in go.sum, of the module zip file
+// ExportModuleZip writes for the current revision.
+func (s *SvnRepo) DirHash(modulePath, version, subdir string) (string, error) {
+	return s.DirHashContext(context.Background(), modulePath, version, subdir)
+}
+
+// DirHashContext is the context-aware version of DirHash.
+func (s *SvnRepo) DirHashContext(ctx context.Context, modulePath, version, subdir string) (string, error) {
+	return moduleHash(ctx, s.exportSource, modulePath, version, subdir)
+}
+
+// exportSource exports the current revision, without externals, to dir.
+func (s *SvnRepo) exportSource(ctx context.Context, dir string) error {
+	out, err := s.RunFromDirContext(ctx, "svn", "export", "--ignore-externals", "--", ".", dir)
+	s.log(out)
+	if err != nil {
+		return NewLocalError("Unable to export source", err, string(out))
+	}
+	return nil
+}
+
 // isUnableToCreateDir checks for an error in Init() to see if an error
 // where the parent directory of the VCS local path doesn't exist.
 func (s *SvnRepo) isUnableToCreateDir(err error) bool {
@@ -3,6 +3,8 @@ package vcs
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 	//"log"
@@ -11,8 +13,15 @@ import (
 // To verify svn is working we perform integration testing
 // with a known svn service.
 
-// Canary test to ensure SvnRepo implements the Repo interface.
-var _ Repo = &SvnRepo{}
+// Canary test to ensure SvnRepo implements the Repo interface and the optional
+// interfaces.
+var (
+	_ Repo        = &SvnRepo{}
+	_ DiffRepo    = &SvnRepo{}
+	_ FileRepo    = &SvnRepo{}
+	_ HistoryRepo = &SvnRepo{}
+	_ StatusRepo  = &SvnRepo{}
+)
 
 func TestSvn(t *testing.T) {
 
@@ -355,3 +364,209 @@ func TestDetectRemoteFromInfoCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSvnStatus(t *testing.T) {
+	out := `<?xml version="1.0" encoding="UTF-8"?>
+<status>
+<target path=".">
+<entry path="a.txt"><wc-status item="modified" props="none" revision="2"></wc-status></entry>
+<entry path="b.txt"><wc-status item="normal" props="modified" revision="2"></wc-status></entry>
+<entry path="c.txt"><wc-status item="conflicted" props="none" revision="2"></wc-status></entry>
+<entry path="d.txt"><wc-status item="deleted" props="none" moved-to="e.txt" revision="2"></wc-status></entry>
+<entry path="e.txt"><wc-status item="added" props="none" moved-from="d.txt" revision="-1"></wc-status></entry>
+<entry path="ext"><wc-status item="external" props="none"></wc-status></entry>
+<entry path="f.txt"><wc-status item="missing" props="none" revision="2"></wc-status></entry>
+<entry path="g.txt"><wc-status item="unversioned" props="none"></wc-status></entry>
+<entry path="h.log"><wc-status item="ignored" props="none"></wc-status></entry>
+<entry path="i.txt"><wc-status item="normal" props="none" tree-conflicted="true" revision="2"></wc-status></entry>
+</target>
+<target path="ext">
+<entry path="ext/x.txt"><wc-status item="modified" props="none" revision="1"></wc-status></entry>
+</target>
+</status>
+`
+	entries, err := parseSvnStatus([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []StatusEntry{
+		{Path: "a.txt", Status: FileModified},
+		{Path: "b.txt", Status: FileModified},
+		{Path: "c.txt", Status: FileConflicted},
+		{Path: "e.txt", OrigPath: "d.txt", Status: FileRenamed},
+		{Path: "ext", Status: FileExternal},
+		{Path: "f.txt", Status: FileMissing},
+		{Path: "g.txt", Status: FileUntracked},
+		{Path: "h.log", Status: FileIgnored},
+		{Path: "i.txt", Status: FileConflicted},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+}
+
+func TestParseSvnList(t *testing.T) {
+	out := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<lists>
+<list path="file:///repo/trunk/dir@2">
+<entry kind="file">
+<name>b.txt</name>
+<size>2</size>
+<commit revision="1">
+<author>Test</author>
+<date>2015-07-29T13:46:39.000000Z</date>
+</commit>
+</entry>
+<entry kind="dir">
+<name>sub</name>
+<commit revision="2">
+<author>Test</author>
+<date>2015-07-29T14:46:39.000000Z</date>
+</commit>
+</entry>
+</list>
+</lists>
+`)
+	fi, err := parseSvnList(out, "dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (FileInfo{Path: "dir/b.txt", Size: 2, Mode: 0644}); *fi != expected {
+		t.Errorf("expected %+v, got %+v", expected, *fi)
+	}
+	fi, err = parseSvnList(out, "dir/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Errorf("expected a directory, got %+v", *fi)
+	}
+	if _, err := parseSvnList(out, "dir/c.txt"); err != ErrFileNotFound {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestSvnLog(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"svn log --xml -r BASE:1 -- .": {out: `<?xml version="1.0" encoding="UTF-8"?>
+<log>
+<logentry revision="2">
+<author>author</author>
+<date>2015-07-30T14:00:00.000000Z</date>
+<msg>Update README.md
+
+Signed-off-by: Bob &lt;bob@example.com&gt;
+</msg>
+</logentry>
+<logentry revision="1">
+<author>author</author>
+<date>2015-07-29T13:46:39.000000Z</date>
+<msg>Initial commit</msg>
+</logentry>
+</log>
+`},
+	}}
+	repo := &SvnRepo{}
+	repo.setLocalPath(t.TempDir())
+	repo.Runner = f
+
+	var commits []*CommitInfo
+	for ci, err := range repo.Log(LogOptions{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, ci)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(commits))
+	}
+	d := time.Date(2015, 7, 30, 14, 0, 0, 0, time.UTC)
+	expected := &CommitInfo{
+		Commit:        "2",
+		Author:        "author",
+		AuthorName:    "author",
+		CommitterName: "author",
+		Date:          d,
+		CommitDate:    d,
+		Parents:       []string{"1"},
+		Message:       "Update README.md\n\nSigned-off-by: Bob <bob@example.com>",
+		Trailers:      []Trailer{{Key: "Signed-off-by", Value: "Bob <bob@example.com>"}},
+	}
+	if !reflect.DeepEqual(commits[0], expected) {
+		t.Errorf("expected %+v, got %+v", expected, commits[0])
+	}
+	if commits[1].Commit != "1" || commits[1].Parents != nil {
+		t.Errorf("unexpected first commit %+v", commits[1])
+	}
+}
+
+func TestParseSvnDiff(t *testing.T) {
+	d, err := ParseDiff(strings.NewReader(`Index: a.txt
+===================================================================
+diff --git a/a.txt b/a.txt
+--- a/a.txt	(revision 1)
++++ b/a.txt	(working copy)
+@@ -1 +1,2 @@
+ a
++b
+Index: bin.dat
+===================================================================
+Cannot display: file marked as a binary type.
+svn:mime-type = application/octet-stream
+Index: plain.txt
+===================================================================
+--- plain.txt	(revision 1)
++++ plain.txt	(working copy)
+@@ -1 +1 @@
+-x
++y
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fileSummary{
+		{"a.txt", "a.txt", FileModified, false, 1, 0},
+		{"bin.dat", "bin.dat", FileModified, true, 0, 0},
+		{"plain.txt", "plain.txt", FileModified, false, 1, 1},
+	}
+	if got := summarize(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestSvnLogFilters(t *testing.T) {
+	entry := func(rev, date string) string {
+		return `<logentry revision="` + rev + `"><author>author</author><date>` + date + `</date><msg>Commit</msg></logentry>`
+	}
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"svn info -r BASE --xml": {out: `<?xml version="1.0"?><info><entry><commit revision="3"></commit></entry></info>`},
+		"svn log -r 3 --xml":     {out: `<?xml version="1.0"?><log>` + entry("3", "2015-07-29T15:00:00.000000Z") + `</log>`},
+		"svn log --xml -r {2015-07-29T14:46:39Z}:{2015-07-29T13:46:39Z} -l 3 -- .": {out: `<?xml version="1.0"?><log>` +
+			entry("2", "2015-07-29T14:00:00.000000Z") + entry("1", "2015-07-29T13:00:00.000000Z") + `</log>`},
+	}}
+	repo := &SvnRepo{}
+	repo.setLocalPath(t.TempDir())
+	repo.Runner = f
+
+	// The revision in effect at Since is older than it and is not listed.
+	since := time.Date(2015, 7, 29, 13, 46, 39, 0, time.UTC)
+	var got []string
+	for ci, err := range repo.Log(LogOptions{Since: since, Until: since.Add(time.Hour), Max: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ci.Commit)
+	}
+	if !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("expected revision 2, got %v", got)
+	}
+
+	// A window after the checked out revision has no commits.
+	f.calls = nil
+	for _, err := range repo.Log(LogOptions{Since: since.Add(2 * time.Hour)}) {
+		t.Errorf("expected no commits, got %v", err)
+	}
+	if len(f.calls) != 2 {
+		t.Errorf("expected only the checked out revision to be read, got %d commands", len(f.calls))
+	}
+}
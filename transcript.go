@@ -0,0 +1,259 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// transcriptRoot is the placeholder written to transcripts in place of the
+// root directory passed to a RecordingRunner. It allows a transcript recorded
+// in one temporary directory to be replayed in another.
+const transcriptRoot = "$ROOT"
+
+// Transcript is a record of the commands run by a Repo and their results. A
+// transcript produced by a RecordingRunner can be saved to a golden file and
+// later served back by a ReplayRunner to test Repo behavior without the VCS
+// binaries or network access.
+type Transcript struct {
+	Entries []TranscriptEntry `json:"entries"`
+}
+
+// TranscriptEntry is a single command in a Transcript.
+type TranscriptEntry struct {
+	Name     string   `json:"name"`
+	Args     []string `json:"args,omitempty"`
+	Dir      string   `json:"dir,omitempty"`
+	Stdout   string   `json:"stdout,omitempty"`
+	Stderr   string   `json:"stderr,omitempty"`
+	ExitCode int      `json:"exit_code,omitempty"`
+}
+
+// ReadTranscript reads a transcript saved with Transcript.WriteFile.
+func ReadTranscript(name string) (*Transcript, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	t := &Transcript{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// WriteFile saves the transcript as indented JSON so that changes to golden
+// files are easy to review.
+func (t *Transcript) WriteFile(name string) error {
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(b, '\n'), 0644)
+}
+
+// ExitError is returned by a ReplayRunner for a command that was recorded as
+// exiting with a non-zero status.
+type ExitError struct {
+	Code int
+}
+
+// Error implements the Error interface
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the recorded exit status.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// RecordingRunner is a Runner that passes commands through to another Runner
+// and records each command along with its output and exit status.
+type RecordingRunner struct {
+	runner Runner
+	root   string
+
+	mu         sync.Mutex
+	transcript Transcript
+}
+
+// NewRecordingRunner returns a RecordingRunner that runs commands using r.
+// Every occurrence of root in the recorded arguments, directories, and output
+// is replaced with a placeholder that a ReplayRunner substitutes back. Pass
+// the temporary directory holding the repos so transcripts are portable. An
+// empty root disables the substitution.
+func NewRecordingRunner(r Runner, root string) *RecordingRunner {
+	return &RecordingRunner{runner: r, root: root}
+}
+
+// LookPath passes the lookup through to the wrapped Runner.
+func (r *RecordingRunner) LookPath(file string) (string, error) {
+	return r.runner.LookPath(file)
+}
+
+// Run executes the command using the wrapped Runner and records it.
+func (r *RecordingRunner) Run(ctx context.Context, cmd *Command) error {
+	var stdout, stderr bytes.Buffer
+	c := *cmd
+	c.Stdout = teeWriter(cmd.Stdout, &stdout)
+	if cmd.Stdout != nil && cmd.Stdout == cmd.Stderr {
+		// Keep combined output combined, and in order, by recording it all
+		// as stdout.
+		c.Stderr = c.Stdout
+	} else {
+		c.Stderr = teeWriter(cmd.Stderr, &stderr)
+	}
+
+	err := r.runner.Run(ctx, &c)
+
+	e := TranscriptEntry{
+		Name:   cmd.Name,
+		Args:   make([]string, len(cmd.Args)),
+		Dir:    r.strip(cmd.Dir),
+		Stdout: r.strip(stdout.String()),
+		Stderr: r.strip(stderr.String()),
+	}
+	for i, a := range cmd.Args {
+		e.Args[i] = r.strip(a)
+	}
+	if err != nil {
+		e.ExitCode = exitCode(err)
+	}
+
+	r.mu.Lock()
+	r.transcript.Entries = append(r.transcript.Entries, e)
+	r.mu.Unlock()
+
+	return err
+}
+
+// Transcript returns a copy of everything recorded so far.
+func (r *RecordingRunner) Transcript() *Transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Transcript{Entries: slices.Clone(r.transcript.Entries)}
+}
+
+func (r *RecordingRunner) strip(s string) string {
+	if r.root == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, r.root, transcriptRoot)
+}
+
+func teeWriter(w, buf io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(w, buf)
+}
+
+// ReplayRunner is a Runner that serves the results of recorded commands
+// instead of executing anything.
+//
+// A command is answered by the first entry not yet used that has the same
+// name, arguments, and directory. This allows the same command to produce
+// different results over the life of a test, such as `git show-ref` before and
+// after an update. Commands without a matching entry fail.
+type ReplayRunner struct {
+	root string
+
+	mu      sync.Mutex
+	entries []TranscriptEntry
+	used    []bool
+}
+
+// NewReplayRunner returns a ReplayRunner serving the entries in t. The
+// placeholder recorded in place of the root directory is replaced with root.
+func NewReplayRunner(t *Transcript, root string) *ReplayRunner {
+	r := &ReplayRunner{
+		root:    root,
+		entries: make([]TranscriptEntry, len(t.Entries)),
+		used:    make([]bool, len(t.Entries)),
+	}
+	for i, e := range t.Entries {
+		e.Args = slices.Clone(e.Args)
+		for j, a := range e.Args {
+			e.Args[j] = r.expand(a)
+		}
+		e.Dir = r.expand(e.Dir)
+		e.Stdout = r.expand(e.Stdout)
+		e.Stderr = r.expand(e.Stderr)
+		r.entries[i] = e
+	}
+	return r
+}
+
+// LookPath reports success for any program that appears in the transcript.
+func (r *ReplayRunner) LookPath(file string) (string, error) {
+	for _, e := range r.entries {
+		if e.Name == file {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("%s: not found in transcript", file)
+}
+
+// Run writes the recorded output of the command and returns an *ExitError if
+// it was recorded as failing.
+func (r *ReplayRunner) Run(ctx context.Context, cmd *Command) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	var e *TranscriptEntry
+	for i := range r.entries {
+		c := &r.entries[i]
+		if !r.used[i] && c.Name == cmd.Name && c.Dir == cmd.Dir && slices.Equal(c.Args, cmd.Args) {
+			r.used[i] = true
+			e = c
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if e == nil {
+		return fmt.Errorf("no recorded result for %q in %q", strings.Join(append([]string{cmd.Name}, cmd.Args...), " "), cmd.Dir)
+	}
+
+	if cmd.Stdout != nil {
+		if _, err := io.WriteString(cmd.Stdout, e.Stdout); err != nil {
+			return err
+		}
+	}
+	if cmd.Stderr != nil {
+		if _, err := io.WriteString(cmd.Stderr, e.Stderr); err != nil {
+			return err
+		}
+	}
+	if e.ExitCode != 0 {
+		return &ExitError{Code: e.ExitCode}
+	}
+	return nil
+}
+
+// Unused returns the entries that have not been replayed. Tests can use it to
+// check that a Repo ran every command it was expected to.
+func (r *ReplayRunner) Unused() []TranscriptEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []TranscriptEntry
+	for i, e := range r.entries {
+		if !r.used[i] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (r *ReplayRunner) expand(s string) string {
+	return strings.ReplaceAll(s, transcriptRoot, r.root)
+}
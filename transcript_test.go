@@ -0,0 +1,278 @@
+package vcs
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Run `go test -run Transcript -update` to re-record the golden transcript,
+// which is recorded from a fixture made with git.
+var updateTranscripts = flag.Bool("update", false, "re-record golden transcripts in testdata")
+
+// transcriptRunner returns a runner for a golden transcript test. When
+// recording, commands are executed and captured to be written at the end of
+// the test, after fixture has created the repository at root/remote.
+// Otherwise the golden file is replayed. The test is skipped when the VCS it
+// is recorded from is not installed or it has not been recorded.
+func transcriptRunner(t *testing.T, name, root string, fixture func(*testing.T, string)) Runner {
+	golden := filepath.Join("testdata", "transcripts", name+".json")
+
+	var r Runner
+	if *updateTranscripts {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is needed to record the transcript", name)
+		}
+		fixture(t, filepath.Join(root, "remote"))
+		rec := NewRecordingRunner(ExecRunner{}, root)
+		t.Cleanup(func() {
+			if t.Failed() {
+				return
+			}
+			if err := rec.Transcript().WriteFile(golden); err != nil {
+				t.Error(err)
+			}
+		})
+		r = rec
+	} else {
+		if _, err := os.Stat(golden); os.IsNotExist(err) {
+			t.Skipf("%s has not been recorded. Record it with -update", golden)
+		}
+		tr, err := ReadTranscript(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = NewReplayRunner(tr, root)
+	}
+
+	useRunner(t, r)
+	return r
+}
+
+// gitFixture creates a small Git repository with fixed authors and dates so
+// the commit ids are stable between recordings.
+func gitFixture(t *testing.T, dir string) {
+	env := append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"HOME="+dir,
+		"GIT_AUTHOR_NAME=Test Author",
+		"GIT_AUTHOR_EMAIL=author@example.com",
+		"GIT_COMMITTER_NAME=Test Committer",
+		"GIT_COMMITTER_EMAIL=committer@example.com",
+	)
+	run := func(date string, args ...string) {
+		c := exec.Command("git", args...)
+		c.Dir = dir
+		c.Env = append(env, "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	run("", "init", "-q", ".")
+	run("", "symbolic-ref", "HEAD", "refs/heads/master")
+	write("README.md", "# Test\n")
+	run("2015-07-29T09:46:39-04:00", "add", "README.md")
+	run("2015-07-29T09:46:39-04:00", "commit", "-q", "-m", "Initial commit")
+	run("2015-07-29T09:46:39-04:00", "tag", "1.0.0")
+	write("README.md", "# Test\n\nMore words.\n")
+	run("2015-07-30T10:00:00-04:00", "commit", "-q", "-a", "-m", "Update README.md\n\nAdd more words.")
+	run("", "branch", "other")
+}
+
+func TestGitTranscript(t *testing.T) {
+	root := t.TempDir()
+	transcriptRunner(t, "git", root, gitFixture)
+
+	const (
+		first  = "93ed63d8a83b182b84b913f6f0b50a2d474adfc7"
+		second = "4f3b762dc392009598a3e739992c98da3f087313"
+	)
+
+	repo, err := NewGitRepo(filepath.Join(root, "remote"), filepath.Join(root, "local"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := repo.Current()
+	if err != nil {
+		t.Error(err)
+	}
+	if v != "master" {
+		t.Errorf("Current failed to detect Git on tip of master. Got version: %s", v)
+	}
+
+	v, err = repo.Version()
+	if err != nil {
+		t.Error(err)
+	}
+	if v != second {
+		t.Errorf("Git Version returned %s", v)
+	}
+
+	tags, err := repo.Tags()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(tags) != 1 || tags[0] != "1.0.0" {
+		t.Errorf("Git Tags returned %v", tags)
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		t.Error(err)
+	}
+	var hasOther bool
+	for _, b := range branches {
+		if b == "other" {
+			hasOther = true
+		}
+	}
+	if !hasOther {
+		t.Errorf("Git Branches did not find other. Got %v", branches)
+	}
+
+	tags, err = repo.TagsFromCommit(first)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(tags) != 1 || tags[0] != "1.0.0" {
+		t.Errorf("Git TagsFromCommit returned %v", tags)
+	}
+
+	ci, err := repo.CommitInfo(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Commit != second {
+		t.Error("Git.CommitInfo wrong commit id")
+	}
+	if ci.Author != "Test Author <author@example.com>" {
+		t.Errorf("Git.CommitInfo wrong author: %s", ci.Author)
+	}
+	if ci.Message != "Update README.md\n\nAdd more words." {
+		t.Errorf("Git.CommitInfo wrong message: %q", ci.Message)
+	}
+	if ci.CommitterName != "Test Committer" || ci.CommitterEmail != "committer@example.com" {
+		t.Errorf("Git.CommitInfo wrong committer: %s <%s>", ci.CommitterName, ci.CommitterEmail)
+	}
+	if len(ci.Parents) != 1 || ci.Parents[0] != first {
+		t.Errorf("Git.CommitInfo wrong parents: %v", ci.Parents)
+	}
+	ti, err := time.Parse(time.RFC3339, "2015-07-30T10:00:00-04:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ti.Equal(ci.Date) || !ti.Equal(ci.CommitDate) {
+		t.Errorf("Git.CommitInfo wrong date: %s", ci.Date)
+	}
+	if _, offset := ci.Date.Zone(); offset != -4*60*60 {
+		t.Errorf("Git.CommitInfo did not keep the time zone: %s", ci.Date)
+	}
+
+	_, err = repo.CommitInfo("asdfasdfasdf")
+	if err != ErrRevisionUnavailable {
+		t.Error("Git didn't return expected ErrRevisionUnavailable")
+	}
+
+	if !repo.IsReference("1.0.0") {
+		t.Error("Git is reporting a reference is not one")
+	}
+	if repo.IsReference("foo") {
+		t.Error("Git is reporting a non-existent reference is one")
+	}
+	if repo.IsDirty() {
+		t.Error("Git incorrectly reporting dirty")
+	}
+
+	if err := repo.UpdateVersion("1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	v, err = repo.Current()
+	if err != nil {
+		t.Error(err)
+	}
+	if v != "1.0.0" {
+		t.Errorf("Current failed to detect Git on a tag. Got version: %s", v)
+	}
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	f := &fakeRunner{responses: map[string]fakeResponse{
+		"git rev-parse HEAD": {out: "4f3b762dc392009598a3e739992c98da3f087313\n"},
+		"git show-ref":       {out: "not a repo\n", code: 128},
+	}}
+	rec := NewRecordingRunner(f, "/tmp/recorded")
+	recRepo := &GitRepo{}
+	recRepo.setLocalPath("/tmp/recorded/local")
+	recRepo.Runner = rec
+
+	if _, err := recRepo.Version(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recRepo.Tags(); err == nil {
+		t.Fatal("expected the recorded failure")
+	}
+
+	name := filepath.Join(t.TempDir(), "transcript.json")
+	if err := rec.Transcript().WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := ReadTranscript(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Entries) != 2 || tr.Entries[0].Dir != "$ROOT/local" || tr.Entries[1].ExitCode != 128 {
+		t.Fatalf("unexpected transcript %+v", tr.Entries)
+	}
+
+	replay := NewReplayRunner(tr, "/tmp/replayed")
+	repo := &GitRepo{}
+	repo.setLocalPath("/tmp/replayed/local")
+	repo.Runner = replay
+
+	v, err := repo.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "4f3b762dc392009598a3e739992c98da3f087313" {
+		t.Errorf("replayed Version returned %s", v)
+	}
+	_, err = repo.Tags()
+	if exitCode(err) != 128 {
+		t.Errorf("replayed failure lost its exit code: %v", err)
+	}
+	if len(replay.Unused()) != 0 {
+		t.Errorf("entries were not replayed: %+v", replay.Unused())
+	}
+
+	// Every entry has been used so running the command again fails.
+	if _, err := repo.Version(); err == nil {
+		t.Error("expected an error for a command without a recorded result")
+	}
+}
+
+// writeFixtureFile writes a file of a fixture, creating dir when needed.
+func writeFixtureFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
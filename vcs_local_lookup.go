@@ -2,6 +2,7 @@ package vcs
 
 import (
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
@@ -23,24 +24,47 @@ func DetectVcsFromFS(vcsPath string) (Type, error) {
 		return "", ErrCannotDetectVCS
 	}
 
-	separator := string(os.PathSeparator)
-
-	// Walk through each of the different VCS types to see if
-	// one can be detected. Do this is order of guessed popularity.
-	if _, err := os.Stat(vcsPath + separator + ".git"); err == nil {
-		return Git, nil
-	}
-	if _, err := os.Stat(vcsPath + separator + ".svn"); err == nil {
-		return Svn, nil
-	}
-	if _, err := os.Stat(vcsPath + separator + ".hg"); err == nil {
-		return Hg, nil
-	}
-	if _, err := os.Stat(vcsPath + separator + ".bzr"); err == nil {
-		return Bzr, nil
+	for _, b := range registeredBackends() {
+		if b.detect(vcsPath) {
+			return b.typ, nil
+		}
 	}
 
 	// If one was not already detected than we default to not finding it.
 	return "", ErrCannotDetectVCS
+}
 
+// findRoot walks up from path to find the root of the working copy containing
+// it and the VCS of the working copy.
+func findRoot(path string) (string, Type, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		if t, err := DetectVcsFromFS(dir); err == nil {
+			// Working copies created by Svn before 1.7 have a .svn directory
+			// in every directory. The root is the highest of them.
+			if t == Svn {
+				for {
+					parent := filepath.Dir(dir)
+					if parent == dir {
+						break
+					}
+					if _, err := os.Stat(filepath.Join(parent, ".svn")); err != nil {
+						break
+					}
+					dir = parent
+				}
+			}
+			return dir, t, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", ErrCannotDetectVCS
+		}
+		dir = parent
+	}
 }
@@ -1,6 +1,11 @@
 package vcs
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -49,7 +54,7 @@ func TestVCSLookup(t *testing.T) {
 	}
 
 	for u, c := range urlList {
-		ty, _, err := detectVcsFromRemote(u)
+		ty, _, err := detectVcsFromRemote(context.Background(), nil, u)
 		if err == nil && !c.work {
 			t.Errorf("Error detecting VCS from URL(%s)", u)
 		}
@@ -92,7 +97,7 @@ func TestVCSFileLookup(t *testing.T) {
 	} else {
 		pth = "file://" + tempDir
 	}
-	ty, _, err := detectVcsFromRemote(pth)
+	ty, _, err := detectVcsFromRemote(context.Background(), nil, pth)
 
 	if err != nil {
 		t.Errorf("Unable to detect file:// path: %s", err)
@@ -102,3 +107,98 @@ func TestVCSFileLookup(t *testing.T) {
 		t.Errorf("Detected wrong type from file:// path. Found type %v", ty)
 	}
 }
+
+func TestDetectRepoRoot(t *testing.T) {
+	tests := []struct {
+		url  string
+		t    Type
+		root string
+	}{
+		{"https://github.com/Masterminds/vcs/vcstest", Git, "github.com/Masterminds/vcs"},
+		{"https://gitlab.com/group/subgroup/repo.git", Git, "gitlab.com/group/subgroup/repo.git"},
+		{"https://gitlab.com/group/subgroup/repo.git/sub/pkg", Git, "gitlab.com/group/subgroup/repo.git"},
+		{"https://gitlab.com/group/subgroup/deeper/repo/-/tree/main/pkg", Git, "gitlab.com/group/subgroup/deeper/repo"},
+		{"git@gitlab.com:group/subgroup/repo.git", Git, "gitlab.com/group/subgroup/repo.git"},
+		{"https://codeberg.org/forgejo/forgejo/modules/git", Git, "codeberg.org/forgejo/forgejo"},
+		{"https://gitea.com/gitea/tea/cmd", Git, "gitea.com/gitea/tea"},
+		{"https://git.sr.ht/~sircmpwn/hare/sort", Git, "git.sr.ht/~sircmpwn/hare"},
+		{"https://hg.sr.ht/~user/project/sub", Hg, "hg.sr.ht/~user/project"},
+		{"https://dev.azure.com/org/project/_git/repo", Git, "dev.azure.com/org/project/_git/repo"},
+		{"https://dev.azure.com/org/project/_git/repo/sub/pkg", Git, "dev.azure.com/org/project/_git/repo"},
+		{"https://org@dev.azure.com/org/_git/repo", Git, "dev.azure.com/org/_git/repo"},
+		{"git@ssh.dev.azure.com:v3/org/project/repo", Git, "ssh.dev.azure.com/v3/org/project/repo"},
+		{"https://org.visualstudio.com/DefaultCollection/project/_git/repo/pkg", Git, "org.visualstudio.com/DefaultCollection/project/_git/repo"},
+		{"https://git-codecommit.us-east-2.amazonaws.com/v1/repos/MyRepo", Git, "git-codecommit.us-east-2.amazonaws.com/v1/repos/MyRepo"},
+		{"https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/MyRepo/sub/pkg", Git, "git-codecommit.eu-west-1.amazonaws.com/v1/repos/MyRepo"},
+		{"https://bitbucket.example.com/scm/proj/repo.git", Git, "bitbucket.example.com/scm/proj/repo.git"},
+		{"https://example.com/bitbucket/scm/~user/repo/sub/pkg", Git, "example.com/bitbucket/scm/~user/repo"},
+		{"ssh://git@bitbucket.example.com:7999/scm/proj/repo.git", Git, "bitbucket.example.com:7999/scm/proj/repo.git"},
+		{"https://example.com/foo/bar.hg", Hg, "example.com/foo/bar.hg"},
+		{"https://gopkg.in/yaml.v3", Git, "gopkg.in/yaml.v3"},
+		{"https://gopkg.in/check.v1/sub", Git, "gopkg.in/check.v1"},
+		{"https://gopkg.in/user/pkg.v2-unstable", Git, "gopkg.in/user/pkg.v2-unstable"},
+	}
+
+	for _, tc := range tests {
+		ty, root, err := detectRepoRoot(tc.url)
+		if err != nil {
+			t.Errorf("%s: %s", tc.url, err)
+			continue
+		}
+		if ty != tc.t || root != tc.root {
+			t.Errorf("%s: expected %s %s, got %s %s", tc.url, tc.t, tc.root, ty, root)
+		}
+	}
+
+	for _, u := range []string{
+		"https://gitlab.com/group/repo",
+		"https://gitlab.com/group/subgroup/repo/sub/pkg",
+		"https://scm.example.com/scm/hg/repo",
+		"https://scm.example.com/scm/svn/repo",
+		"https://scm.example.com/scm/repo/namespace/repo",
+		"https://codeberg.org/forgejo",
+		"https://git.example.com/owner/repo/src/branch/main/pkg",
+		"https://example.com/docs/guide/src/tag/v1.0.0",
+		"https://git.sr.ht/sircmpwn/hare",
+		"https://dev.azure.com/org/project",
+		"https://git-codecommit.us-east-2.amazonaws.com/v1/repos",
+	} {
+		if _, _, err := detectRepoRoot(u); err != ErrCannotDetectVCS {
+			t.Errorf("%s: expected ErrCannotDetectVCS, got %v", u, err)
+		}
+	}
+}
+
+func TestDetectVcsFromRemoteClient(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s/private hg https://hg.example.com/private"></head></html>`, r.Host)
+	}))
+	defer srv.Close()
+
+	base := srv.Client().Transport
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer secret")
+		return base.RoundTrip(r)
+	})}
+
+	ty, remote, err := detectVcsFromRemote(context.Background(), client, srv.URL+"/private/pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ty != Hg || remote != "https://hg.example.com/private" {
+		t.Errorf("unexpected result %s %s", ty, remote)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("the request was not made with the client. Authorization was %q", auth)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewRepoContext(ctx, srv.URL+"/private", t.TempDir(), WithHTTPClient(client))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
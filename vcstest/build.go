@@ -0,0 +1,429 @@
+package vcstest
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/vcs"
+)
+
+// DefaultAuthor is used for commits in a Spec without an author.
+const DefaultAuthor = "Test Author <author@example.com>"
+
+// DefaultDate is used for the first commit in a Spec without a date. Each
+// following commit without a date is an hour after the one before it.
+var DefaultDate = time.Date(2015, 7, 29, 9, 46, 39, 0, time.FixedZone("", -4*60*60))
+
+// Spec declares the history of a repository for Build to create.
+type Spec struct {
+	// Commits are created in order. Each commit is made on top of the commit
+	// before it unless it is on a branch that already exists.
+	Commits []Commit
+}
+
+// Commit declares a single commit in a Spec.
+type Commit struct {
+	// Branch is the branch to commit to. An empty branch means the default
+	// branch of the VCS (master for Git, default for Hg, and trunk for Svn).
+	// A branch that does not exist yet is created from the previous commit.
+	// Bzr only supports the default branch.
+	Branch string
+
+	// Author is in the form "Name <email>". Svn only records the name.
+	// DefaultAuthor is used when it is empty.
+	Author string
+
+	// Date is when the commit was made. See DefaultDate for when it is zero.
+	Date time.Time
+
+	// CommitDate is when a Git commit was committed, such as when it was
+	// rebased after Date. It defaults to Date. The other VCS record a single
+	// date.
+	CommitDate time.Time
+
+	// Message is the commit message. It defaults to "Commit N" where N counts
+	// from 1.
+	Message string
+
+	// Files maps a slash separated path, relative to the root of the repo, to
+	// the content written to it. Hg and Svn cannot record a commit without
+	// changes so each commit should change at least one file.
+	Files map[string]string
+
+	// Submodules maps a slash separated path to the remote of another repo,
+	// such as the Remote of a Built, to include at that path. They are Git
+	// submodules, Hg subrepos, and Svn externals. Bzr does not support them.
+	Submodules map[string]string
+
+	// Tags are created on this commit. With Hg each tag is itself a commit
+	// that becomes the parent of the next commit.
+	Tags []string
+}
+
+// Built describes a repository created by Build.
+type Built struct {
+	// Type is the VCS of the repository.
+	Type vcs.Type
+
+	// Remote is a file:// URL for the repository that can be passed to
+	// vcs.NewRepo or the constructor for the type. For Svn it is the trunk.
+	Remote string
+
+	// Root is the file:// URL for the root of the repository. It is the same
+	// as Remote except for Svn, where branches and tags are below it.
+	Root string
+
+	// Dir is the location of the repository on disk.
+	Dir string
+
+	// Revisions holds the revision, as reported by Version, of each commit in
+	// the Spec in order.
+	Revisions []string
+}
+
+// Build creates the repository declared by s in a temporary directory that is
+// removed when the test ends. The test is skipped when the tools for the VCS
+// are not installed.
+//
+// Git requires protocol.file.allow=always in its configuration to check out
+// submodules over file:// URLs.
+func Build(t testing.TB, typ vcs.Type, s Spec) *Built {
+	t.Helper()
+
+	var b builder
+	switch typ {
+	case vcs.Git:
+		b = &gitBuilder{}
+	case vcs.Hg:
+		b = &hgBuilder{}
+	case vcs.Svn:
+		b = &svnBuilder{}
+	case vcs.Bzr:
+		b = &bzrBuilder{}
+	default:
+		t.Fatalf("unable to build a %q repository", typ)
+	}
+
+	built := &Built{Type: typ}
+	work := b.init(t, built, filepath.Join(t.TempDir(), string(typ)))
+
+	date := DefaultDate
+	branches := map[string]bool{"": true}
+	current := ""
+	for i, c := range s.Commits {
+		if c.Author == "" {
+			c.Author = DefaultAuthor
+		}
+		if c.Date.IsZero() {
+			c.Date = date
+		}
+		if c.CommitDate.IsZero() {
+			c.CommitDate = c.Date
+		}
+		date = c.Date.Add(time.Hour)
+		if c.Message == "" {
+			c.Message = fmt.Sprintf("Commit %d", i+1)
+		}
+
+		if c.Branch != current {
+			b.branch(t, c, !branches[c.Branch])
+			branches[c.Branch] = true
+			current = c.Branch
+		}
+
+		for _, name := range sortedKeys(c.Files) {
+			writeFile(t, work, name, c.Files[name])
+		}
+		if len(c.Submodules) > 0 {
+			b.submodules(t, c)
+		}
+		built.Revisions = append(built.Revisions, b.commit(t, c))
+		for _, tag := range c.Tags {
+			b.tag(t, c, tag)
+		}
+	}
+	if current != "" {
+		b.branch(t, Commit{Author: DefaultAuthor}, false)
+	}
+
+	return built
+}
+
+// builder creates a repository for a particular VCS.
+type builder interface {
+	// init creates an empty repository at dir, sets the locations on b, and
+	// returns the working directory files are written to.
+	init(t testing.TB, b *Built, dir string) string
+
+	// branch switches to the branch for c, creating it from the current
+	// commit when create is true.
+	branch(t testing.TB, c Commit, create bool)
+
+	// submodules adds the submodules of c.
+	submodules(t testing.TB, c Commit)
+
+	// commit records the files written for c and returns the new revision.
+	commit(t testing.TB, c Commit) string
+
+	// tag tags the last commit, c.
+	tag(t testing.TB, c Commit, name string)
+}
+
+type gitBuilder struct {
+	dir string
+}
+
+func (g *gitBuilder) init(t testing.TB, b *Built, dir string) string {
+	requireTool(t, "git")
+	g.dir = dir
+	b.Dir, b.Remote, b.Root = dir, fileURL(dir), fileURL(dir)
+	mkdir(t, dir)
+	g.git(t, nil, "init", "-q", ".")
+	g.git(t, nil, "symbolic-ref", "HEAD", "refs/heads/master")
+	return dir
+}
+
+func (g *gitBuilder) branch(t testing.TB, c Commit, create bool) {
+	branch := c.Branch
+	if branch == "" {
+		branch = "master"
+	}
+	if create {
+		g.git(t, nil, "checkout", "-q", "-b", branch)
+	} else {
+		g.git(t, nil, "checkout", "-q", branch)
+	}
+}
+
+func (g *gitBuilder) submodules(t testing.TB, c Commit) {
+	for _, p := range sortedKeys(c.Submodules) {
+		g.git(t, nil, "-c", "protocol.file.allow=always", "submodule", "add", "-q", c.Submodules[p], p)
+	}
+}
+
+func (g *gitBuilder) commit(t testing.TB, c Commit) string {
+	g.git(t, nil, "add", "-A")
+	g.git(t, g.env(t, c), "commit", "-q", "--allow-empty", "-m", c.Message)
+	return strings.TrimSpace(g.git(t, nil, "rev-parse", "HEAD"))
+}
+
+func (g *gitBuilder) tag(t testing.TB, c Commit, name string) {
+	g.git(t, g.env(t, c), "tag", name)
+}
+
+func (g *gitBuilder) env(t testing.TB, c Commit) []string {
+	name, email := splitAuthor(t, c.Author)
+	return []string{
+		"GIT_AUTHOR_NAME=" + name,
+		"GIT_AUTHOR_EMAIL=" + email,
+		"GIT_AUTHOR_DATE=" + c.Date.Format(time.RFC3339),
+		"GIT_COMMITTER_NAME=" + name,
+		"GIT_COMMITTER_EMAIL=" + email,
+		"GIT_COMMITTER_DATE=" + c.CommitDate.Format(time.RFC3339),
+	}
+}
+
+func (g *gitBuilder) git(t testing.TB, env []string, args ...string) string {
+	t.Helper()
+	return run(t, g.dir, append([]string{"GIT_CONFIG_NOSYSTEM=1", "HOME=" + g.dir}, env...), "git", args...)
+}
+
+type hgBuilder struct {
+	dir string
+}
+
+func (h *hgBuilder) init(t testing.TB, b *Built, dir string) string {
+	requireTool(t, "hg")
+	h.dir = dir
+	b.Dir, b.Remote, b.Root = dir, fileURL(dir), fileURL(dir)
+	mkdir(t, dir)
+	h.hg(t, "init")
+	return dir
+}
+
+func (h *hgBuilder) branch(t testing.TB, c Commit, create bool) {
+	branch := c.Branch
+	if branch == "" {
+		branch = "default"
+	}
+	if create {
+		h.hg(t, "branch", "-q", branch)
+	} else {
+		h.hg(t, "update", "-q", branch)
+	}
+}
+
+func (h *hgBuilder) submodules(t testing.TB, c Commit) {
+	hgsub := ""
+	if b, err := os.ReadFile(filepath.Join(h.dir, ".hgsub")); err == nil {
+		hgsub = string(b)
+	}
+	for _, p := range sortedKeys(c.Submodules) {
+		h.hg(t, "clone", "-q", c.Submodules[p], filepath.FromSlash(p))
+		hgsub += p + " = " + c.Submodules[p] + "\n"
+	}
+	writeFile(t, h.dir, ".hgsub", hgsub)
+}
+
+func (h *hgBuilder) commit(t testing.TB, c Commit) string {
+	h.hg(t, "add", "-q")
+	h.hg(t, "commit", "-u", c.Author, "-d", c.Date.Format(plainDateFormat), "-m", c.Message)
+	return strings.TrimSpace(h.hg(t, "log", "-r", ".", "--template", "{node}"))
+}
+
+func (h *hgBuilder) tag(t testing.TB, c Commit, name string) {
+	h.hg(t, "tag", "-u", c.Author, "-d", c.Date.Format(plainDateFormat), name)
+}
+
+func (h *hgBuilder) hg(t testing.TB, args ...string) string {
+	t.Helper()
+	return run(t, h.dir, []string{"HGPLAIN=1", "HGRCPATH="}, "hg", args...)
+}
+
+type svnBuilder struct {
+	repo, wc, root string
+
+	// path is the location of the current branch relative to root.
+	path string
+
+	// externals holds the svn:externals definitions for each branch path.
+	externals map[string][]string
+}
+
+func (s *svnBuilder) init(t testing.TB, b *Built, dir string) string {
+	requireTool(t, "svn")
+	requireTool(t, "svnadmin")
+	requireTool(t, "svnlook")
+	s.repo = dir + "repo"
+	s.wc = dir
+	s.root = fileURL(s.repo)
+	s.path = "trunk"
+	s.externals = map[string][]string{}
+	b.Dir, b.Remote, b.Root = s.repo, s.root+"/trunk", s.root
+
+	run(t, filepath.Dir(dir), nil, "svnadmin", "create", s.repo)
+	s.svn(t, DefaultAuthor, "mkdir", "-q", "-m", "Create layout", s.root+"/trunk", s.root+"/branches", s.root+"/tags")
+	s.setDate(t, DefaultDate)
+	s.svn(t, DefaultAuthor, "checkout", "-q", s.root+"/trunk", s.wc)
+	return s.wc
+}
+
+func (s *svnBuilder) branch(t testing.TB, c Commit, create bool) {
+	path := "trunk"
+	if c.Branch != "" {
+		path = "branches/" + c.Branch
+	}
+	if create {
+		s.svn(t, c.Author, "copy", "-q", "-m", "Create branch "+c.Branch, s.root+"/"+s.path, s.root+"/"+path)
+		s.setDate(t, c.Date)
+		s.externals[path] = s.externals[s.path]
+	}
+	s.svn(t, c.Author, "switch", "-q", s.root+"/"+path, s.wc)
+	s.path = path
+}
+
+func (s *svnBuilder) submodules(t testing.TB, c Commit) {
+	ext := slices.Clone(s.externals[s.path])
+	for _, p := range sortedKeys(c.Submodules) {
+		ext = append(ext, c.Submodules[p]+" "+p)
+	}
+	s.externals[s.path] = ext
+	s.svn(t, c.Author, "propset", "-q", "svn:externals", strings.Join(ext, "\n"), s.wc)
+}
+
+func (s *svnBuilder) commit(t testing.TB, c Commit) string {
+	s.svn(t, c.Author, "add", "-q", "--force", s.wc)
+	s.svn(t, c.Author, "commit", "-q", "-m", c.Message, s.wc)
+	rev := s.setDate(t, c.Date)
+	// Bring the working copy to the new revision so it is not mixed.
+	s.svn(t, c.Author, "update", "-q", s.wc)
+	return rev
+}
+
+func (s *svnBuilder) tag(t testing.TB, c Commit, name string) {
+	s.svn(t, c.Author, "copy", "-q", "-m", "Tag "+name, s.root+"/"+s.path, s.root+"/tags/"+name)
+	s.setDate(t, c.Date)
+}
+
+// setDate sets the date of the youngest revision, which svn otherwise always
+// records as the current time, and returns the revision.
+func (s *svnBuilder) setDate(t testing.TB, d time.Time) string {
+	rev := strings.TrimSpace(run(t, s.repo, nil, "svnlook", "youngest", s.repo))
+	f := filepath.Join(filepath.Dir(s.repo), "svn-date")
+	if err := os.WriteFile(f, []byte(d.UTC().Format("2006-01-02T15:04:05.000000Z")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, s.repo, nil, "svnadmin", "setrevprop", s.repo, "-r", rev, "svn:date", f)
+	return rev
+}
+
+func (s *svnBuilder) svn(t testing.TB, author string, args ...string) string {
+	t.Helper()
+	name, _ := splitAuthor(t, author)
+	return run(t, filepath.Dir(s.wc), nil, "svn", append([]string{"--non-interactive", "--username", name}, args...)...)
+}
+
+type bzrBuilder struct {
+	dir string
+}
+
+func (z *bzrBuilder) init(t testing.TB, b *Built, dir string) string {
+	requireTool(t, "bzr")
+	z.dir = dir
+	b.Dir, b.Remote, b.Root = dir, fileURL(dir), fileURL(dir)
+	mkdir(t, dir)
+	z.bzr(t, DefaultAuthor, "init", "-q")
+	return dir
+}
+
+func (z *bzrBuilder) branch(t testing.TB, c Commit, create bool) {
+	if c.Branch != "" {
+		t.Fatalf("bzr does not support branches within a repository: %q", c.Branch)
+	}
+}
+
+func (z *bzrBuilder) submodules(t testing.TB, c Commit) {
+	t.Fatal("bzr does not support submodules")
+}
+
+func (z *bzrBuilder) commit(t testing.TB, c Commit) string {
+	z.bzr(t, c.Author, "add", "-q")
+	z.bzr(t, c.Author, "commit", "-q", "--unchanged", "--commit-time", c.Date.Format(plainDateFormat), "-m", c.Message)
+	return strings.TrimSpace(z.bzr(t, c.Author, "revno"))
+}
+
+func (z *bzrBuilder) tag(t testing.TB, c Commit, name string) {
+	z.bzr(t, c.Author, "tag", "-q", name)
+}
+
+func (z *bzrBuilder) bzr(t testing.TB, author string, args ...string) string {
+	t.Helper()
+	return run(t, z.dir, []string{"BZR_EMAIL=" + author, "BZR_HOME=" + z.dir}, "bzr", args...)
+}
+
+// plainDateFormat is accepted by hg commit -d and bzr commit --commit-time.
+const plainDateFormat = "2006-01-02 15:04:05 -0700"
+
+func splitAuthor(t testing.TB, author string) (name, email string) {
+	a, err := mail.ParseAddress(author)
+	if err != nil {
+		t.Fatalf("invalid author %q: %s", author, err)
+	}
+	return a.Name, a.Address
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
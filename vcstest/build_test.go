@@ -0,0 +1,89 @@
+package vcstest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/vcs"
+)
+
+func TestBuildGit(t *testing.T) {
+	lib := Build(t, vcs.Git, Spec{Commits: []Commit{
+		{Files: map[string]string{"lib.go": "package lib\n"}},
+	}})
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := Build(t, vcs.Git, Spec{Commits: []Commit{
+		{Author: "Jane Doe <jane@example.com>", Date: date, Message: "First", Files: map[string]string{"a.txt": "a\n"}, Tags: []string{"v1.0.0"}},
+		{Branch: "feature", Files: map[string]string{"dir/b.txt": "b\n"}},
+		{Submodules: map[string]string{"lib": lib.Remote}, Files: map[string]string{"a.txt": "aa\n"}},
+	}})
+	if len(b.Revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %v", b.Revisions)
+	}
+
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	local := filepath.Join(t.TempDir(), "checkout")
+	repo, err := vcs.NewRepo(b.Remote, local)
+	if err != nil {
+		t.Fatalf("NewRepo did not detect the built repo: %s", err)
+	}
+	if repo.Vcs() != vcs.Git {
+		t.Errorf("NewRepo detected %q", repo.Vcs())
+	}
+	if err := repo.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := repo.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != b.Revisions[2] {
+		t.Errorf("the default branch is not checked out. Got %q", v)
+	}
+	if _, err := os.Stat(filepath.Join(local, "lib", "lib.go")); err != nil {
+		t.Errorf("submodule not checked out: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(local, "dir", "b.txt")); err == nil {
+		t.Error("the commit on feature was made on the default branch")
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(branches, "feature") {
+		t.Errorf("branch feature not created. Got %v", branches)
+	}
+
+	tags, err := repo.TagsFromCommit(b.Revisions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tags, []string{"v1.0.0"}) {
+		t.Errorf("tag not created on the first commit. Got %v", tags)
+	}
+
+	ci, err := repo.CommitInfo(b.Revisions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Author != "Jane Doe <jane@example.com>" || ci.Message != "First" || !ci.Date.Equal(date) {
+		t.Errorf("commit not made as declared: %+v", ci)
+	}
+
+	ci, err = repo.CommitInfo(b.Revisions[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Author != DefaultAuthor || ci.Message != "Commit 2" || !ci.Date.Equal(date.Add(time.Hour)) {
+		t.Errorf("defaults not used for an empty commit: %+v", ci)
+	}
+}
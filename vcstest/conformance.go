@@ -0,0 +1,332 @@
+// Package vcstest provides helpers for testing implementations of the
+// vcs.Repo interface.
+//
+// RunConformance exercises the full Repo contract against a repository created
+// on the local disk. It is used to test the Git, Svn, Hg, and Bzr repos
+// provided by the vcs package and can be used by other implementations to
+// prove they behave the same way. For example,
+//
+//	func TestConformance(t *testing.T) {
+//		vcstest.RunConformance(t, vcstest.GitFactory())
+//	}
+//
+// Build creates throwaway repositories on the local disk from a declarative
+// Spec so that tests do not need network access to a hosted repository.
+package vcstest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Masterminds/vcs"
+)
+
+// Factory describes a Repo implementation to the conformance suite.
+type Factory struct {
+	// Type is the VCS type the implementation reports from Vcs.
+	Type vcs.Type
+
+	// New creates a Repo for the remote and local locations. It has the same
+	// semantics as vcs.NewRepo.
+	New func(remote, local string) (vcs.Repo, error)
+
+	// Fixture creates a repository for the suite to work with and describes
+	// it. It should skip the test when the VCS is unavailable.
+	Fixture func(t testing.TB) *Fixture
+}
+
+// Fixture describes a repository created for the conformance suite.
+type Fixture struct {
+	// Remote is the location of the repository passed to Factory.New.
+	Remote string
+
+	// Head is the revision, as reported by Version, checked out by Get.
+	Head string
+
+	// Tip is the value reported by Current when Head is checked out. It must
+	// also be a version that can be passed to UpdateVersion to return to Head.
+	// For example, the branch name for Git or HEAD for Svn.
+	Tip string
+
+	// Older is a revision committed before Head.
+	Older string
+
+	// OlderTag is a tag on Older. It is empty when the VCS has no tags.
+	OlderTag string
+
+	// OlderAuthor and OlderMessage are expected from CommitInfo for Older.
+	OlderAuthor  string
+	OlderMessage string
+
+	// Missing is a revision in the format of the VCS that does not exist.
+	Missing string
+
+	// Branches lists branches that Branches must report. It is empty when
+	// the VCS has no branches.
+	Branches []string
+
+	// Files lists files, relative to the root of the repo, present at Head.
+	// The first file is modified to test IsDirty.
+	Files []string
+}
+
+// RunConformance runs the conformance suite for the Repo implementation
+// described by f. The subtests build on each other so a failure early on will
+// often cause later failures.
+func RunConformance(t *testing.T, f Factory) {
+	t.Helper()
+
+	fx := f.Fixture(t)
+	local := filepath.Join(t.TempDir(), "checkout")
+
+	repo, err := f.New(fx.Remote, local)
+	if err != nil {
+		t.Fatalf("unable to create repo: %s", err)
+	}
+
+	t.Run("Getters", func(t *testing.T) {
+		if repo.Vcs() != f.Type {
+			t.Errorf("Vcs returned %q instead of %q", repo.Vcs(), f.Type)
+		}
+		if repo.Remote() != fx.Remote {
+			t.Errorf("Remote returned %q instead of %q", repo.Remote(), fx.Remote)
+		}
+		if repo.LocalPath() != local {
+			t.Errorf("LocalPath returned %q instead of %q", repo.LocalPath(), local)
+		}
+		if repo.CheckLocal() {
+			t.Error("CheckLocal reported a repo before Get")
+		}
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		if !repo.Ping() {
+			t.Error("Ping reported the remote is not accessible")
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		if err := repo.Get(); err != nil {
+			t.Fatalf("Get failed: %s", err)
+		}
+		if !repo.CheckLocal() {
+			t.Error("CheckLocal did not report a repo after Get")
+		}
+		lt, err := vcs.DetectVcsFromFS(local)
+		if err == nil && lt != f.Type {
+			t.Errorf("DetectVcsFromFS detected %q instead of %q", lt, f.Type)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		r, err := f.New(fx.Remote, local)
+		if err != nil {
+			t.Fatalf("unable to create repo for an existing checkout: %s", err)
+		}
+		if !r.CheckLocal() {
+			t.Error("CheckLocal did not report the existing checkout")
+		}
+	})
+
+	t.Run("Version", func(t *testing.T) {
+		checkVersion(t, repo, fx.Head, fx.Tip)
+	})
+
+	t.Run("Date", func(t *testing.T) {
+		d, err := repo.Date()
+		if err != nil {
+			t.Fatalf("Date failed: %s", err)
+		}
+		if d.IsZero() {
+			t.Error("Date returned the zero time")
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		if err := repo.Update(); err != nil {
+			t.Fatalf("Update failed: %s", err)
+		}
+		checkVersion(t, repo, fx.Head, fx.Tip)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		tags, err := repo.Tags()
+		if err != nil {
+			t.Fatalf("Tags failed: %s", err)
+		}
+		if fx.OlderTag != "" && !slices.Contains(tags, fx.OlderTag) {
+			t.Errorf("Tags did not include %q. Got %v", fx.OlderTag, tags)
+		}
+		if fx.OlderTag == "" && len(tags) != 0 {
+			t.Errorf("Tags returned tags for a VCS without them: %v", tags)
+		}
+
+		tags, err = repo.TagsFromCommit(fx.Older)
+		if err != nil {
+			t.Fatalf("TagsFromCommit failed: %s", err)
+		}
+		if fx.OlderTag != "" && !slices.Contains(tags, fx.OlderTag) {
+			t.Errorf("TagsFromCommit did not include %q. Got %v", fx.OlderTag, tags)
+		}
+	})
+
+	t.Run("Branches", func(t *testing.T) {
+		branches, err := repo.Branches()
+		if err != nil {
+			t.Fatalf("Branches failed: %s", err)
+		}
+		for _, b := range fx.Branches {
+			if !slices.Contains(branches, b) {
+				t.Errorf("Branches did not include %q. Got %v", b, branches)
+			}
+		}
+	})
+
+	t.Run("IsReference", func(t *testing.T) {
+		for _, r := range []string{fx.Head, fx.Older, fx.OlderTag} {
+			if r != "" && !repo.IsReference(r) {
+				t.Errorf("IsReference reported %q is not a reference", r)
+			}
+		}
+		if repo.IsReference(fx.Missing) {
+			t.Errorf("IsReference reported %q is a reference", fx.Missing)
+		}
+	})
+
+	t.Run("CommitInfo", func(t *testing.T) {
+		ci, err := repo.CommitInfo(fx.Older)
+		if err != nil {
+			t.Fatalf("CommitInfo failed: %s", err)
+		}
+		if ci.Commit != fx.Older {
+			t.Errorf("CommitInfo returned commit %q instead of %q", ci.Commit, fx.Older)
+		}
+		if ci.Author != fx.OlderAuthor {
+			t.Errorf("CommitInfo returned author %q instead of %q", ci.Author, fx.OlderAuthor)
+		}
+		if ci.Message != fx.OlderMessage {
+			t.Errorf("CommitInfo returned message %q instead of %q", ci.Message, fx.OlderMessage)
+		}
+		if ci.Date.IsZero() {
+			t.Error("CommitInfo returned the zero time")
+		}
+
+		_, err = repo.CommitInfo(fx.Missing)
+		if err != vcs.ErrRevisionUnavailable {
+			t.Errorf("CommitInfo for a missing revision returned %v instead of ErrRevisionUnavailable", err)
+		}
+	})
+
+	t.Run("UpdateVersion", func(t *testing.T) {
+		if err := repo.UpdateVersion(fx.Older); err != nil {
+			t.Fatalf("UpdateVersion failed: %s", err)
+		}
+		v, err := repo.Version()
+		if err != nil {
+			t.Fatalf("Version failed: %s", err)
+		}
+		if v != fx.Older {
+			t.Errorf("Version returned %q instead of %q", v, fx.Older)
+		}
+		c, err := repo.Current()
+		if err != nil {
+			t.Fatalf("Current failed: %s", err)
+		}
+		if c != fx.Older && (fx.OlderTag == "" || c != fx.OlderTag) {
+			t.Errorf("Current returned %q on an older revision", c)
+		}
+
+		if err := repo.UpdateVersion(fx.Tip); err != nil {
+			t.Fatalf("UpdateVersion back to %q failed: %s", fx.Tip, err)
+		}
+		checkVersion(t, repo, fx.Head, fx.Tip)
+	})
+
+	t.Run("IsDirty", func(t *testing.T) {
+		if repo.IsDirty() {
+			t.Fatal("IsDirty reported a clean checkout as dirty")
+		}
+
+		name := filepath.Join(local, filepath.FromSlash(fx.Files[0]))
+		orig, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, append(orig, "modified\n"...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !repo.IsDirty() {
+			t.Error("IsDirty did not report a modified file")
+		}
+		if err := os.WriteFile(name, orig, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if repo.IsDirty() {
+			t.Error("IsDirty reported a restored file as dirty")
+		}
+	})
+
+	t.Run("ExportDir", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "export")
+		if err := repo.ExportDir(dir); err != nil {
+			t.Fatalf("ExportDir failed: %s", err)
+		}
+		for _, name := range fx.Files {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+				t.Errorf("ExportDir did not export %s: %s", name, err)
+			}
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.Name() == "."+string(f.Type) {
+				t.Errorf("ExportDir exported the %s metadata directory", e.Name())
+			}
+		}
+	})
+
+	t.Run("Init", func(t *testing.T) {
+		dir := t.TempDir()
+		r, err := f.New(filepath.Join(dir, "remote"), filepath.Join(dir, "local"))
+		if err != nil {
+			t.Fatalf("unable to create repo: %s", err)
+		}
+		if err := r.Init(); err != nil {
+			t.Fatalf("Init failed: %s", err)
+		}
+
+		// Centralized systems, such as Svn, initialize the remote. A checkout
+		// is needed before there is a local repo.
+		if !r.CheckLocal() {
+			if err := r.Get(); err != nil {
+				t.Fatalf("Get after Init failed: %s", err)
+			}
+		}
+		if !r.CheckLocal() {
+			t.Error("CheckLocal did not report a repo after Init")
+		}
+	})
+}
+
+func checkVersion(t *testing.T, repo vcs.Repo, head, tip string) {
+	t.Helper()
+
+	v, err := repo.Version()
+	if err != nil {
+		t.Fatalf("Version failed: %s", err)
+	}
+	if v != head {
+		t.Errorf("Version returned %q instead of %q", v, head)
+	}
+	c, err := repo.Current()
+	if err != nil {
+		t.Fatalf("Current failed: %s", err)
+	}
+	if c != tip {
+		t.Errorf("Current returned %q instead of %q", c, tip)
+	}
+}
@@ -0,0 +1,152 @@
+package vcstest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/vcs"
+)
+
+// GitFactory returns the Factory for vcs.GitRepo.
+func GitFactory() Factory {
+	return Factory{
+		Type: vcs.Git,
+		New: func(remote, local string) (vcs.Repo, error) {
+			return vcs.NewGitRepo(remote, local)
+		},
+		Fixture: gitFixture,
+	}
+}
+
+// HgFactory returns the Factory for vcs.HgRepo.
+func HgFactory() Factory {
+	return Factory{
+		Type: vcs.Hg,
+		New: func(remote, local string) (vcs.Repo, error) {
+			return vcs.NewHgRepo(remote, local)
+		},
+		Fixture: hgFixture,
+	}
+}
+
+// SvnFactory returns the Factory for vcs.SvnRepo.
+func SvnFactory() Factory {
+	return Factory{
+		Type: vcs.Svn,
+		New: func(remote, local string) (vcs.Repo, error) {
+			return vcs.NewSvnRepo(remote, local)
+		},
+		Fixture: svnFixture,
+	}
+}
+
+// BzrFactory returns the Factory for vcs.BzrRepo.
+func BzrFactory() Factory {
+	return Factory{
+		Type: vcs.Bzr,
+		New: func(remote, local string) (vcs.Repo, error) {
+			return vcs.NewBzrRepo(remote, local)
+		},
+		Fixture: bzrFixture,
+	}
+}
+
+// fixtureSpec is the history shared by the fixtures. The first commit is
+// tagged 1.0.0 and, when withBranch is set, a branch named other is created
+// after the second commit.
+func fixtureSpec(withBranch bool) Spec {
+	s := Spec{Commits: []Commit{
+		{Message: "Initial commit", Files: map[string]string{"README.md": "# Test\n"}, Tags: []string{"1.0.0"}},
+		{Message: "Update README.md", Files: map[string]string{"README.md": "# Test\n\nMore words.\n"}},
+	}}
+	if withBranch {
+		s.Commits = append(s.Commits, Commit{Branch: "other", Message: "Start other", Files: map[string]string{"OTHER.md": "# Other\n"}})
+	}
+	return s
+}
+
+// fixture describes a repository built from fixtureSpec.
+func fixture(b *Built, tip, author, missing string) *Fixture {
+	return &Fixture{
+		Remote:       b.Remote,
+		Head:         b.Revisions[1],
+		Tip:          tip,
+		Older:        b.Revisions[0],
+		OlderTag:     "1.0.0",
+		OlderAuthor:  author,
+		OlderMessage: "Initial commit",
+		Missing:      missing,
+		Files:        []string{"README.md"},
+	}
+}
+
+func gitFixture(t testing.TB) *Fixture {
+	fx := fixture(Build(t, vcs.Git, fixtureSpec(true)), "master", DefaultAuthor, strings.Repeat("0", 12))
+	fx.Branches = []string{"master", "other"}
+	return fx
+}
+
+func hgFixture(t testing.TB) *Fixture {
+	fx := fixture(Build(t, vcs.Hg, fixtureSpec(true)), "default", DefaultAuthor, strings.Repeat("f", 12))
+	fx.Branches = []string{"default", "other"}
+	return fx
+}
+
+func svnFixture(t testing.TB) *Fixture {
+	fx := fixture(Build(t, vcs.Svn, fixtureSpec(false)), "HEAD", "Test Author", "99999")
+	// Svn tags are directories in the repo rather than something Tags reports.
+	fx.OlderTag = ""
+	return fx
+}
+
+func bzrFixture(t testing.TB) *Fixture {
+	return fixture(Build(t, vcs.Bzr, fixtureSpec(false)), "-1", DefaultAuthor, "99999")
+}
+
+func requireTool(t testing.TB, name string) {
+	t.Helper()
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s is not installed", name)
+	}
+}
+
+func run(t testing.TB, dir string, env []string, name string, args ...string) string {
+	t.Helper()
+	c := exec.Command(name, args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(), env...)
+	out, err := c.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %s: %s: %s", name, strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func mkdir(t testing.TB, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t testing.TB, dir, name, content string) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	mkdir(t, filepath.Dir(p))
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fileURL converts a local path to a file:// URL as needed by svn.
+func fileURL(p string) string {
+	p = filepath.ToSlash(p)
+	if runtime.GOOS == "windows" {
+		return "file:///" + p
+	}
+	return "file://" + p
+}
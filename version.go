@@ -0,0 +1,276 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AncestorTagger is implemented by repos that can list the tags on a commit
+// and its ancestors. GitRepo and HgRepo implement it. ModuleVersion needs it
+// to compute pseudo-versions.
+type AncestorTagger interface {
+	// AncestorTagsContext retrieves the tags on a commit id and its
+	// ancestors.
+	AncestorTagsContext(ctx context.Context, id string) ([]string, error)
+}
+
+// ModuleVersion returns the Go module version of the revision rev in repo. m
+// describes the module and supplies the tag prefix and major version to use.
+// When m is nil the module is assumed to be at the root of the repository
+// with a major version of v0 or v1.
+//
+// When rev is tagged with a semantic version for the module that version is
+// returned, the highest one if there are several. Otherwise a pseudo-version,
+// such as v1.2.4-0.20150729134639-806b07b08faa, is built from the highest
+// such tag on an ancestor of rev and the UTC time rev was committed. The repo
+// must implement AncestorTagger for a pseudo-version to be built, otherwise
+// ErrUnsupported is returned. Tags that are not canonical semantic versions,
+// such as v1.2, are ignored as they are by the go command.
+func ModuleVersion(repo Repo, rev string, m *Module) (string, error) {
+	return ModuleVersionContext(context.Background(), repo, rev, m)
+}
+
+// ModuleVersionContext is the context-aware version of ModuleVersion.
+func ModuleVersionContext(ctx context.Context, repo Repo, rev string, m *Module) (string, error) {
+	var prefix, major string
+	if m != nil {
+		prefix = m.TagPrefix
+		major = moduleMajor(m)
+	}
+
+	// Repos that do not implement RepoContext, such as those from other
+	// backends, are still supported without the context.
+	var ci *CommitInfo
+	var tags []string
+	var err error
+	if rc, ok := repo.(RepoContext); ok {
+		ci, err = rc.CommitInfoContext(ctx, rev)
+		if err == nil {
+			tags, err = rc.TagsFromCommitContext(ctx, ci.Commit)
+		}
+	} else {
+		ci, err = repo.CommitInfo(rev)
+		if err == nil {
+			tags, err = repo.TagsFromCommit(ci.Commit)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if v, ok := highestVersion(tags, prefix, major); ok {
+		return v, nil
+	}
+
+	at, ok := repo.(AncestorTagger)
+	if !ok {
+		return "", ErrUnsupported
+	}
+	tags, err = at.AncestorTagsContext(ctx, ci.Commit)
+	if err != nil {
+		return "", err
+	}
+	older, _ := highestVersion(tags, prefix, major)
+	return pseudoVersion(major, older, commitTime(ci), ci.Commit), nil
+}
+
+// commitTime returns the time of ci used by the go command in versions and
+// .info files. It is when the commit was committed rather than authored, as
+// they differ for rebased and cherry-picked Git commits.
+func commitTime(ci *CommitInfo) time.Time {
+	if ci.CommitDate.IsZero() {
+		return ci.Date
+	}
+	return ci.CommitDate
+}
+
+// highestVersion returns the highest canonical semantic version with the
+// major version major, or v0 or v1 when it is empty, from the tags starting
+// with prefix.
+func highestVersion(tags []string, prefix, major string) (string, bool) {
+	var best string
+	var bestV semver
+	for _, t := range tags {
+		if !strings.HasPrefix(t, prefix) {
+			continue
+		}
+		v := strings.TrimPrefix(t, prefix)
+		sv, ok := parseSemver(v)
+		if !ok || isPseudoVersion(sv) {
+			continue
+		}
+		if !hasMajor(sv, major) {
+			continue
+		}
+		if best == "" || compareSemver(sv, bestV) > 0 {
+			best, bestV = v, sv
+		}
+	}
+	return best, best != ""
+}
+
+// moduleMajor returns the major version, such as v2, of the versions of m or
+// an empty string for v0 and v1.
+func moduleMajor(m *Module) string {
+	return strings.TrimSuffix(strings.TrimLeft(m.Major, "/."), "-unstable")
+}
+
+// hasMajor reports whether sv has the major version major, or v0 or v1 when
+// it is empty.
+func hasMajor(sv semver, major string) bool {
+	if major == "" {
+		return sv.major <= 1
+	}
+	return "v"+strconv.Itoa(sv.major) == major
+}
+
+// pseudoVersion builds a pseudo-version for the commit rev made at t. older
+// is the highest version tagged on an ancestor of rev, if any. The forms
+// follow the go command:
+//
+//	vX.0.0-yyyymmddhhmmss-abcdefabcdef     with no older version
+//	vX.Y.Z-pre.0.yyyymmddhhmmss-abcdefabcdef for an older prerelease
+//	vX.Y.(Z+1)-0.yyyymmddhhmmss-abcdefabcdef for an older release
+func pseudoVersion(major, older string, t time.Time, rev string) string {
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	segment := t.UTC().Format("20060102150405") + "-" + rev
+
+	if older == "" {
+		if major == "" {
+			major = "v0"
+		}
+		return major + ".0.0-" + segment
+	}
+	sv, _ := parseSemver(older)
+	if sv.prerelease != "" {
+		return older + ".0." + segment
+	}
+	return fmt.Sprintf("v%d.%d.%d-0.%s", sv.major, sv.minor, sv.patch+1, segment)
+}
+
+// semver is a parsed semantic version of the form vMAJOR.MINOR.PATCH with an
+// optional -prerelease.
+type semver struct {
+	major, minor, patch int
+	prerelease          string
+}
+
+// parseSemver parses v when it is a canonical semantic version. Versions
+// with build metadata, leading zeros, or missing parts are not canonical.
+func parseSemver(v string) (semver, bool) {
+	var sv semver
+	if !strings.HasPrefix(v, "v") || strings.Contains(v, "+") {
+		return sv, false
+	}
+	core, pre, hasPre := strings.Cut(v[1:], "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return sv, false
+	}
+	nums := []*int{&sv.major, &sv.minor, &sv.patch}
+	for i, p := range parts {
+		if !isNum(p) {
+			return sv, false
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return sv, false
+		}
+		*nums[i] = n
+	}
+	if hasPre {
+		for _, id := range strings.Split(pre, ".") {
+			if id == "" || strings.Trim(id, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-") != "" {
+				return sv, false
+			}
+			if len(id) > 1 && id[0] == '0' && isDigits(id) {
+				return sv, false
+			}
+		}
+		sv.prerelease = pre
+	}
+	return sv, true
+}
+
+// isNum reports whether s is a decimal number without leading zeros.
+func isNum(s string) bool {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return false
+	}
+	return strings.Trim(s, "0123456789") == ""
+}
+
+// isPseudoVersion reports whether sv is a pseudo-version, which is never used
+// as a tag. The last identifier of the prerelease of a pseudo-version is the
+// time of the commit and its abbreviated id.
+func isPseudoVersion(sv semver) bool {
+	ids := strings.Split(sv.prerelease, ".")
+	ts, rev, ok := strings.Cut(ids[len(ids)-1], "-")
+	return ok && len(ts) == 14 && isDigits(ts) && len(rev) == 12
+}
+
+// isDigits reports whether s is made only of decimal digits.
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// compareSemver returns -1, 0, or 1 as a is lower than, equal to, or higher
+// than b following the precedence rules of semantic versioning.
+func compareSemver(a, b semver) int {
+	for _, d := range [][2]int{{a.major, b.major}, {a.minor, b.minor}, {a.patch, b.patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// A release is higher than any of its prereleases.
+	switch {
+	case a.prerelease == b.prerelease:
+		return 0
+	case a.prerelease == "":
+		return 1
+	case b.prerelease == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a.prerelease, "."), strings.Split(b.prerelease, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := comparePrereleaseID(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// comparePrereleaseID compares two dot separated identifiers of a
+// prerelease. Numeric identifiers are lower than alphanumeric ones.
+func comparePrereleaseID(a, b string) int {
+	an, bn := isDigits(a), isDigits(b)
+	switch {
+	case an && bn:
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
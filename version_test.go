@@ -0,0 +1,114 @@
+package vcs_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/vcs"
+	"github.com/Masterminds/vcs/vcstest"
+)
+
+func TestModuleVersion(t *testing.T) {
+	for _, typ := range []vcs.Type{vcs.Git, vcs.Hg} {
+		t.Run(string(typ), func(t *testing.T) {
+			b := vcstest.Build(t, typ, vcstest.Spec{Commits: []vcstest.Commit{
+				{Files: map[string]string{"a.txt": "a\n"}, Tags: []string{"v1.2.3", "v1.2"}},
+				{Files: map[string]string{"a.txt": "b\n"}},
+				{Files: map[string]string{"a.txt": "c\n"}, Tags: []string{"v1.3.0-rc.1", "sub/dir/v0.1.0", "v2.0.0"}},
+				{Files: map[string]string{"a.txt": "d\n"}},
+			}})
+			repo, err := vcs.NewRepo(b.Remote, filepath.Join(t.TempDir(), "checkout"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Get(); err != nil {
+				t.Fatal(err)
+			}
+
+			sub := &vcs.Module{Path: "example.com/repo/sub/dir", Subdir: "sub/dir", TagPrefix: "sub/dir/"}
+			v2 := &vcs.Module{Path: "example.com/repo/v2", Major: "/v2", MajorSubdir: "v2"}
+			rev := func(i int) string { return b.Revisions[i][:12] }
+			tests := []struct {
+				rev      int
+				m        *vcs.Module
+				expected string
+			}{
+				{0, nil, "v1.2.3"},
+				{1, nil, "v1.2.4-0.20150729144639-" + rev(1)},
+				{2, nil, "v1.3.0-rc.1"},
+				{3, nil, "v1.3.0-rc.1.0.20150729164639-" + rev(3)},
+				{0, sub, "v0.0.0-20150729134639-" + rev(0)},
+				{3, sub, "v0.1.1-0.20150729164639-" + rev(3)},
+				{0, v2, "v2.0.0-20150729134639-" + rev(0)},
+				{2, v2, "v2.0.0"},
+				{3, v2, "v2.0.1-0.20150729164639-" + rev(3)},
+			}
+			for _, tc := range tests {
+				v, err := vcs.ModuleVersion(repo, b.Revisions[tc.rev], tc.m)
+				if err != nil {
+					t.Errorf("commit %d: %s", tc.rev, err)
+					continue
+				}
+				if v != tc.expected {
+					t.Errorf("commit %d: expected %s, got %s", tc.rev, tc.expected, v)
+				}
+			}
+		})
+	}
+}
+
+func TestModuleVersionCommitDate(t *testing.T) {
+	// A rebased commit, committed two days after it was authored.
+	authored := vcstest.DefaultDate.Add(time.Hour)
+	b := vcstest.Build(t, vcs.Git, vcstest.Spec{Commits: []vcstest.Commit{
+		{Files: map[string]string{"go.mod": "module example.com/m.git\n"}, Tags: []string{"v1.0.0"}},
+		{Files: map[string]string{"a.txt": "a\n"}, Date: authored, CommitDate: authored.Add(48 * time.Hour)},
+	}})
+	repo, err := vcs.NewRepo(b.Remote, filepath.Join(t.TempDir(), "checkout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := vcs.ModuleVersion(repo, b.Revisions[1], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "v1.0.1-0.20150731144639-" + b.Revisions[1][:12]
+	if v != expected {
+		t.Errorf("expected %s, got %s", expected, v)
+	}
+	if d := goModDownload(t, b, b.Revisions[1]); v != d.Version {
+		t.Errorf("expected the version %s of go mod download, got %s", d.Version, v)
+	}
+}
+
+// taggedRepo is a Repo without AncestorTagger where only the commit v1 is
+// tagged.
+type taggedRepo struct {
+	vcs.Repo
+}
+
+func (taggedRepo) CommitInfo(id string) (*vcs.CommitInfo, error) {
+	return &vcs.CommitInfo{Commit: id, Date: vcstest.DefaultDate}, nil
+}
+
+func (taggedRepo) TagsFromCommit(id string) ([]string, error) {
+	if id == "v1" {
+		return []string{"v1.0.0"}, nil
+	}
+	return nil, nil
+}
+
+func TestModuleVersionUnsupported(t *testing.T) {
+	v, err := vcs.ModuleVersion(taggedRepo{}, "v1", nil)
+	if err != nil || v != "v1.0.0" {
+		t.Errorf("expected v1.0.0, got %s, %v", v, err)
+	}
+	if _, err := vcs.ModuleVersion(taggedRepo{}, "other", nil); err != vcs.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported for a pseudo-version, got %v", err)
+	}
+}